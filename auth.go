@@ -2,13 +2,28 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type AuthResponse struct {
 	Token   string      `json:"token,omitempty"`
 	Message string      `json:"message,omitempty"`
 	Type    MessageType `json:"type,omitempty"`
+	Code    ErrorCode   `json:"code,omitempty"`
+	// RetryAfterMs is set together with the rate_limited and account_locked codes
+	RetryAfterMs int64 `json:"retryAfterMs,omitempty"`
+	// FailedLogins lists the failed logins since the previous successful one
+	FailedLogins []FailedLoginDTO `json:"failedLogins,omitempty"`
+}
+
+type FailedLoginDTO struct {
+	IP   string    `json:"ip"`
+	Time time.Time `json:"time"`
 }
 
 func handleLogin(w http.ResponseWriter, r *http.Request) {
@@ -25,10 +40,31 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	playerID, err := verifyPlayerCredentials(req.Name, req.Password)
-	if err != nil {
+	playerID, failedLogins, err := verifyPlayerCredentials(req.Name, req.Password, remoteIP(r))
+	var blocked *LoginBlockedError
+	switch {
+	case errors.As(err, &blocked):
+		code, message := ErrorRateLimited, "Too many failed logins, try again later"
+		if blocked.Locked {
+			code, message = ErrorAccountLocked, "Account temporarily locked after too many failed logins"
+		}
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(blocked.RetryAfter.Seconds())), 10))
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(AuthResponse{
+			Type:         ResponseLoginFailed,
+			Code:         code,
+			Message:      message,
+			RetryAfterMs: retryAfterMs(blocked.RetryAfter),
+		})
+		return
+	case errors.Is(err, ErrInvalidCredentials):
 		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(AuthResponse{Type: ResponseLoginFailed, Message: "Invalid username or password"})
+		json.NewEncoder(w).Encode(AuthResponse{Type: ResponseLoginFailed, Code: ErrorInvalidCredentials, Message: "Invalid username or password"})
+		return
+	case err != nil:
+		log.Printf("Login failed for %s: %v", req.Name, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(AuthResponse{Type: ResponseLoginFailed, Code: ErrorInternal, Message: "Login failed"})
 		return
 	}
 
@@ -40,7 +76,12 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(AuthResponse{Token: token, Type: ResponseLoginSuccessful, Message: "Login successful"})
+	json.NewEncoder(w).Encode(AuthResponse{
+		Token:        token,
+		Type:         ResponseLoginSuccessful,
+		Message:      "Login successful",
+		FailedLogins: failedLogins,
+	})
 }
 
 func handleRegister(w http.ResponseWriter, r *http.Request) {
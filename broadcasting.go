@@ -1,21 +1,13 @@
 package main
 
+import "sync"
+
 func broadcastLobbyUpdate(lobby *Lobby) {
 	lobby.Lock.RLock()
-	// Copying mutable fields, leaving immutable ones out
 	playersCopy := make([]*Player, len(lobby.Players))
 	copy(playersCopy, lobby.Players)
-	gameStartCopy := make([]PlayerStarted, len(lobby.GameStart))
-	copy(gameStartCopy, lobby.GameStart)
+	updatedLobby := toLobbyDTO(lobby)
 	lobby.Lock.RUnlock()
-	updatedLobby := LobbyDTO{
-		ID:         lobby.ID,
-		Name:       lobby.Name,
-		MaxPlayers: lobby.MaxPlayers,
-		IsPrivate:  lobby.IsPrivate,
-		Players:    toPlayerResponses(playersCopy),
-		GameStart:  gameStartCopy,
-	}
 
 	for _, player := range playersCopy {
 		lobbyUpdatedResponse := LobbyUpdatedResponse{
@@ -26,43 +18,45 @@ func broadcastLobbyUpdate(lobby *Lobby) {
 	}
 }
 
-func broadcastLobbies() {
+// The lobby list is kept in sync on the clients with deltas. Every active
+// player gets the full list once with the welcome message (or a resync
+// snapshot) and afterwards only lobby_added, lobby_changed and lobby_removed
+// for the lobbies matching its subscription, see publishLobbyDelta. Every
+// change also reaches the lobby persister through here.
+
+// lobbyDeltaLock keeps the deltas in order. A change that checked that its
+// lobby still exists is published before the removal of that lobby, never
+// after it.
+var lobbyDeltaLock sync.Mutex
+
+func broadcastLobbyAdded(lobby *Lobby) {
+	broadcastLobbyChanged(lobby)
+}
+
+func broadcastLobbyChanged(lobby *Lobby) {
+	lobbyDeltaLock.Lock()
+	defer lobbyDeltaLock.Unlock()
+
+	// The lobby may have been removed in the meantime, don't bring it back
 	lobbiesLock.RLock()
-	lobbiesCopy := make([]*Lobby, 0, len(lobbies))
-	for _, l := range lobbies {
-		lobbiesCopy = append(lobbiesCopy, l)
-	}
+	_, exists := lobbies[lobby.ID]
 	lobbiesLock.RUnlock()
-
-	lobbiesResponse := make([]LobbyDTO, 0, len(lobbiesCopy))
-	for _, lobby := range lobbiesCopy {
-		lobby.Lock.RLock()
-		playersCopy := make([]*Player, len(lobby.Players))
-		copy(playersCopy, lobby.Players)
-		gameStartCopy := make([]PlayerStarted, len(lobby.GameStart))
-		copy(gameStartCopy, lobby.GameStart)
-		lobbiesResponse = append(lobbiesResponse, LobbyDTO{
-			ID:         lobby.ID,
-			Name:       lobby.Name,
-			MaxPlayers: lobby.MaxPlayers,
-			IsPrivate:  lobby.IsPrivate,
-			Players:    toPlayerResponses(playersCopy),
-			GameStart:  gameStartCopy,
-		})
-		lobby.Lock.RUnlock()
+	if !exists {
+		return
 	}
 
-	activePlayersLock.RLock()
-	activePlayersCopy := make([]*Player, 0, len(activePlayers))
-	for _, p := range activePlayers {
-		activePlayersCopy = append(activePlayersCopy, p)
-	}
-	activePlayersLock.RUnlock()
-	for _, player := range activePlayersCopy {
-		lobbiesUpdateResponse := LobbiesUpdateResponse{
-			BaseResponse: newBaseResponse(ResponseLobbyList),
-			Lobbies:      lobbiesResponse,
-		}
-		sendResponse(player, lobbiesUpdateResponse)
-	}
+	lobby.Lock.RLock()
+	dto := toLobbyDTO(lobby)
+	lobby.Lock.RUnlock()
+
+	publishLobbyDelta(lobby.ID, &dto)
+	persistLobby(lobby)
+}
+
+func broadcastLobbyRemoved(lobbyID string) {
+	lobbyDeltaLock.Lock()
+	defer lobbyDeltaLock.Unlock()
+
+	publishLobbyDelta(lobbyID, nil)
+	persistLobbyRemoved(lobbyID)
 }
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// simulateConnections registers n fake players whose send buffers are drained
+// by a goroutine, like writePump would do for a real connection.
+func simulateConnections(b *testing.B, n int) {
+	b.Helper()
+
+	activePlayersLock.Lock()
+	activePlayers = make(map[string]*Player, n)
+	for i := 0; i < n; i++ {
+		p := newPlayer(fmt.Sprintf("player-%d", i), fmt.Sprintf("Player %d", i), nil)
+		activePlayers[p.ID] = p
+
+		go func() {
+			for {
+				select {
+				case <-p.Send:
+				case <-p.Done:
+					return
+				}
+			}
+		}()
+	}
+	activePlayersLock.Unlock()
+
+	b.Cleanup(func() {
+		activePlayersLock.Lock()
+		for _, p := range activePlayers {
+			p.close()
+		}
+		activePlayers = make(map[string]*Player)
+		activePlayersLock.Unlock()
+	})
+}
+
+// simulateLobbies creates n lobbies with two players each.
+func simulateLobbies(b *testing.B, n int) []*Lobby {
+	b.Helper()
+
+	lobbiesLock.Lock()
+	lobbies = make(map[string]*Lobby, n)
+	created := make([]*Lobby, 0, n)
+	for i := 0; i < n; i++ {
+		l := &Lobby{
+			ID:         fmt.Sprintf("lobby-%d", i),
+			Name:       fmt.Sprintf("Lobby %d", i),
+			MaxPlayers: 4,
+			Players: []*Player{
+				{ID: fmt.Sprintf("host-%d", i), Name: "Host"},
+				{ID: fmt.Sprintf("guest-%d", i), Name: "Guest"},
+			},
+			GameStart: []PlayerStarted{},
+			Version:   1,
+		}
+		lobbies[l.ID] = l
+		created = append(created, l)
+	}
+	lobbiesLock.Unlock()
+
+	// Every client starts out with the full list, like after the welcome message
+	activePlayersLock.RLock()
+	for _, p := range activePlayers {
+		p.lobbySub.reset(getSortedLobbiesList())
+	}
+	activePlayersLock.RUnlock()
+
+	b.Cleanup(func() {
+		lobbiesLock.Lock()
+		lobbies = make(map[string]*Lobby)
+		lobbiesLock.Unlock()
+	})
+
+	return created
+}
+
+// fullLobbyListBroadcast is what every join, leave and create used to cost:
+// the whole list marshalled and sent to every player.
+func fullLobbyListBroadcast() {
+	response := LobbiesUpdateResponse{
+		BaseResponse: newBaseResponse(ResponseLobbyList),
+		Lobbies:      getLobbiesList(),
+	}
+
+	activePlayersLock.RLock()
+	activePlayersCopy := make([]*Player, 0, len(activePlayers))
+	for _, p := range activePlayers {
+		activePlayersCopy = append(activePlayersCopy, p)
+	}
+	activePlayersLock.RUnlock()
+
+	for _, p := range activePlayersCopy {
+		sendResponse(p, response)
+	}
+}
+
+func BenchmarkLobbyBroadcast(b *testing.B) {
+	for _, connections := range []int{1000, 5000} {
+		for _, lobbyCount := range []int{10, 100} {
+			name := fmt.Sprintf("connections=%d/lobbies=%d", connections, lobbyCount)
+
+			b.Run(name+"/delta", func(b *testing.B) {
+				simulateConnections(b, connections)
+				created := simulateLobbies(b, lobbyCount)
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					broadcastLobbyChanged(created[i%len(created)])
+				}
+			})
+
+			b.Run(name+"/full_list", func(b *testing.B) {
+				simulateConnections(b, connections)
+				simulateLobbies(b, lobbyCount)
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					fullLobbyListBroadcast()
+				}
+			})
+		}
+	}
+}
@@ -90,12 +90,12 @@ export default function UserProfile({ playerName, onLogout, onAddFriend, pending
                   <ul className="list-disc pl-5">
                     {pendingFriendRequests.map((req) => (
                       
-                      <li key={req.friendID} className="flex items-center gap-2">
-                        <span className="text-gray-500">{req.friendName}</span> 
-                        <button className="bg-green-500 text-white px-2 py-1 rounded hover:bg-green-600 transition duration-200" onClick={() => onAcceptFriendRequest(req.friendID, true)}>
+                      <li key={req.id} className="flex items-center gap-2">
+                        <span className="text-gray-500">{req.name}</span> 
+                        <button className="bg-green-500 text-white px-2 py-1 rounded hover:bg-green-600 transition duration-200" onClick={() => onAcceptFriendRequest(req.id, true)}>
                           Accept
                         </button>
-                        <button className="bg-red-500 text-white px-2 py-1 rounded hover:bg-red-600 transition duration-200" onClick={() => onAcceptFriendRequest(req.friendID, false)}>
+                        <button className="bg-red-500 text-white px-2 py-1 rounded hover:bg-red-600 transition duration-200" onClick={() => onAcceptFriendRequest(req.id, false)}>
                           Decline
                         </button>
                       </li>
@@ -2,6 +2,7 @@ import React, { useState } from 'react';
 import { Button } from '@/components/ui/button';
 import { Input } from '@/components/ui/input';
 import { toast } from "sonner";
+import type { AuthResponse } from '@/protocol';
 
 type AuthProps = {
   connectWebSocket: () => void; 
@@ -75,7 +76,7 @@ export default function Auth({ connectWebSocket }: AuthProps) {
         body: JSON.stringify({ name, password }),
       });
 
-      const data = await response.json();
+      const data: AuthResponse = await response.json();
       if (!response.ok) {
         throw new Error(data.message);
       }
@@ -86,6 +87,12 @@ export default function Auth({ connectWebSocket }: AuthProps) {
 
       connectWebSocket();
       toast('Login successful');
+
+      const failedLogins = data.failedLogins ?? [];
+      if (failedLogins.length > 0) {
+        const last = failedLogins[0];
+        toast(`${failedLogins.length} failed login attempt(s) since your last login, most recently from ${last.ip} at ${new Date(last.time).toLocaleString()}`);
+      }
     } catch (error: any) {
       console.error('Login error:', error);
       toast(error.message);
@@ -0,0 +1,641 @@
+// Code generated by protogen from the Go protocol types. DO NOT EDIT.
+// Run `go generate` in the module root after changing them.
+
+export const MessageTypes = {
+  RequestAuthentication: "authenticate",
+  RequestLogin: "login",
+  RequestRegister: "register",
+  RequestCreateLobby: "create_lobby",
+  RequestJoinLobby: "join_lobby",
+  RequestLeaveLobby: "leave_lobby",
+  RequestStartGame: "start_game",
+  RequestCancelGame: "cancel_game",
+  RequestAddFriend: "add_friend",
+  RequestAcceptFriendRequest: "accept_friend_request",
+  RequestGetPendingFriendRequests: "get_pending_friend_requests",
+  RequestResync: "resync",
+  RequestSubscribeLobbies: "subscribe_lobbies",
+  RequestPlayCard: "play_card",
+  RequestGetLegalMoves: "get_legal_moves",
+  RequestSetTeam: "set_team",
+  RequestExchangeCard: "exchange_card",
+  RequestPassTurn: "pass_turn",
+  RequestLoadPosition: "load_position",
+  /** POST /ws-ticket, only used for rate limits */
+  RequestWSTicket: "ws_ticket",
+  ResponseWelcome: "welcome",
+  ResponseLoginSuccessful: "login_successful",
+  ResponseLoginFailed: "login_failed",
+  ResponseRegisterSuccessful: "register_successful",
+  ResponseRegisterFailed: "register_failed",
+  ResponseLobbyCreated: "lobby_created",
+  ResponseLobbyList: "lobby_list",
+  ResponseLobbyUpdated: "lobby_updated",
+  ResponseLobbyAdded: "lobby_added",
+  ResponseLobbyChanged: "lobby_changed",
+  ResponseLobbyRemoved: "lobby_removed",
+  ResponseJoinLobbySuccessful: "join_lobby_successful",
+  ResponseJoinLobbyFailed: "join_lobby_failed",
+  ResponseLobbyLeft: "lobby_left",
+  ResponseFriendRequestSent: "friend_request_sent",
+  ResponsePendingFriendRequests: "pending_friend_requests",
+  ResponseFriendRequestReceived: "friend_request_received",
+  ResponseFriendRequestAccepted: "friend_request_accepted",
+  ResponseFriendOnlineStatus: "friend_online_status",
+  ResponseFriendsList: "friends_list",
+  ResponseSnapshot: "snapshot",
+  ResponseWSTicket: "ws_ticket",
+  ResponseServerShuttingDown: "server_shutting_down",
+  ResponseGameState: "game_state",
+  ResponseLegalMoves: "legal_moves",
+  ResponseTurnSkipped: "turn_skipped",
+  ResponseRoundStarted: "round_started",
+  ResponseError: "error",
+} as const;
+
+export type MessageType = (typeof MessageTypes)[keyof typeof MessageTypes];
+
+/** ErrorCode is the machine-readable reason sent along with an error message. */
+export const ErrorCodes = {
+  ErrorInvalidMessage: "invalid_message",
+  ErrorInvalidRequest: "invalid_request",
+  ErrorUnknownMessageType: "unknown_message_type",
+  ErrorAuthenticationRequired: "authentication_required",
+  ErrorInvalidToken: "invalid_token",
+  ErrorPlayerNotFound: "player_not_found",
+  ErrorLobbyNotFound: "lobby_not_found",
+  ErrorIncorrectPassword: "incorrect_password",
+  ErrorLobbyFull: "lobby_full",
+  ErrorCannotAddSelf: "cannot_add_self",
+  ErrorAlreadyFriends: "already_friends",
+  ErrorFriendRequestExists: "friend_request_exists",
+  ErrorFriendRequestNotFound: "friend_request_not_found",
+  ErrorInternal: "internal_error",
+  ErrorRateLimited: "rate_limited",
+  ErrorAccountLocked: "account_locked",
+  ErrorInvalidCredentials: "invalid_credentials",
+  ErrorGameNotStarted: "game_not_started",
+  ErrorNotYourTurn: "not_your_turn",
+  ErrorInvalidPlay: "invalid_play",
+  ErrorGameOver: "game_over",
+  ErrorNotLobbyHost: "not_lobby_host",
+  ErrorTeamFull: "team_full",
+  ErrorGameAlreadyStarted: "game_already_started",
+  ErrorNoExchange: "no_exchange",
+  ErrorNotAdmin: "not_admin",
+  ErrorNotInLobby: "not_in_lobby",
+} as const;
+
+export type ErrorCode = (typeof ErrorCodes)[keyof typeof ErrorCodes];
+
+/** FigureStatus says where a figure is and what its Position means */
+export const FigureStatuses = {
+  /** in the start area, Position is unused */
+  FigureInStart: "start",
+  /** Position is the square on the shared track */
+  FigureOnTrack: "track",
+  /** Position is the square in the home lane, 0 is the entrance */
+  FigureInHome: "home",
+} as const;
+
+export type FigureStatus = (typeof FigureStatuses)[keyof typeof FigureStatuses];
+
+/** Effect is what playing a card does */
+export const Effects = {
+  /** move one figure forward by one of the move values */
+  EffectMove: "move",
+  /** the move values may also be moved backwards */
+  EffectBackward: "backward",
+  /** bring a figure from the start area onto the board */
+  EffectStart: "start",
+  /** split the move value across several figures */
+  EffectSplit: "split",
+  /** swap two figures on the track */
+  EffectSwap: "swap",
+  /** stand in for any other card of the deck */
+  EffectJoker: "joker",
+} as const;
+
+export type Effect = (typeof Effects)[keyof typeof Effects];
+
+/** Phase is the part of a round the game is in */
+export const Phases = {
+  PhasePlay: "play",
+  /** partners choose the card to give each other */
+  PhaseExchange: "exchange",
+} as const;
+
+export type Phase = (typeof Phases)[keyof typeof Phases];
+
+export interface AuthResponse {
+  token?: string;
+  message?: string;
+  type?: MessageType;
+  code?: ErrorCode;
+  /** RetryAfterMs is set together with the rate_limited and account_locked codes */
+  retryAfterMs?: number;
+  /** FailedLogins lists the failed logins since the previous successful one */
+  failedLogins?: FailedLoginDTO[];
+}
+
+export interface FailedLoginDTO {
+  ip: string;
+  time: string;
+}
+
+/**
+ * BaseRequest holds the fields every inbound message has. RequestID is
+ * optional and chosen by the client; it is echoed in the direct response
+ * and in any error so the client can match them to the request.
+ */
+export interface BaseRequest {
+  type: MessageType;
+  requestID?: string;
+}
+
+export interface PlayerDTO {
+  id: string;
+  name: string;
+}
+
+export interface FriendDTO {
+  id: string;
+  name: string;
+  isOnline: boolean;
+}
+
+export interface PlayerStarted {
+  playerID: string;
+}
+
+export interface LoginRequest extends BaseRequest {
+  name: string;
+  password: string;
+}
+
+export interface RegisterRequest extends BaseRequest {
+  name: string;
+  password: string;
+}
+
+export interface JoinLobbyRequest extends BaseRequest {
+  lobbyID: string;
+  password: string;
+}
+
+export interface CreateLobbyRequest extends BaseRequest {
+  lobbyName: string;
+  maxPlayers: number;
+  isPrivate: boolean;
+  password: string;
+  /**
+   * TeamPlay seats four players as two teams. The host assigns the teams
+   * unless RandomTeams draws them when the game starts
+   */
+  teamPlay: boolean;
+  randomTeams: boolean;
+  /**
+   * Rules are the house rules of the lobby's games, the standard rules if
+   * omitted
+   */
+  rules?: Rules | null;
+}
+
+export interface LeaveLobbyRequest extends BaseRequest {
+  lobbyID: string;
+}
+
+export interface AddFriendRequest extends BaseRequest {
+  friendName: string;
+}
+
+export type GetPendingFriendRequestsRequest = BaseRequest;
+
+export interface AcceptFriendRequestRequest extends BaseRequest {
+  /** person that requested in the first place */
+  friendID: string;
+  acceptRequest: boolean;
+}
+
+export type ResyncRequest = BaseRequest;
+
+export interface LobbyFilter {
+  /** case-insensitive part of the lobby name */
+  search: string;
+  /** 0 matches every size */
+  maxPlayers: number;
+  /** "public", "private" or "" for both */
+  visibility: string;
+  /** only lobbies that can still be joined */
+  hasFreeSeats: boolean;
+  /** hide lobbies with a running game */
+  notStarted: boolean;
+  /** only lobbies with the standard rules */
+  rated: boolean;
+}
+
+export interface SubscribeLobbiesRequest extends BaseRequest {
+  filter: LobbyFilter;
+  /** 0 means all matching lobbies */
+  pageSize: number;
+}
+
+export interface StartGame extends BaseRequest {
+  lobbyID: string;
+}
+
+export interface CancelGame extends BaseRequest {
+  lobbyID: string;
+}
+
+export interface PlayCardRequest extends BaseRequest {
+  lobbyID: string;
+  play: Play;
+}
+
+export interface GetLegalMovesRequest extends BaseRequest {
+  lobbyID: string;
+}
+
+/** SetTeamRequest lets the host put a player of the lobby into team 0 or 1 */
+export interface SetTeamRequest extends BaseRequest {
+  lobbyID: string;
+  playerID: string;
+  team: number;
+}
+
+export interface ExchangeCardRequest extends BaseRequest {
+  lobbyID: string;
+  cardID: number;
+}
+
+/**
+ * LoadPositionRequest opens a practice lobby with the game of a position in
+ * the notation of game.ParseNotation. Only admins may send it.
+ */
+export interface LoadPositionRequest extends BaseRequest {
+  position: string;
+}
+
+/**
+ * PassTurnRequest ends the player's turn without playing, if the lobby's
+ * rules allow passing
+ */
+export interface PassTurnRequest extends BaseRequest {
+  lobbyID: string;
+}
+
+export interface BaseResponse {
+  type: MessageType;
+  /**
+   * Seq is filled in per connection when the message is queued, see Player.enqueue.
+   * It starts at 1 and increases by one for every message, so a gap means a lost update.
+   */
+  seq?: number;
+  /** RequestID echoes the request this message is the direct response to */
+  requestID?: string;
+}
+
+export interface WelcomeResponse extends BaseResponse {
+  player: PlayerDTO;
+  message: string;
+  lobbies: LobbyDTO[];
+  pendingFriendRequests: PlayerDTO[];
+  friendsList: FriendDTO[];
+}
+
+export interface LobbyDTO {
+  id: string;
+  name: string;
+  maxPlayers: number;
+  isPrivate: boolean;
+  players: PlayerDTO[];
+  gameStart: PlayerStarted[];
+  started: boolean;
+  version: number;
+  /** HostID is the player that may assign the teams, the longest in the lobby */
+  hostID: string;
+  teamPlay: boolean;
+  randomTeams: boolean;
+  teams?: Record<string, number>;
+  /** DeckVersion is the deck definition the running game was dealt from */
+  deckVersion?: string;
+  rules: Rules;
+  /** Rated games are played with the standard rules, see game.Rules.IsStandard */
+  rated: boolean;
+  /**
+   * Practice lobbies hold a loaded position, their only player plays
+   * every seat
+   */
+  practice?: boolean;
+}
+
+export interface LobbyUpdatedResponse extends BaseResponse {
+  lobby: LobbyDTO;
+}
+
+export interface LobbiesUpdateResponse extends BaseResponse {
+  lobbies: LobbyDTO[];
+  /** number of matching lobbies, may exceed the page */
+  total: number;
+  pageSize: number;
+}
+
+export interface LobbyAddedResponse extends BaseResponse {
+  lobby: LobbyDTO;
+}
+
+export interface LobbyChangedResponse extends BaseResponse {
+  lobby: LobbyDTO;
+}
+
+export interface LobbyRemovedResponse extends BaseResponse {
+  lobbyID: string;
+}
+
+export interface LobbyJoinFailedResponse extends BaseResponse {
+  code: ErrorCode;
+  message: string;
+}
+
+export interface SuccessfulJoinLobbyResponse extends BaseResponse {
+  lobby: LobbyDTO;
+}
+
+export interface CreateLobbyResponse extends BaseResponse {
+  lobby: LobbyDTO;
+}
+
+export type LobbyLeftResponse = BaseResponse;
+
+export interface FriendRequestSentResponse extends BaseResponse {
+  success: boolean;
+  /** set when Success is false */
+  code?: ErrorCode;
+  message: string;
+}
+
+export interface PendingFriendRequestsResponse extends BaseResponse {
+  pendingFriendRequests: PlayerDTO[];
+}
+
+export interface FriendRequestReceivedResponse extends BaseResponse {
+  player: PlayerDTO;
+}
+
+export interface FriendRequestAcceptedResponse extends BaseResponse {
+  friend: FriendDTO;
+}
+
+export interface FriendsListResponse extends BaseResponse {
+  friendsList: FriendDTO[];
+}
+
+export interface FriendOnlineStatusResponse extends BaseResponse {
+  friend: FriendDTO;
+}
+
+/**
+ * ServerShuttingDownResponse counts down to a restart. Lobbies and games are
+ * saved and can be rejoined after reconnecting.
+ */
+export interface ServerShuttingDownResponse extends BaseResponse {
+  secondsLeft: number;
+}
+
+/**
+ * WSTicketResponse is the answer of POST /ws-ticket. The ticket opens one
+ * websocket as ws://host/ws?ticket=...
+ */
+export interface WSTicketResponse extends BaseResponse {
+  ticket: string;
+  expiresInMs: number;
+}
+
+/**
+ * SnapshotResponse carries the full state a client needs to rebuild its view,
+ * sent in reply to a resync request.
+ */
+export interface SnapshotResponse extends BaseResponse {
+  player: PlayerDTO;
+  lobbies: LobbyDTO[];
+  /** lobby the player is in, if any */
+  lobby: LobbyDTO | null;
+  /** game in that lobby, if one runs */
+  game?: View | null;
+  pendingFriendRequests: PlayerDTO[];
+  friendsList: FriendDTO[];
+}
+
+/**
+ * GameStateResponse is sent to every player of a lobby after the game
+ * changed, each with their own view.
+ */
+export interface GameStateResponse extends BaseResponse {
+  lobbyID: string;
+  game: View;
+  /**
+   * TurnDeadline is when the current turn ends, in Unix milliseconds.
+   * Only set if the lobby has a turn timer.
+   */
+  turnDeadline?: number;
+}
+
+/**
+ * LegalMovesResponse lists what the player can play at the given game
+ * version
+ */
+export interface LegalMovesResponse extends BaseResponse {
+  lobbyID: string;
+  version: number;
+  moves: Move[];
+}
+
+/**
+ * RoundStartedResponse is sent to every player of a lobby when a round was
+ * dealt, with their new hand in the view
+ */
+export interface RoundStartedResponse extends BaseResponse {
+  lobbyID: string;
+  round: number;
+  dealer: string;
+  game: View;
+}
+
+/**
+ * TurnSkippedResponse tells the lobby that a player could not or did not
+ * play any card and discarded the hand, or passed and kept it
+ */
+export interface TurnSkippedResponse extends BaseResponse {
+  lobbyID: string;
+  playerID: string;
+  discarded: Card[];
+  passed?: boolean;
+}
+
+export interface ErrorResponse extends BaseResponse {
+  code: ErrorCode;
+  /** human readable, for display only */
+  error: string;
+  /** RetryAfterMs tells a rate limited client when to try again */
+  retryAfterMs?: number;
+}
+
+/** CardDefinition describes one kind of card */
+export interface CardDefinition {
+  type: string;
+  count: number;
+  moves?: number[];
+  effects: Effect[];
+}
+
+/** DeckDefinition is the versioned composition of a deck */
+export interface DeckDefinition {
+  version: string;
+  description?: string;
+  cards: CardDefinition[];
+}
+
+/**
+ * Figure is one piece of a player. Position is a square of the track or of
+ * the home lane, depending on Status, see board.go.
+ */
+export interface Figure {
+  id: number;
+  status: FigureStatus;
+  position: number;
+}
+
+/**
+ * Card carries the moves and effects of its definition, so a saved game
+ * keeps its rules when the deck definition changes
+ */
+export interface Card {
+  id: number;
+  type: string;
+  moves?: number[];
+  effects: Effect[];
+}
+
+/** Move is a legal play together with the figures it changes */
+export interface Move {
+  play: Play;
+  changes: FigureChange[];
+  /** sends a figure of an opponent back to its start area */
+  capture?: boolean;
+}
+
+/**
+ * FigureChange is where a figure ends up after a move. Captured figures of
+ * other players are listed too.
+ */
+export interface FigureChange {
+  playerID: string;
+  figure: Figure;
+}
+
+/**
+ * Skip records a player whose hand was discarded because none of its cards
+ * could be played, or who passed and kept the hand
+ */
+export interface Skip {
+  playerID: string;
+  discarded?: Card[];
+  passed?: boolean;
+}
+
+/**
+ * Play is what a player does with one card of their hand. Which fields are
+ * needed depends on Effect:
+ * 
+ *   - move, backward: FigureID and Moves
+ *   - start: FigureID
+ *   - split: Split, the steps add up to the card's move value
+ *   - swap: FigureID and Target
+ * 
+ * A joker is played with As set to the card it stands in for and Effect set
+ * to one of that card's effects.
+ */
+export interface Play {
+  cardID: number;
+  effect: Effect;
+  as?: string;
+  figureID: number;
+  moves?: number;
+  split?: SplitStep[];
+  target?: FigureRef | null;
+}
+
+/** SplitStep moves one figure part of a split card's value */
+export interface SplitStep {
+  figureID: number;
+  steps: number;
+}
+
+/** FigureRef names a figure of any player */
+export interface FigureRef {
+  playerID: string;
+  figureID: number;
+}
+
+/**
+ * Rules are the house rules of a game. The zero value is the standard
+ * ruleset.
+ */
+export interface Rules {
+  /** MandatoryCapture forces a capturing play whenever one is possible */
+  mandatoryCapture: boolean;
+  /**
+   * AllowPass lets a player pass the turn and keep the hand. A player who
+   * can not play passes too instead of discarding; the hands are only
+   * discarded once nobody with cards can or wants to play.
+   */
+  allowPass: boolean;
+  /**
+   * UnprotectedStart makes start squares unsafe: a figure on its own
+   * start square can be passed, captured and swapped
+   */
+  unprotectedStart: boolean;
+  /**
+   * HandSizes is the sequence of cards dealt per round, DefaultHandSizes
+   * if empty
+   */
+  handSizes?: number[];
+  /**
+   * TurnTimerSeconds is how long a player has for a turn, 0 for no limit.
+   * A player who runs out of time passes or discards like a stuck player.
+   */
+  turnTimerSeconds?: number;
+}
+
+/**
+ * View is the game as one player may see it: their own hand, but only the
+ * number of cards in the other hands and in the deck.
+ */
+export interface View {
+  version: number;
+  deckVersion: string;
+  /** ID of the player to play next */
+  turn: string;
+  round: number;
+  /** ID of the dealer of the round */
+  dealer: string;
+  /** cards dealt this round */
+  handSize: number;
+  phase: Phase;
+  winners?: string[];
+  deckSize: number;
+  topDiscard?: Card | null;
+  hand: Card[];
+  players: PlayerView[];
+}
+
+export interface PlayerView {
+  id: string;
+  name: string;
+  partner?: string;
+  handSize: number;
+  /** has chosen the card for the partner */
+  exchanged?: boolean;
+  figures: Figure[];
+}
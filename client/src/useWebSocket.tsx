@@ -1,13 +1,13 @@
 // useGameWebSocket.ts
 import { useRef, useEffect } from 'react';
-import type { yourLobby, broadcastedLobby, Player, PageType, friendRequest, Friend } from './structs';
+import type { yourLobby, broadcastedLobby, Player, PageType, friendRequest, Friend, LobbyFilter } from './structs';
 import { MessageTypes, Page } from './structs';
 import { toast } from 'sonner';
 
 interface UseWebSocketProps {
   onSetPlayer: (player: Player) => void;
   onSetLobby: (lobby: yourLobby) => void;
-  onSetLobbies: (lobbies: broadcastedLobby[]) => void;
+  onSetLobbies: React.Dispatch<React.SetStateAction<broadcastedLobby[]>>;
   onSetPendingFriendRequests: (pendingFriendRequests: friendRequest[]) => void;
   onSetFriendsList: React.Dispatch<React.SetStateAction<Friend[]>>;
   onSetPage: (page: PageType) => void;
@@ -23,6 +23,7 @@ export default function useWebSocket({
 }: UseWebSocketProps) {
 
   const ws = useRef<WebSocket | null>(null);
+  const lastSeq = useRef(0);
 
   const setAuthToken = (token: string) => {
     localStorage.setItem('gameToken', token);
@@ -77,21 +78,24 @@ export default function useWebSocket({
 
     const wsUrl = import.meta.env.REACT_APP_WS_URL || 'ws://localhost:4000/ws';
 
-    ws.current = new WebSocket(wsUrl);
-
-    ws.current.onopen = () => {
-      if (ws.current && ws.current.readyState === WebSocket.OPEN) {
-        ws.current.send(JSON.stringify({
-          type: MessageTypes.RequestAuthentication,
-          token: token
-        }));
-      }
-    };
+    // The token authenticates the upgrade itself, the server answers with welcome
+    ws.current = new WebSocket(wsUrl, ['json', `bearer.${token}`]);
+    lastSeq.current = 0;
 
     ws.current.onmessage = (event) => {
       const data = JSON.parse(event.data);
       console.log('WebSocket message received:', data);
 
+      // Every server message carries a per-connection sequence number.
+      // A gap means we missed an update, so ask the server for a full snapshot.
+      if (typeof data.seq === 'number') {
+        const missedUpdate = lastSeq.current > 0 && data.seq > lastSeq.current + 1;
+        lastSeq.current = data.seq;
+        if (missedUpdate && data.type !== MessageTypes.ResponseSnapshot) {
+          ws.current?.send(JSON.stringify({ type: MessageTypes.RequestResync }));
+        }
+      }
+
       switch (data.type) {
 
         case MessageTypes.ResponseWelcome:
@@ -103,10 +107,34 @@ export default function useWebSocket({
           if (data.friendsList) onSetFriendsList(data.friendsList);
           break;
 
+        case MessageTypes.ResponseSnapshot:
+          onSetPlayer(data.player);
+          onSetLobbies(data.lobbies ?? []);
+          onSetLobby(data.lobby ?? ({} as yourLobby));
+          onSetPendingFriendRequests(data.pendingFriendRequests ?? []);
+          onSetFriendsList(data.friendsList ?? []);
+          break;
+
         case MessageTypes.ResponseLobbyList:
           onSetLobbies(data.lobbies);
           break;
 
+        case MessageTypes.ResponseLobbyAdded:
+          onSetLobbies(prev => [...prev.filter(l => l.id !== data.lobby.id), data.lobby]);
+          break;
+
+        case MessageTypes.ResponseLobbyChanged:
+          onSetLobbies(prev =>
+            prev.some(l => l.id === data.lobby.id)
+              ? prev.map(l => (l.id === data.lobby.id && l.version <= data.lobby.version ? data.lobby : l))
+              : [...prev, data.lobby]
+          );
+          break;
+
+        case MessageTypes.ResponseLobbyRemoved:
+          onSetLobbies(prev => prev.filter(l => l.id !== data.lobbyID));
+          break;
+
         case MessageTypes.ResponseLobbyCreated:
           onSetLobby(data.lobby);
           onSetPage(Page.InLobby);
@@ -170,6 +198,10 @@ export default function useWebSocket({
 
   break;
 
+        case MessageTypes.ResponseServerShuttingDown:
+          toast(`Server restarts in ${data.secondsLeft}s, your lobby will be kept`, { id: 'server-shutting-down' });
+          break;
+
         case MessageTypes.ResponseError:
           toast(data.error || 'An error occurred');
           break;
@@ -273,6 +305,13 @@ export default function useWebSocket({
     });
   }
 
+  const subscribeLobbies = (filter: Partial<LobbyFilter>, pageSize = 0) =>
+    sendMessage({
+      type: MessageTypes.RequestSubscribeLobbies,
+      filter,
+      pageSize
+    });
+
   // Auto-connect if token exists
   useEffect(() => {
     const token = getAuthToken();
@@ -289,6 +328,7 @@ export default function useWebSocket({
     leaveLobby,
     startGame,
     cancelGame,
+    subscribeLobbies,
     getAuthToken,
     setAuthToken,
     clearAuthToken,
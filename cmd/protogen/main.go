@@ -0,0 +1,35 @@
+// Command protogen writes the protocol JSON Schema and the client's
+// TypeScript definitions from the Go protocol types. It is run through
+// `go generate` in the module root.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/Daweenci/Web_Lobby/internal/protogen"
+)
+
+func main() {
+	schemaPath := flag.String("schema", protogen.SchemaFile, "where to write the JSON Schema")
+	tsPath := flag.String("ts", protogen.TypeScriptFile, "where to write the TypeScript definitions")
+	flag.Parse()
+
+	dirs := flag.Args()
+	if len(dirs) == 0 {
+		dirs = protogen.DefaultDirs
+	}
+
+	out, err := protogen.Generate(dirs...)
+	if err != nil {
+		log.Fatalf("protogen: %v", err)
+	}
+
+	if err := os.WriteFile(*schemaPath, out.Schema, 0o644); err != nil {
+		log.Fatalf("protogen: %v", err)
+	}
+	if err := os.WriteFile(*tsPath, out.TypeScript, 0o644); err != nil {
+		log.Fatalf("protogen: %v", err)
+	}
+}
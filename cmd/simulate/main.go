@@ -0,0 +1,75 @@
+// Command simulate plays games between bots with the standard deck and a
+// chosen ruleset and reports win rates by seat, game length, card usage and
+// how often players have no legal move. An engine error stops the run with
+// the seed and position of the game.
+//
+//	go run ./cmd/simulate -games 1000 -players 4 -teams -rules '{"allowPass":true}' -format csv
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/Daweenci/Web_Lobby/internal/simulate"
+)
+
+func main() {
+	c := simulate.Config{}
+	flag.IntVar(&c.Games, "games", 100, "number of games to play")
+	flag.Uint64Var(&c.FirstSeed, "seed", 1, "seed of the first game, the following games count up")
+	flag.IntVar(&c.Players, "players", 4, "players per game")
+	flag.BoolVar(&c.Teams, "teams", false, "play two teams of two")
+	flag.IntVar(&c.MaxTurns, "max-turns", 5000, "turns after which a game without a winner is stopped")
+	rules := flag.String("rules", "", "house rules as JSON like in create_lobby, the standard rules if empty")
+	format := flag.String("format", "json", "report format, json or csv")
+	out := flag.String("o", "", "file to write the report to, standard output if empty")
+	flag.Parse()
+
+	if *rules != "" {
+		dec := json.NewDecoder(bytes.NewReader([]byte(*rules)))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&c.Rules); err != nil {
+			log.Fatalf("simulate: invalid rules: %v", err)
+		}
+	}
+	if *format != "json" && *format != "csv" {
+		log.Fatalf("simulate: unknown format %q", *format)
+	}
+
+	report, err := simulate.Run(c)
+	if err != nil {
+		log.Fatalf("simulate: %v", err)
+	}
+	if err := writeReport(report, *format, *out); err != nil {
+		log.Fatalf("simulate: %v", err)
+	}
+}
+
+// writeReport writes the report to the file out, or to standard output if
+// out is empty
+func writeReport(report *simulate.Report, format, out string) error {
+	if out == "" {
+		return encodeReport(report, format, os.Stdout)
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := encodeReport(report, format, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func encodeReport(report *simulate.Report, format string, w io.Writer) error {
+	if format == "csv" {
+		return report.WriteCSV(w)
+	}
+	return report.WriteJSON(w)
+}
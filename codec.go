@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"strconv"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Subprotocols a client can ask for in Sec-WebSocket-Protocol. Without one
+// the connection speaks JSON.
+const (
+	SubprotocolJSON    = "json"
+	SubprotocolMsgpack = "msgpack"
+)
+
+// codec encodes the messages of one connection. The encoding is negotiated
+// once at upgrade time through the websocket subprotocol.
+type codec interface {
+	marshal(v any) ([]byte, error)
+	unmarshal(data []byte, v any) error
+	// withSeq adds the "seq" field to an already encoded message, so one
+	// encoded payload can be shared between connections
+	withSeq(msg []byte, seq uint64) []byte
+	frameType() int
+	subprotocol() string
+}
+
+var (
+	jsonWire    codec = jsonCodec{}
+	msgpackWire codec = msgpackCodec{}
+
+	// Every codec the server speaks, in order of preference
+	wireCodecs = []codec{jsonWire, msgpackWire}
+)
+
+// codecFor returns the codec of the negotiated subprotocol
+func codecFor(subprotocol string) codec {
+	for _, c := range wireCodecs {
+		if c.subprotocol() == subprotocol {
+			return c
+		}
+	}
+	return jsonWire
+}
+
+// wireSubprotocols lists the subprotocols of wireCodecs for the upgrader
+func wireSubprotocols() []string {
+	protocols := make([]string, len(wireCodecs))
+	for i, c := range wireCodecs {
+		protocols[i] = c.subprotocol()
+	}
+	return protocols
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (jsonCodec) frameType() int                     { return websocket.TextMessage }
+func (jsonCodec) subprotocol() string                { return SubprotocolJSON }
+
+func (jsonCodec) withSeq(msg []byte, seq uint64) []byte {
+	if len(msg) < 2 || msg[0] != '{' {
+		return msg
+	}
+
+	out := make([]byte, 0, len(msg)+32)
+	out = append(out, `{"seq":`...)
+	out = strconv.AppendUint(out, seq, 10)
+	if len(msg) > 2 {
+		out = append(out, ',')
+	}
+	return append(out, msg[1:]...)
+}
+
+// msgpackCodec uses the json struct tags, so both encodings share field names
+type msgpackCodec struct{}
+
+func (msgpackCodec) marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+func (msgpackCodec) frameType() int      { return websocket.BinaryMessage }
+func (msgpackCodec) subprotocol() string { return SubprotocolMsgpack }
+
+// withSeq bumps the entry count in the map header and appends the seq entry
+// right behind it.
+func (msgpackCodec) withSeq(msg []byte, seq uint64) []byte {
+	if len(msg) == 0 {
+		return msg
+	}
+
+	var n, headerLen int
+	switch b := msg[0]; {
+	case b >= 0x80 && b <= 0x8f:
+		n, headerLen = int(b&0x0f), 1
+	case b == 0xde && len(msg) >= 3:
+		n, headerLen = int(binary.BigEndian.Uint16(msg[1:3])), 3
+	case b == 0xdf && len(msg) >= 5:
+		n, headerLen = int(binary.BigEndian.Uint32(msg[1:5])), 5
+	default:
+		return msg
+	}
+
+	out := make([]byte, 0, len(msg)+16)
+	switch n++; {
+	case n <= 0x0f:
+		out = append(out, 0x80|byte(n))
+	case n <= 0xffff:
+		out = append(out, 0xde)
+		out = binary.BigEndian.AppendUint16(out, uint16(n))
+	default:
+		out = append(out, 0xdf)
+		out = binary.BigEndian.AppendUint32(out, uint32(n))
+	}
+
+	out = append(out, 0xa3, 's', 'e', 'q', 0xcf)
+	out = binary.BigEndian.AppendUint64(out, seq)
+	return append(out, msg[headerLen:]...)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// decodeMap decodes msg generically, the way a client without the Go types would
+func decodeMap(t *testing.T, c codec, msg []byte) map[string]any {
+	t.Helper()
+	var m map[string]any
+	if err := c.unmarshal(msg, &m); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return m
+}
+
+func TestCodecsEncodeResponsesWithSameFieldNames(t *testing.T) {
+	r := ErrorResponse{
+		BaseResponse: newReplyResponse(ResponseError, "req-1"),
+		Code:         ErrorLobbyFull,
+		Error:        "Lobby is full",
+	}
+
+	for _, c := range wireCodecs {
+		msg, err := c.marshal(r)
+		if err != nil {
+			t.Fatalf("%T: marshal: %v", c, err)
+		}
+
+		m := decodeMap(t, c, c.withSeq(msg, 42))
+		want := map[string]string{"type": "error", "requestID": "req-1", "code": "lobby_full", "error": "Lobby is full"}
+		for k, v := range want {
+			if fmt.Sprint(m[k]) != v {
+				t.Errorf("%T: %s = %v, want %s", c, k, m[k], v)
+			}
+		}
+		if fmt.Sprint(m["seq"]) != "42" {
+			t.Errorf("%T: seq = %v, want 42", c, m["seq"])
+		}
+		if len(m) != len(want)+1 {
+			t.Errorf("%T: got fields %v", c, m)
+		}
+	}
+}
+
+func TestCodecsDecodeRequests(t *testing.T) {
+	in := CreateLobbyRequest{
+		BaseRequest: BaseRequest{Type: RequestCreateLobby, RequestID: "r", PlayerID: "spoofed"},
+		LobbyName:   "Lobby",
+		MaxPlayers:  4,
+	}
+
+	for _, c := range wireCodecs {
+		msg, err := c.marshal(in)
+		if err != nil {
+			t.Fatalf("%T: marshal: %v", c, err)
+		}
+
+		var out CreateLobbyRequest
+		if err := c.unmarshal(msg, &out); err != nil {
+			t.Fatalf("%T: unmarshal: %v", c, err)
+		}
+		if out.Type != in.Type || out.RequestID != in.RequestID || out.LobbyName != in.LobbyName || out.MaxPlayers != in.MaxPlayers {
+			t.Errorf("%T: got %+v", c, out)
+		}
+		if out.PlayerID != "" {
+			t.Errorf("%T: PlayerID must not be read from the wire, got %q", c, out.PlayerID)
+		}
+	}
+}
+
+// TestMsgpackWithSeqGrowsMapHeader covers the switch from a fixmap to map16
+func TestMsgpackWithSeqGrowsMapHeader(t *testing.T) {
+	for _, n := range []int{0, 14, 15, 20} {
+		fields := make(map[string]int, n)
+		for i := 0; i < n; i++ {
+			fields[fmt.Sprintf("f%d", i)] = i
+		}
+
+		msg, err := msgpackWire.marshal(fields)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+
+		m := decodeMap(t, msgpackWire, msgpackWire.withSeq(msg, 7))
+		if len(m) != n+1 || fmt.Sprint(m["seq"]) != "7" {
+			t.Errorf("%d fields: got %v", n, m)
+		}
+	}
+}
+
+func TestCodecForNegotiatedSubprotocol(t *testing.T) {
+	for _, protocol := range upgrader.Subprotocols {
+		if c := codecFor(protocol); c.subprotocol() != protocol {
+			t.Errorf("codecFor(%q) speaks %q", protocol, c.subprotocol())
+		}
+	}
+	if c := codecFor(""); c != jsonWire {
+		t.Errorf("without a subprotocol the connection speaks %q", c.subprotocol())
+	}
+}
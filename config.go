@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Shorter secrets make the HMAC of the JWTs guessable
+const minJWTSecretLength = 32
+
+var (
+	ErrJWTSecretMissing  = errors.New("JWT_SECRET is required")
+	ErrJWTSecretTooShort = fmt.Errorf("JWT_SECRET must be at least %d bytes", minJWTSecretLength)
+)
+
+// Config holds the server settings. Every field is read from the environment
+// variable named in its comment.
+type Config struct {
+	Port        string // PORT
+	DevMode     bool   // DEV_MODE, allows the Vite dev server origins
+	MetricsAddr string // METRICS_ADDR, listen address of /debug/vars, keep it off the public network; empty turns it off
+
+	JWTSecret string        // JWT_SECRET
+	JWTTTL    time.Duration // JWT_TTL, how long a login token is valid
+
+	DBPath     string // DB_PATH
+	SchemaPath string // SCHEMA_PATH
+
+	AllowedOrigins []string // ALLOWED_ORIGINS, comma separated
+
+	WSCompression  bool          // WS_COMPRESSION, negotiate per-message deflate
+	MaxMessageSize int64         // WS_MAX_MESSAGE_SIZE, largest message accepted from a client
+	SendBufferSize int           // WS_SEND_BUFFER, messages queued per player before it is dropped
+	WriteWait      time.Duration // WS_WRITE_WAIT, time allowed to write a message to the peer
+	PongWait       time.Duration // WS_PONG_WAIT, time allowed to read the next pong from the peer
+	AuthTimeout    time.Duration // WS_AUTH_TIMEOUT, time to send the authenticate message
+	WSTicketTTL    time.Duration // WS_TICKET_TTL, how long a ticket from /ws-ticket can be redeemed
+
+	// RATE_LIMITS, JSON object of message types to limits like
+	// {"create_lobby":{"player":{"rate":0.2,"burst":3},"ip":{"rate":0.5,"burst":10}}}.
+	// A listed type replaces its default limits, a missing player or ip
+	// limit means none.
+	RateLimits map[MessageType]messageRateLimit
+
+	LoginLockoutAttempts int           // LOGIN_LOCKOUT_ATTEMPTS, failed logins in a row that lock the account
+	LoginLockoutDuration time.Duration // LOGIN_LOCKOUT_DURATION
+	LoginIPFailureLimit  int           // LOGIN_IP_FAILURE_LIMIT, failed logins of one IP within the window that block it
+	LoginIPFailureWindow time.Duration // LOGIN_IP_FAILURE_WINDOW
+
+	ShutdownGracePeriod time.Duration // SHUTDOWN_GRACE_PERIOD, countdown shown to the players before a shutdown
+	SeatReclaimTimeout  time.Duration // SEAT_RECLAIM_TIMEOUT, how long restored lobbies keep the seats of offline players
+
+	Admins []string // ADMINS, comma separated player names that may load positions into practice lobbies
+}
+
+var config = defaultConfig()
+
+func defaultConfig() Config {
+	return Config{
+		Port:        "4000",
+		MetricsAddr: "localhost:4001",
+		JWTTTL:      24 * time.Hour,
+		DBPath:      "game.db",
+		SchemaPath:  "schema.sql",
+
+		MaxMessageSize: 8192,
+		SendBufferSize: 256,
+		WriteWait:      10 * time.Second,
+		PongWait:       60 * time.Second,
+		AuthTimeout:    10 * time.Second,
+		WSTicketTTL:    30 * time.Second,
+
+		RateLimits: maps.Clone(defaultMessageRateLimits),
+
+		LoginLockoutAttempts: 10,
+		LoginLockoutDuration: 15 * time.Minute,
+		LoginIPFailureLimit:  30,
+		LoginIPFailureWindow: 15 * time.Minute,
+
+		ShutdownGracePeriod: 10 * time.Second,
+		SeatReclaimTimeout:  5 * time.Minute,
+	}
+}
+
+// PingPeriod is how often pings are sent, it must be less than PongWait
+func (c Config) PingPeriod() time.Duration {
+	return c.PongWait * 9 / 10
+}
+
+// loadConfig reads the config from the environment, the .env file and the
+// optional file named by CONFIG_FILE, in that order of precedence. The files
+// use the same KEY=value format.
+func loadConfig() (Config, error) {
+	if err := godotenv.Load(".env"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Config{}, fmt.Errorf("failed to read .env: %w", err)
+	}
+
+	var file map[string]string
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		var err error
+		if file, err = godotenv.Read(path); err != nil {
+			return Config{}, fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+
+	return parseConfig(func(key string) (string, bool) {
+		if v, ok := os.LookupEnv(key); ok {
+			return v, true
+		}
+		v, ok := file[key]
+		return v, ok
+	})
+}
+
+// parseConfig builds a validated Config from the values lookup finds,
+// falling back to the defaults.
+func parseConfig(lookup func(key string) (string, bool)) (Config, error) {
+	c := defaultConfig()
+	p := configParser{lookup: lookup}
+
+	p.string("PORT", &c.Port)
+	p.bool("DEV_MODE", &c.DevMode)
+	p.string("METRICS_ADDR", &c.MetricsAddr)
+	p.string("JWT_SECRET", &c.JWTSecret)
+	p.duration("JWT_TTL", &c.JWTTTL)
+	p.string("DB_PATH", &c.DBPath)
+	p.string("SCHEMA_PATH", &c.SchemaPath)
+	p.list("ALLOWED_ORIGINS", &c.AllowedOrigins)
+
+	p.bool("WS_COMPRESSION", &c.WSCompression)
+	p.int64("WS_MAX_MESSAGE_SIZE", &c.MaxMessageSize)
+	p.int("WS_SEND_BUFFER", &c.SendBufferSize)
+	p.duration("WS_WRITE_WAIT", &c.WriteWait)
+	p.duration("WS_PONG_WAIT", &c.PongWait)
+	p.duration("WS_AUTH_TIMEOUT", &c.AuthTimeout)
+	p.duration("WS_TICKET_TTL", &c.WSTicketTTL)
+
+	p.rateLimits("RATE_LIMITS", c.RateLimits)
+
+	p.int("LOGIN_LOCKOUT_ATTEMPTS", &c.LoginLockoutAttempts)
+	p.duration("LOGIN_LOCKOUT_DURATION", &c.LoginLockoutDuration)
+	p.int("LOGIN_IP_FAILURE_LIMIT", &c.LoginIPFailureLimit)
+	p.duration("LOGIN_IP_FAILURE_WINDOW", &c.LoginIPFailureWindow)
+
+	p.duration("SHUTDOWN_GRACE_PERIOD", &c.ShutdownGracePeriod)
+	p.duration("SEAT_RECLAIM_TIMEOUT", &c.SeatReclaimTimeout)
+
+	p.list("ADMINS", &c.Admins)
+
+	if err := errors.Join(append(p.errs, c.validate())...); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+func (c Config) validate() error {
+	var errs []error
+
+	switch {
+	case c.JWTSecret == "":
+		errs = append(errs, ErrJWTSecretMissing)
+	case len(c.JWTSecret) < minJWTSecretLength:
+		errs = append(errs, ErrJWTSecretTooShort)
+	}
+
+	if c.Port == "" {
+		errs = append(errs, errors.New("PORT must not be empty"))
+	}
+	if c.DBPath == "" || c.SchemaPath == "" {
+		errs = append(errs, errors.New("DB_PATH and SCHEMA_PATH must not be empty"))
+	}
+
+	positive := []struct {
+		key   string
+		value int64
+	}{
+		{"JWT_TTL", int64(c.JWTTTL)},
+		{"WS_MAX_MESSAGE_SIZE", c.MaxMessageSize},
+		{"WS_SEND_BUFFER", int64(c.SendBufferSize)},
+		{"WS_WRITE_WAIT", int64(c.WriteWait)},
+		{"WS_PONG_WAIT", int64(c.PongWait)},
+		{"WS_AUTH_TIMEOUT", int64(c.AuthTimeout)},
+		{"WS_TICKET_TTL", int64(c.WSTicketTTL)},
+		{"LOGIN_LOCKOUT_ATTEMPTS", int64(c.LoginLockoutAttempts)},
+		{"LOGIN_LOCKOUT_DURATION", int64(c.LoginLockoutDuration)},
+		{"LOGIN_IP_FAILURE_LIMIT", int64(c.LoginIPFailureLimit)},
+		{"LOGIN_IP_FAILURE_WINDOW", int64(c.LoginIPFailureWindow)},
+		{"SEAT_RECLAIM_TIMEOUT", int64(c.SeatReclaimTimeout)},
+	}
+	if c.ShutdownGracePeriod < 0 {
+		errs = append(errs, errors.New("SHUTDOWN_GRACE_PERIOD must not be negative"))
+	}
+
+	for _, p := range positive {
+		if p.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", p.key))
+		}
+	}
+
+	for t, limit := range c.RateLimits {
+		switch {
+		case !knownMessageType(t):
+			errs = append(errs, fmt.Errorf("RATE_LIMITS: unknown message type %q", t))
+		case limit.Player.Rate < 0 || limit.Player.Burst < 0 || limit.IP.Rate < 0 || limit.IP.Burst < 0:
+			errs = append(errs, fmt.Errorf("RATE_LIMITS: limits of %s must not be negative", t))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// configParser parses the values of a lookup into typed fields and collects
+// the errors, so all bad values are reported at once.
+type configParser struct {
+	lookup func(key string) (string, bool)
+	errs   []error
+}
+
+func (p *configParser) value(key string) (string, bool) {
+	v, ok := p.lookup(key)
+	return strings.TrimSpace(v), ok
+}
+
+func (p *configParser) fail(key, value string, err error) {
+	p.errs = append(p.errs, fmt.Errorf("invalid %s %q: %w", key, value, err))
+}
+
+func (p *configParser) string(key string, dst *string) {
+	if v, ok := p.value(key); ok {
+		*dst = v
+	}
+}
+
+func (p *configParser) list(key string, dst *[]string) {
+	v, ok := p.value(key)
+	if !ok {
+		return
+	}
+
+	*dst = nil
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*dst = append(*dst, item)
+		}
+	}
+}
+
+// rateLimits merges the limits of the JSON object at key into dst
+func (p *configParser) rateLimits(key string, dst map[MessageType]messageRateLimit) {
+	v, ok := p.value(key)
+	if !ok || v == "" {
+		return
+	}
+
+	var limits map[MessageType]messageRateLimit
+	dec := json.NewDecoder(strings.NewReader(v))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&limits); err != nil {
+		p.fail(key, v, err)
+		return
+	}
+	maps.Copy(dst, limits)
+}
+
+func (p *configParser) bool(key string, dst *bool) {
+	if v, ok := p.value(key); ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			p.fail(key, v, err)
+			return
+		}
+		*dst = b
+	}
+}
+
+func (p *configParser) int(key string, dst *int) {
+	if v, ok := p.value(key); ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			p.fail(key, v, err)
+			return
+		}
+		*dst = n
+	}
+}
+
+func (p *configParser) int64(key string, dst *int64) {
+	if v, ok := p.value(key); ok && v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			p.fail(key, v, err)
+			return
+		}
+		*dst = n
+	}
+}
+
+func (p *configParser) duration(key string, dst *time.Duration) {
+	if v, ok := p.value(key); ok && v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			p.fail(key, v, err)
+			return
+		}
+		*dst = d
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func lookupMap(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := values[key]
+		return v, ok
+	}
+}
+
+func TestParseConfigRejectsWeakJWTSecret(t *testing.T) {
+	for secret, want := range map[string]error{
+		"":      ErrJWTSecretMissing,
+		"short": ErrJWTSecretTooShort,
+	} {
+		_, err := parseConfig(lookupMap(map[string]string{"JWT_SECRET": secret}))
+		if !errors.Is(err, want) {
+			t.Errorf("JWT_SECRET %q: err = %v, want %v", secret, err, want)
+		}
+	}
+}
+
+func TestParseConfigReadsTypedValues(t *testing.T) {
+	c, err := parseConfig(lookupMap(map[string]string{
+		"JWT_SECRET":      "0123456789abcdef0123456789abcdef",
+		"PORT":            "8080",
+		"DEV_MODE":        "true",
+		"DB_PATH":         "/var/lib/lobby/game.db",
+		"ALLOWED_ORIGINS": "https://a.example.com, https://b.example.com,",
+		"WS_PONG_WAIT":    "30s",
+		"WS_SEND_BUFFER":  "64",
+		"ADMINS":          "alice",
+		"METRICS_ADDR":    "127.0.0.1:9100",
+	}))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+
+	if c.Port != "8080" || !c.DevMode || c.DBPath != "/var/lib/lobby/game.db" || c.MetricsAddr != "127.0.0.1:9100" {
+		t.Errorf("got %+v", c)
+	}
+	if len(c.AllowedOrigins) != 2 || c.AllowedOrigins[1] != "https://b.example.com" {
+		t.Errorf("AllowedOrigins = %q", c.AllowedOrigins)
+	}
+	if c.PongWait != 30*time.Second || c.PingPeriod() >= c.PongWait || c.SendBufferSize != 64 {
+		t.Errorf("PongWait = %v, PingPeriod = %v, SendBufferSize = %d", c.PongWait, c.PingPeriod(), c.SendBufferSize)
+	}
+	if len(c.Admins) != 1 || c.Admins[0] != "alice" {
+		t.Errorf("Admins = %q", c.Admins)
+	}
+	if c.SchemaPath != defaultConfig().SchemaPath {
+		t.Errorf("SchemaPath = %q, want the default", c.SchemaPath)
+	}
+}
+
+func TestParseConfigReportsEveryBadValue(t *testing.T) {
+	_, err := parseConfig(lookupMap(map[string]string{
+		"JWT_SECRET":     "0123456789abcdef0123456789abcdef",
+		"WS_PONG_WAIT":   "soon",
+		"WS_SEND_BUFFER": "-1",
+		"DEV_MODE":       "maybe",
+	}))
+	if err == nil {
+		t.Fatal("parseConfig accepted bad values")
+	}
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok || len(joined.Unwrap()) != 3 {
+		t.Errorf("err = %v, want three errors", err)
+	}
+}
+
+func TestParseConfigOverridesRateLimits(t *testing.T) {
+	c, err := parseConfig(lookupMap(map[string]string{
+		"JWT_SECRET":  "0123456789abcdef0123456789abcdef",
+		"RATE_LIMITS": `{"create_lobby":{"player":{"rate":1,"burst":2}}}`,
+	}))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	if got := c.RateLimits[RequestCreateLobby]; got != (messageRateLimit{Player: rateLimit{Rate: 1, Burst: 2}}) {
+		t.Errorf("create_lobby limits %+v", got)
+	}
+	if c.RateLimits[RequestLogin] != defaultMessageRateLimits[RequestLogin] {
+		t.Error("limits of an unlisted type changed")
+	}
+	if defaultMessageRateLimits[RequestCreateLobby].IP.Burst == 0 {
+		t.Error("RATE_LIMITS changed the defaults")
+	}
+
+	for _, limits := range []string{
+		`{"no_such_type":{"ip":{"rate":1,"burst":1}}}`,
+		`{"login":{"ip":{"rate":-1,"burst":1}}}`,
+		`{"login":{"ip":{"rate":1,"burst":1,"window":5}}}`,
+	} {
+		_, err := parseConfig(lookupMap(map[string]string{
+			"JWT_SECRET":  "0123456789abcdef0123456789abcdef",
+			"RATE_LIMITS": limits,
+		}))
+		if err == nil {
+			t.Errorf("RATE_LIMITS %s accepted", limits)
+		}
+	}
+}
@@ -12,7 +12,7 @@ import (
 var db *sql.DB
 
 func initDB() error {
-	dbPath := "game.db"
+	dbPath := config.DBPath
 
 	var err error
 	db, err = sql.Open("sqlite3", dbPath)
@@ -63,9 +63,9 @@ func initDB() error {
 }
 
 func loadSchema() error {
-	schemaBytes, err := os.ReadFile("schema.sql")
+	schemaBytes, err := os.ReadFile(config.SchemaPath)
 	if err != nil {
-		return fmt.Errorf("failed to read schema.sql: %w", err)
+		return fmt.Errorf("failed to read schema: %w", err)
 	}
 
 	_, err = db.Exec(string(schemaBytes))
@@ -73,6 +73,37 @@ func loadSchema() error {
 		return fmt.Errorf("failed to execute schema: %w", err)
 	}
 
+	return migrateSchema()
+}
+
+// migrateSchema adds the columns that were introduced after a table was
+// first created, since CREATE TABLE IF NOT EXISTS leaves old tables as they are.
+func migrateSchema() error {
+	columns := []struct{ table, column, definition string }{
+		{"players", "last_failed_login", "DATETIME"},
+		{"players", "failed_login_count", "INTEGER NOT NULL DEFAULT 0"},
+		{"players", "locked_until", "DATETIME"},
+		{"saved_lobbies", "version", "INTEGER NOT NULL DEFAULT 0"},
+	}
+
+	for _, c := range columns {
+		var exists bool
+		err := db.QueryRow(
+			`SELECT COUNT(*) > 0 FROM pragma_table_info(?) WHERE name = ?`, c.table, c.column,
+		).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to inspect table %s: %w", c.table, err)
+		}
+		if exists {
+			continue
+		}
+
+		_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition))
+		if err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", c.table, c.column, err)
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const (
+	minLobbyPlayers    = 2
+	maxLobbyPlayers    = 4
+	maxLobbyNameLength = 64
+)
+
+var (
+	ErrLobbyIDRequired   = errors.New("lobbyID is required")
+	ErrLobbyNameRequired = errors.New("lobbyName is required")
+	ErrLobbyNameTooLong  = fmt.Errorf("lobbyName must be at most %d characters", maxLobbyNameLength)
+	ErrInvalidLobbySize  = fmt.Errorf("maxPlayers must be between %d and %d", minLobbyPlayers, maxLobbyPlayers)
+	ErrFriendNameMissing = errors.New("friendName is required")
+	ErrFriendIDMissing   = errors.New("friendID is required")
+
+	ErrPlayEffectRequired       = errors.New("play.effect is required")
+	ErrTeamPlayNeedsFour        = fmt.Errorf("teamPlay needs maxPlayers %d", teamPlayers)
+	ErrRandomTeamsNeedsTeamPlay = errors.New("randomTeams needs teamPlay")
+	ErrInvalidTeam              = fmt.Errorf("team must be between 0 and %d", teamCount-1)
+	ErrTargetPlayerRequired     = errors.New("playerID is required")
+	ErrPositionRequired         = errors.New("position is required")
+)
+
+// inboundMessage is implemented by every request that can be sent over the
+// websocket once the connection is authenticated.
+type inboundMessage interface {
+	// bind fills in the fields the server owns, like the PlayerID
+	bind(player *Player)
+}
+
+// validator is implemented by requests that check their own fields before
+// the handler runs.
+type validator interface {
+	validate() error
+}
+
+// messageRoute decodes, binds and validates one message type and calls its handler
+type messageRoute struct {
+	dispatch func(player *Player, msgBytes []byte) error
+	metrics  *expvar.Map
+}
+
+var (
+	messageRoutes  = make(map[MessageType]messageRoute)
+	messageMetrics = expvar.NewMap("websocket_messages")
+)
+
+// serveMetrics serves the expvar counters, including the message metrics, on
+// addr. They are not meant for the public, so addr should only be reachable
+// by the operators, like the default localhost:4001.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	log.Printf("Serving metrics on %s/debug/vars", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("Metrics server stopped: %v", err)
+	}
+}
+
+// errInvalidMessage wraps errors caused by the client's message, as opposed
+// to errors inside the handler.
+type errInvalidMessage struct {
+	code ErrorCode
+	err  error
+}
+
+func (e errInvalidMessage) Error() string { return e.err.Error() }
+
+// registerMessage routes messages of type t to handler. The message is
+// decoded into T, bound to the sending player and validated first.
+func registerMessage[T any, PT interface {
+	*T
+	inboundMessage
+}](t MessageType, handler func(T)) {
+	if _, exists := messageRoutes[t]; exists {
+		panic("registerMessage: duplicate registration for " + string(t))
+	}
+
+	metrics := new(expvar.Map).Init()
+	messageMetrics.Set(string(t), metrics)
+
+	messageRoutes[t] = messageRoute{
+		metrics: metrics,
+		dispatch: func(player *Player, msgBytes []byte) error {
+			var msg T
+			if err := player.codec.unmarshal(msgBytes, &msg); err != nil {
+				return errInvalidMessage{ErrorInvalidMessage, fmt.Errorf("Invalid %s message", t)}
+			}
+
+			PT(&msg).bind(player)
+
+			if v, ok := any(PT(&msg)).(validator); ok {
+				if err := v.validate(); err != nil {
+					return errInvalidMessage{ErrorInvalidRequest, err}
+				}
+			}
+
+			handler(msg)
+			return nil
+		},
+	}
+}
+
+func init() {
+	registerMessage(RequestJoinLobby, joinLobbyHandler)
+	registerMessage(RequestLeaveLobby, leaveLobbyHandler)
+	registerMessage(RequestCreateLobby, createLobbyHandler)
+	registerMessage(RequestStartGame, startGameHandler)
+	registerMessage(RequestCancelGame, cancelGameHandler)
+	registerMessage(RequestAddFriend, sendFriendRequestHandler)
+	registerMessage(RequestAcceptFriendRequest, acceptFriendRequestHandler)
+	registerMessage(RequestGetPendingFriendRequests, getPendingFriendRequestsHandler)
+	registerMessage(RequestResync, resyncHandler)
+	registerMessage(RequestSubscribeLobbies, subscribeLobbiesHandler)
+	registerMessage(RequestPlayCard, playCardHandler)
+	registerMessage(RequestGetLegalMoves, getLegalMovesHandler)
+	registerMessage(RequestSetTeam, setTeamHandler)
+	registerMessage(RequestExchangeCard, exchangeCardHandler)
+	registerMessage(RequestPassTurn, passTurnHandler)
+	registerMessage(RequestLoadPosition, loadPositionHandler)
+}
+
+// dispatchMessage hands an authenticated message to its registered handler.
+// A panicking handler is recovered so it only costs the player this one
+// message, not the connection. Handlers release their locks with defer, so
+// the panic leaves no lobby locked.
+func dispatchMessage(player *Player, base BaseRequest, msgBytes []byte) {
+	route, ok := messageRoutes[base.Type]
+	if !ok {
+		messageMetrics.Add("unknown", 1)
+		sendErrorToPlayer(player, base.RequestID, ErrorUnknownMessageType, "Unknown message type")
+		return
+	}
+
+	start := time.Now()
+	route.metrics.Add("received", 1)
+	defer func() {
+		route.metrics.Add("durationNanos", int64(time.Since(start)))
+
+		if r := recover(); r != nil {
+			route.metrics.Add("panics", 1)
+			log.Printf("Handler for %s panicked: %v\n%s", base.Type, r, debug.Stack())
+			sendErrorToPlayer(player, base.RequestID, ErrorInternal, "Internal server error")
+		}
+	}()
+
+	if err := route.dispatch(player, msgBytes); err != nil {
+		route.metrics.Add("rejected", 1)
+
+		var invalid errInvalidMessage
+		if errors.As(err, &invalid) {
+			sendErrorToPlayer(player, base.RequestID, invalid.code, invalid.Error())
+			return
+		}
+		sendErrorToPlayer(player, base.RequestID, ErrorInternal, err.Error())
+	}
+}
+
+func (r *BaseRequest) bind(player *Player) {
+	r.PlayerID = player.ID
+}
+
+func (r *JoinLobbyRequest) bind(player *Player) {
+	r.PlayerID = player.ID
+	r.Name = player.Name
+}
+
+func (r *CreateLobbyRequest) bind(player *Player) {
+	r.PlayerID = player.ID
+	r.PlayerName = player.Name
+}
+
+func (r *JoinLobbyRequest) validate() error {
+	if r.LobbyID == "" {
+		return ErrLobbyIDRequired
+	}
+	return nil
+}
+
+func (r *LeaveLobbyRequest) validate() error {
+	if r.LobbyID == "" {
+		return ErrLobbyIDRequired
+	}
+	return nil
+}
+
+func (r *StartGame) validate() error {
+	if r.LobbyID == "" {
+		return ErrLobbyIDRequired
+	}
+	return nil
+}
+
+func (r *CancelGame) validate() error {
+	if r.LobbyID == "" {
+		return ErrLobbyIDRequired
+	}
+	return nil
+}
+
+func (r *PlayCardRequest) validate() error {
+	if r.LobbyID == "" {
+		return ErrLobbyIDRequired
+	}
+	if r.Play.Effect == "" {
+		return ErrPlayEffectRequired
+	}
+	return nil
+}
+
+func (r *GetLegalMovesRequest) validate() error {
+	if r.LobbyID == "" {
+		return ErrLobbyIDRequired
+	}
+	return nil
+}
+
+func (r *CreateLobbyRequest) validate() error {
+	r.LobbyName = strings.TrimSpace(r.LobbyName)
+	if r.LobbyName == "" {
+		return ErrLobbyNameRequired
+	}
+	if len([]rune(r.LobbyName)) > maxLobbyNameLength {
+		return ErrLobbyNameTooLong
+	}
+	if r.MaxPlayers < minLobbyPlayers || r.MaxPlayers > maxLobbyPlayers {
+		return ErrInvalidLobbySize
+	}
+	if r.TeamPlay && r.MaxPlayers != teamPlayers {
+		return ErrTeamPlayNeedsFour
+	}
+	if r.RandomTeams && !r.TeamPlay {
+		return ErrRandomTeamsNeedsTeamPlay
+	}
+	if r.Rules != nil {
+		return r.Rules.Validate()
+	}
+	return nil
+}
+
+func (r *SetTeamRequest) validate() error {
+	if r.LobbyID == "" {
+		return ErrLobbyIDRequired
+	}
+	if r.TargetPlayerID == "" {
+		return ErrTargetPlayerRequired
+	}
+	if r.Team < 0 || r.Team >= teamCount {
+		return ErrInvalidTeam
+	}
+	return nil
+}
+
+func (r *ExchangeCardRequest) validate() error {
+	if r.LobbyID == "" {
+		return ErrLobbyIDRequired
+	}
+	return nil
+}
+
+func (r *LoadPositionRequest) validate() error {
+	if strings.TrimSpace(r.Position) == "" {
+		return ErrPositionRequired
+	}
+	return nil
+}
+
+func (r *PassTurnRequest) validate() error {
+	if r.LobbyID == "" {
+		return ErrLobbyIDRequired
+	}
+	return nil
+}
+
+func (r *AddFriendRequest) validate() error {
+	if strings.TrimSpace(r.FriendName) == "" {
+		return ErrFriendNameMissing
+	}
+	return nil
+}
+
+func (r *AcceptFriendRequestRequest) validate() error {
+	if r.FriendID == "" {
+		return ErrFriendIDMissing
+	}
+	return nil
+}
+
+func (r *SubscribeLobbiesRequest) validate() error {
+	if err := r.Filter.validate(); err != nil {
+		return err
+	}
+	if r.PageSize < 0 || r.PageSize > maxLobbyPageSize {
+		return ErrInvalidPageSize
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+
+	"github.com/Daweenci/Web_Lobby/game"
+)
+
+// messageMetric reads a counter of one message type, 0 while it is unset
+func messageMetric(t MessageType, name string) int64 {
+	if m, ok := messageMetrics.Get(string(t)).(*expvar.Map); ok {
+		if v, ok := m.Get(name).(*expvar.Int); ok {
+			return v.Value()
+		}
+	}
+	return 0
+}
+
+func unknownMessages() int64 {
+	if v, ok := messageMetrics.Get("unknown").(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestDispatchMessageCountsMessages(t *testing.T) {
+	const panicking MessageType = "test_panic"
+	registerMessage(panicking, func(BaseRequest) { panic("boom") })
+	t.Cleanup(func() {
+		delete(messageRoutes, panicking)
+		messageMetrics.Delete(string(panicking))
+	})
+
+	player := newPlayer("p", "P", nil)
+	dispatch := func(msgType MessageType, data string) ErrorResponse {
+		t.Helper()
+		dispatchMessage(player, BaseRequest{Type: msgType}, []byte(data))
+
+		var r ErrorResponse
+		select {
+		case sent := <-player.Send:
+			if err := json.Unmarshal(sent, &r); err != nil {
+				t.Fatal(err)
+			}
+		default:
+		}
+		return r
+	}
+
+	received := messageMetric(RequestJoinLobby, "received")
+	rejected := messageMetric(RequestJoinLobby, "rejected")
+	unknown := unknownMessages()
+
+	if r := dispatch(RequestJoinLobby, `{"type":"join_lobby"}`); r.Code != ErrorInvalidRequest {
+		t.Errorf("join_lobby without lobbyID answered %+v", r)
+	}
+	if r := dispatch(RequestJoinLobby, "{"); r.Code != ErrorInvalidMessage {
+		t.Errorf("broken join_lobby answered %+v", r)
+	}
+	if r := dispatch("no_such_type", `{"type":"no_such_type"}`); r.Code != ErrorUnknownMessageType {
+		t.Errorf("unknown type answered %+v", r)
+	}
+	if r := dispatch(panicking, `{"type":"test_panic"}`); r.Code != ErrorInternal {
+		t.Errorf("panicking handler answered %+v", r)
+	}
+
+	if got := messageMetric(RequestJoinLobby, "received") - received; got != 2 {
+		t.Errorf("received %d join_lobby messages, want 2", got)
+	}
+	if got := messageMetric(RequestJoinLobby, "rejected") - rejected; got != 2 {
+		t.Errorf("rejected %d join_lobby messages, want 2", got)
+	}
+	if got := unknownMessages() - unknown; got != 1 {
+		t.Errorf("counted %d unknown messages, want 1", got)
+	}
+	if got := messageMetric(panicking, "panics"); got != 1 {
+		t.Errorf("counted %d panics, want 1", got)
+	}
+	if messageMetric(panicking, "durationNanos") <= 0 {
+		t.Error("handler duration not recorded")
+	}
+}
+
+func TestPanickingGameActionReleasesTheLobby(t *testing.T) {
+	const panicking MessageType = "test_panic_in_lobby"
+	lobby := &Lobby{ID: "lobby", Game: &game.Game{}}
+	registerMessage(panicking, func(msg BaseRequest) {
+		playTurn(lobby, &Player{ID: msg.PlayerID}, func(*game.Game, string) ([]game.Skip, error) { panic("boom") })
+	})
+	t.Cleanup(func() {
+		delete(messageRoutes, panicking)
+		messageMetrics.Delete(string(panicking))
+	})
+
+	dispatchMessage(newPlayer("p", "P", nil), BaseRequest{Type: panicking}, []byte(`{"type":"test_panic_in_lobby"}`))
+	if !lobby.Lock.TryLock() {
+		t.Fatal("the lobby is still locked after the handler panicked")
+	}
+}
@@ -7,7 +7,10 @@ import (
 	"strings"
 )
 
-var ErrFriendRequestExists = errors.New("friend request already exists")
+var (
+	ErrFriendRequestExists   = errors.New("friend request already exists")
+	ErrFriendRequestNotFound = errors.New("friend request not found")
+)
 
 func createFriendRequest(playerID, friendID string) error {
 	query := "INSERT INTO friend_requests (sender_id, receiver_id) VALUES (?, ?)"
@@ -73,7 +76,7 @@ func handleFriendRequest(senderID, receiverID string, acceptRequest bool) error
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		return errors.New("friend request not found")
+		return ErrFriendRequestNotFound
 	}
 
 	if !acceptRequest {
@@ -0,0 +1,170 @@
+package game
+
+// The board has a start area and a home lane per player and one shared
+// track of TrackSegment squares per player, so 32, 48 or 64 squares for 2, 3
+// or 4 players. Seat i enters the track on its start square i*TrackSegment.
+// A figure walks one lap and then turns into its home lane right before its
+// start square; it can not pass the entrance and go round again.
+//
+// A figure sitting on its own start square blocks it: no figure can pass or
+// land there, and it can not be swapped, unless the rules make start squares
+// unprotected. Landing on a figure of another player sends that figure back
+// to its start area.
+
+// TrackSegment is the number of track squares per seat
+const TrackSegment = 16
+
+// HomeSize is the number of squares in each home lane
+const HomeSize = FiguresPerPlayer
+
+// FigureStatus says where a figure is and what its Position means
+type FigureStatus string
+
+const (
+	FigureInStart FigureStatus = "start" // in the start area, Position is unused
+	FigureOnTrack FigureStatus = "track" // Position is the square on the shared track
+	FigureInHome  FigureStatus = "home"  // Position is the square in the home lane, 0 is the entrance
+)
+
+// trackLength is the number of squares on the shared track
+func (g *Game) trackLength() int {
+	return TrackSegment * len(g.Players)
+}
+
+// square wraps a track square into 0..trackLength-1
+func (g *Game) square(sq int) int {
+	n := g.trackLength()
+	return (sq%n + n) % n
+}
+
+func startSquare(seat int) int {
+	return seat * TrackSegment
+}
+
+// progress is how many squares a figure on the track is past its start square
+func (g *Game) progress(seat int, f *Figure) int {
+	return g.square(f.Position - startSquare(seat))
+}
+
+// figureAt returns the figure on a track square and the seat it belongs to
+func (g *Game) figureAt(sq int) (int, *Figure) {
+	for seat, p := range g.Players {
+		for i := range p.Figures {
+			f := &p.Figures[i]
+			if f.Status == FigureOnTrack && f.Position == sq {
+				return seat, f
+			}
+		}
+	}
+	return -1, nil
+}
+
+// blocking reports whether a figure sits on its own start square at sq and
+// the start squares are protected
+func (g *Game) blocking(sq int) bool {
+	if g.Rules.UnprotectedStart {
+		return false
+	}
+	seat, f := g.figureAt(sq)
+	return f != nil && sq == startSquare(seat)
+}
+
+// Finished reports whether all figures of the player are in the home lane
+func (p *PlayerInGame) Finished() bool {
+	for _, f := range p.Figures {
+		if f.Status != FigureInHome {
+			return false
+		}
+	}
+	return true
+}
+
+// enterTrack puts a figure from the start area onto its start square
+func (g *Game) enterTrack(seat, figureID int) error {
+	f, err := g.figure(seat, figureID)
+	if err != nil {
+		return err
+	}
+	if f.Status != FigureInStart {
+		return invalidPlay("figure %d is not in the start area", f.ID)
+	}
+
+	if err := g.land(seat, f, startSquare(seat)); err != nil {
+		return err
+	}
+	f.Status = FigureOnTrack
+	return nil
+}
+
+// moveFigure moves a figure forward, or backwards for negative steps
+func (g *Game) moveFigure(seat, figureID, steps int) error {
+	f, err := g.figure(seat, figureID)
+	if err != nil {
+		return err
+	}
+
+	switch f.Status {
+	case FigureInStart:
+		return invalidPlay("figure %d is in the start area", f.ID)
+	case FigureInHome:
+		if steps < 0 {
+			return invalidPlay("figures in the home lane can not move backwards")
+		}
+		return g.moveInHome(seat, f, f.Position+1, f.Position+steps)
+	}
+
+	if steps < 0 {
+		for i := 1; i < -steps; i++ {
+			if g.blocking(g.square(f.Position - i)) {
+				return invalidPlay("figure %d can not pass a blocked start square", f.ID)
+			}
+		}
+		return g.land(seat, f, g.square(f.Position+steps))
+	}
+
+	// Squares left before the home lane
+	left := g.trackLength() - 1 - g.progress(seat, f)
+	for i := 1; i < steps && i <= left; i++ {
+		if g.blocking(g.square(f.Position + i)) {
+			return invalidPlay("figure %d can not pass a blocked start square", f.ID)
+		}
+	}
+	if steps <= left {
+		return g.land(seat, f, g.square(f.Position+steps))
+	}
+	return g.moveInHome(seat, f, 0, steps-left-1)
+}
+
+// land moves a figure onto a track square and captures the figure there
+func (g *Game) land(seat int, f *Figure, sq int) error {
+	if otherSeat, other := g.figureAt(sq); other != nil && other != f {
+		switch {
+		case otherSeat == seat:
+			return invalidPlay("your figure %d is already on square %d", other.ID, sq)
+		case g.blocking(sq):
+			return invalidPlay("square %d is blocked", sq)
+		}
+		other.Status = FigureInStart
+		other.Position = 0
+	}
+
+	f.Position = sq
+	return nil
+}
+
+// moveInHome moves a figure to the home square to, walking over the squares
+// from..to, which must all be free
+func (g *Game) moveInHome(seat int, f *Figure, from, to int) error {
+	if to >= HomeSize {
+		return invalidPlay("figure %d would overshoot the home lane", f.ID)
+	}
+	for _, other := range g.Players[seat].Figures {
+		if other.ID != f.ID && other.Status == FigureInHome && other.Position >= from && other.Position <= to {
+			return invalidPlay("figure %d can not jump over figure %d in the home lane", f.ID, other.ID)
+		}
+	}
+
+	f.Status = FigureInHome
+	f.Position = to
+	return nil
+}
@@ -0,0 +1,187 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+)
+
+func newBoardGame(t *testing.T, players int) *Game {
+	t.Helper()
+
+	seats := make([]PlayerInGame, players)
+	for i := range seats {
+		seats[i] = PlayerInGame{ID: fmt.Sprintf("p%d", i)}
+	}
+	g := NewGame(seats, &DeckDefinition{Version: "test"}, Rules{}, rand.New(rand.NewPCG(1, 2)))
+	g.Deck = &Deck{}
+	return g
+}
+
+func inHome(g *Game, seat, figureID, position int) {
+	g.Players[seat].Figures[figureID] = Figure{ID: figureID, Status: FigureInHome, Position: position}
+}
+
+func TestBoardMoves(t *testing.T) {
+	tests := []struct {
+		name    string
+		players int
+		setup   func(g *Game)
+		seat    int
+		figure  int
+		steps   int
+		want    Figure
+		wantErr bool
+	}{
+		{
+			name: "enters the home lane before its start square", players: 2,
+			setup: func(g *Game) { onTrack(g, 0, 0, 30) },
+			steps: 3, want: Figure{ID: 0, Status: FigureInHome, Position: 1},
+		},
+		{
+			name: "seat 2 of 3 enters its home lane", players: 3,
+			setup: func(g *Game) { onTrack(g, 2, 0, 30) },
+			seat:  2, steps: 2, want: Figure{ID: 0, Status: FigureInHome, Position: 0},
+		},
+		{
+			name: "seat 3 of 4 wraps around the track", players: 4,
+			setup: func(g *Game) { onTrack(g, 3, 0, 60) },
+			seat:  3, steps: 10, want: Figure{ID: 0, Status: FigureOnTrack, Position: 6},
+		},
+		{
+			name: "overshooting the home lane", players: 2,
+			setup: func(g *Game) { onTrack(g, 0, 0, 30) },
+			steps: 6, wantErr: true,
+		},
+		{
+			name: "jumping over a figure in the home lane", players: 2,
+			setup: func(g *Game) {
+				onTrack(g, 0, 0, 31)
+				inHome(g, 0, 1, 1)
+			},
+			steps: 3, wantErr: true,
+		},
+		{
+			name: "moving on in the home lane", players: 2,
+			setup: func(g *Game) { inHome(g, 0, 0, 0) },
+			steps: 3, want: Figure{ID: 0, Status: FigureInHome, Position: 3},
+		},
+		{
+			name: "backwards in the home lane", players: 2,
+			setup: func(g *Game) { inHome(g, 0, 0, 2) },
+			steps: -1, wantErr: true,
+		},
+		{
+			name: "passing a blocked start square", players: 3,
+			setup: func(g *Game) {
+				onTrack(g, 0, 0, 12)
+				onTrack(g, 1, 0, 16)
+			},
+			steps: 5, wantErr: true,
+		},
+		{
+			name: "landing on a blocked start square", players: 2,
+			setup: func(g *Game) {
+				onTrack(g, 0, 0, 12)
+				onTrack(g, 1, 0, 16)
+			},
+			steps: 4, wantErr: true,
+		},
+		{
+			name: "passing a blocked start square backwards", players: 2,
+			setup: func(g *Game) {
+				onTrack(g, 0, 0, 18)
+				onTrack(g, 1, 0, 16)
+			},
+			steps: -4, wantErr: true,
+		},
+		{
+			name: "passing a figure that is not on its start square", players: 2,
+			setup: func(g *Game) {
+				onTrack(g, 0, 0, 12)
+				onTrack(g, 1, 0, 14)
+			},
+			steps: 5, want: Figure{ID: 0, Status: FigureOnTrack, Position: 17},
+		},
+		{
+			name: "landing on an own figure", players: 2,
+			setup: func(g *Game) {
+				onTrack(g, 0, 0, 3)
+				onTrack(g, 0, 1, 8)
+			},
+			steps: 5, wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newBoardGame(t, tt.players)
+			tt.setup(g)
+
+			err := g.moveFigure(tt.seat, tt.figure, tt.steps)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidPlay) {
+					t.Fatalf("err = %v, want ErrInvalidPlay", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("moveFigure: %v", err)
+			}
+			if got := g.Players[tt.seat].Figures[tt.figure]; got != tt.want {
+				t.Errorf("figure %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLandingOnAnOpponentCapturesIt(t *testing.T) {
+	g := newBoardGame(t, 4)
+	onTrack(g, 0, 0, 40)
+	onTrack(g, 2, 1, 45)
+
+	if err := g.moveFigure(0, 0, 5); err != nil {
+		t.Fatal(err)
+	}
+	if f := g.Players[2].Figures[1]; f.Status != FigureInStart {
+		t.Errorf("captured figure %+v, want back in start", f)
+	}
+}
+
+func TestEnteringCapturesTheFigureOnTheStartSquare(t *testing.T) {
+	g := newBoardGame(t, 2)
+	onTrack(g, 1, 0, 0)
+
+	if err := g.enterTrack(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if f := g.Players[1].Figures[0]; f.Status != FigureInStart {
+		t.Errorf("figure on the start square %+v, want back in start", f)
+	}
+
+	// The start square is now blocked by the figure that entered
+	if err := g.enterTrack(0, 1); !errors.Is(err, ErrInvalidPlay) {
+		t.Errorf("second figure entered onto a blocked start square: %v", err)
+	}
+}
+
+func TestGameIsWonWhenAllFiguresAreHome(t *testing.T) {
+	g := newBoardGame(t, 2)
+	for id := 1; id < FiguresPerPlayer; id++ {
+		inHome(g, 0, id, id)
+	}
+	onTrack(g, 0, 0, 31)
+	g.Players[0].Hand = []Card{card(1, "ace", []int{1, 11}, EffectMove, EffectStart)}
+	g.Players[1].Hand = []Card{card(2, "two", []int{2}, EffectMove)}
+
+	if err := g.PlayCard("p0", Play{CardID: 1, Effect: EffectMove, FigureID: 0, Moves: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Winners) != 1 || g.Winners[0] != "p0" {
+		t.Fatalf("Winners = %v, want p0", g.Winners)
+	}
+	if err := g.PlayCard("p1", Play{CardID: 2, Effect: EffectMove, Moves: 2}); !errors.Is(err, ErrGameOver) {
+		t.Errorf("play after the game was won: %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+package game
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// The deck is not hard coded: decks/standard.json defines how many of each
+// card there are and what they do, so rules can be tweaked without touching
+// the engine. Every game records the version of the definition it was dealt
+// from.
+
+//go:embed decks/standard.json
+var standardDeckFile []byte
+
+// MaxMoves is the largest move value a card may have, one lap of a
+// two player board
+const MaxMoves = 32
+
+var ErrInvalidDeck = errors.New("invalid deck definition")
+
+// Effect is what playing a card does
+type Effect string
+
+const (
+	EffectMove     Effect = "move"     // move one figure forward by one of the move values
+	EffectBackward Effect = "backward" // the move values may also be moved backwards
+	EffectStart    Effect = "start"    // bring a figure from the start area onto the board
+	EffectSplit    Effect = "split"    // split the move value across several figures
+	EffectSwap     Effect = "swap"     // swap two figures on the track
+	EffectJoker    Effect = "joker"    // stand in for any other card of the deck
+)
+
+// effects that need move values
+var movingEffects = []Effect{EffectMove, EffectBackward, EffectSplit}
+
+// CardDefinition describes one kind of card
+type CardDefinition struct {
+	Type    string   `json:"type"`
+	Count   int      `json:"count"`
+	Moves   []int    `json:"moves,omitempty"`
+	Effects []Effect `json:"effects"`
+}
+
+// DeckDefinition is the versioned composition of a deck
+type DeckDefinition struct {
+	Version     string           `json:"version"`
+	Description string           `json:"description,omitempty"`
+	Cards       []CardDefinition `json:"cards"`
+}
+
+// StandardDeck returns the embedded deck definition. It is parsed and
+// validated once, call it at startup to catch a broken definition early.
+var StandardDeck = sync.OnceValues(func() (*DeckDefinition, error) {
+	return ParseDeckDefinition(standardDeckFile)
+})
+
+// ParseDeckDefinition reads and validates a deck definition. Unknown fields
+// are rejected so typos do not silently change the rules.
+func ParseDeckDefinition(data []byte) (*DeckDefinition, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var d DeckDefinition
+	if err := dec.Decode(&d); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDeck, err)
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// Validate checks that every card kind is unique, has a known set of effects
+// and the move values those effects need.
+func (d *DeckDefinition) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]any{ErrInvalidDeck}, args...)...))
+	}
+
+	if d.Version == "" {
+		fail("version is missing")
+	}
+	if len(d.Cards) == 0 {
+		fail("no cards")
+	}
+
+	seen := make(map[string]bool)
+	for _, c := range d.Cards {
+		if c.Type == "" {
+			fail("card without type")
+			continue
+		}
+		if seen[c.Type] {
+			fail("card %q is defined twice", c.Type)
+		}
+		seen[c.Type] = true
+		// A position writes a card as its type followed by its ID
+		if strings.IndexFunc(c.Type, func(r rune) bool { return !unicode.IsLetter(r) }) >= 0 {
+			fail("card %q: the type may only contain letters", c.Type)
+		}
+
+		if c.Count <= 0 {
+			fail("card %q: count must be positive", c.Type)
+		}
+		if len(c.Effects) == 0 {
+			fail("card %q has no effects", c.Type)
+		}
+		for _, e := range c.Effects {
+			if !e.valid() {
+				fail("card %q: unknown effect %q", c.Type, e)
+			}
+		}
+		for _, m := range c.Moves {
+			if m <= 0 || m > MaxMoves {
+				fail("card %q: move %d out of range 1..%d", c.Type, m, MaxMoves)
+			}
+		}
+
+		moves := slices.ContainsFunc(c.Effects, func(e Effect) bool { return slices.Contains(movingEffects, e) })
+		switch {
+		case moves && len(c.Moves) == 0:
+			fail("card %q needs move values", c.Type)
+		case !moves && len(c.Moves) > 0:
+			fail("card %q has move values but no effect that moves", c.Type)
+		}
+
+		switch {
+		case slices.Contains(c.Effects, EffectJoker) && len(c.Effects) > 1:
+			fail("card %q: a joker can not have other effects", c.Type)
+		case slices.Contains(c.Effects, EffectSplit) && len(c.Moves) != 1:
+			fail("card %q: a split card needs exactly one move value", c.Type)
+		case slices.Contains(c.Effects, EffectBackward) && !slices.Contains(c.Effects, EffectMove):
+			fail("card %q: backward needs the move effect", c.Type)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (e Effect) valid() bool {
+	switch e {
+	case EffectMove, EffectBackward, EffectStart, EffectSplit, EffectSwap, EffectJoker:
+		return true
+	}
+	return false
+}
+
+// Size is the number of cards in a full deck
+func (d *DeckDefinition) Size() int {
+	n := 0
+	for _, c := range d.Cards {
+		n += c.Count
+	}
+	return n
+}
+
+// Card returns the definition of a card type
+func (d *DeckDefinition) Card(cardType string) (CardDefinition, bool) {
+	for _, c := range d.Cards {
+		if c.Type == cardType {
+			return c, true
+		}
+	}
+	return CardDefinition{}, false
+}
+
+// NewDeck builds a full shuffled deck. Card IDs are unique within the deck.
+func (d *DeckDefinition) NewDeck(rng *rand.Rand) *Deck {
+	deck := &Deck{Cards: make([]Card, 0, d.Size())}
+	for _, c := range d.Cards {
+		for range c.Count {
+			deck.Cards = append(deck.Cards, Card{
+				ID:      len(deck.Cards),
+				Type:    c.Type,
+				Moves:   slices.Clone(c.Moves),
+				Effects: slices.Clone(c.Effects),
+			})
+		}
+	}
+
+	rng.Shuffle(len(deck.Cards), func(i, j int) {
+		deck.Cards[i], deck.Cards[j] = deck.Cards[j], deck.Cards[i]
+	})
+	return deck
+}
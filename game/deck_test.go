@@ -0,0 +1,81 @@
+package game
+
+import (
+	"errors"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestStandardDeckIsValid(t *testing.T) {
+	d, err := StandardDeck()
+	if err != nil {
+		t.Fatalf("StandardDeck: %v", err)
+	}
+	if d.Version == "" {
+		t.Error("standard deck has no version")
+	}
+	if d.Size() != 110 {
+		t.Errorf("Size() = %d, want 110", d.Size())
+	}
+
+	deck := d.NewDeck(rand.New(rand.NewPCG(1, 2)))
+	if len(deck.Cards) != d.Size() {
+		t.Fatalf("deck has %d cards, want %d", len(deck.Cards), d.Size())
+	}
+	ids := make(map[int]bool)
+	for _, c := range deck.Cards {
+		if ids[c.ID] {
+			t.Fatalf("card ID %d dealt twice", c.ID)
+		}
+		ids[c.ID] = true
+		if _, ok := d.Card(c.Type); !ok {
+			t.Errorf("card %d has unknown type %q", c.ID, c.Type)
+		}
+	}
+}
+
+func TestNewGameRecordsDeckVersion(t *testing.T) {
+	d, err := StandardDeck()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := NewGame([]PlayerInGame{{ID: "a"}, {ID: "b"}}, d, Rules{}, rand.New(rand.NewPCG(1, 2)))
+	if g.DeckVersion != d.Version {
+		t.Errorf("DeckVersion = %q, want %q", g.DeckVersion, d.Version)
+	}
+	cards := len(g.Deck.Cards)
+	for _, p := range g.Players {
+		cards += len(p.Hand)
+	}
+	if cards != d.Size() {
+		t.Errorf("deck and hands hold %d cards, want %d", cards, d.Size())
+	}
+}
+
+func TestParseDeckDefinitionRejectsBadDecks(t *testing.T) {
+	tests := map[string]string{
+		"not json":         `{`,
+		"unknown field":    `{"version":"1","cards":[{"type":"a","count":1,"moves":[1],"effects":["move"],"colour":"red"}]}`,
+		"no version":       `{"cards":[{"type":"a","count":1,"moves":[1],"effects":["move"]}]}`,
+		"no cards":         `{"version":"1","cards":[]}`,
+		"duplicate type":   `{"version":"1","cards":[{"type":"a","count":1,"moves":[1],"effects":["move"]},{"type":"a","count":1,"moves":[2],"effects":["move"]}]}`,
+		"zero count":       `{"version":"1","cards":[{"type":"a","count":0,"moves":[1],"effects":["move"]}]}`,
+		"unknown effect":   `{"version":"1","cards":[{"type":"a","count":1,"moves":[1],"effects":["teleport"]}]}`,
+		"move missing":     `{"version":"1","cards":[{"type":"a","count":1,"effects":["move"]}]}`,
+		"move too large":   `{"version":"1","cards":[{"type":"a","count":1,"moves":[99],"effects":["move"]}]}`,
+		"moves on swap":    `{"version":"1","cards":[{"type":"a","count":1,"moves":[1],"effects":["swap"]}]}`,
+		"joker with more":  `{"version":"1","cards":[{"type":"a","count":1,"effects":["joker","start"]}]}`,
+		"split two values": `{"version":"1","cards":[{"type":"a","count":1,"moves":[1,7],"effects":["split"]}]}`,
+		"digit in type":    `{"version":"1","cards":[{"type":"a1","count":1,"moves":[1],"effects":["move"]}]}`,
+		"backward alone":   `{"version":"1","cards":[{"type":"a","count":1,"moves":[4],"effects":["backward"]}]}`,
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseDeckDefinition([]byte(data)); !errors.Is(err, ErrInvalidDeck) {
+				t.Errorf("err = %v, want ErrInvalidDeck", err)
+			}
+		})
+	}
+}
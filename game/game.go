@@ -0,0 +1,104 @@
+package game
+
+import (
+	"math/rand/v2"
+	"slices"
+)
+
+// FiguresPerPlayer is the number of figures every player starts with
+const FiguresPerPlayer = 4
+
+// MinPlayers and MaxPlayers bound the players of a game
+const (
+	MinPlayers = 2
+	MaxPlayers = 4
+)
+
+// NewGame seats the players in the given order with all their figures in
+// the start area, shuffles a full deck of the given definition and deals the
+// first round. For team play the caller sets the Partner of every player.
+// The rules must be valid.
+func NewGame(players []PlayerInGame, deck *DeckDefinition, rules Rules, rng *rand.Rand) *Game {
+	g := &Game{
+		Players:     make([]*PlayerInGame, len(players)),
+		Deck:        deck.NewDeck(rng),
+		DeckVersion: deck.Version,
+		Dealer:      len(players) - 1, // so the first seat begins
+		Rules:       rules,
+		rng:         rng,
+	}
+
+	for i := range players {
+		p := players[i]
+		p.Figures = make([]Figure, FiguresPerPlayer)
+		for id := range p.Figures {
+			p.Figures[id] = Figure{ID: id, Status: FigureInStart}
+		}
+		g.Players[i] = &p
+	}
+
+	g.dealRound()
+	return g
+}
+
+// seatOf returns the index of the player in Players, or -1
+func (g *Game) seatOf(playerID string) int {
+	for i, p := range g.Players {
+		if p.ID == playerID {
+			return i
+		}
+	}
+	return -1
+}
+
+// CurrentPlayer is the player to play next
+func (g *Game) CurrentPlayer() *PlayerInGame {
+	return g.Players[g.Turn]
+}
+
+// Clone copies the game deeply, so a play can be tried on the copy or the
+// copy saved while the game goes on. Cards are never changed, so the copies
+// share their moves and effects, and the copy draws from the same random
+// source.
+func (g *Game) Clone() *Game {
+	c := g.cloneFigures()
+	for _, p := range c.Players {
+		p.Hand = slices.Clone(p.Hand)
+		if p.Exchange != nil {
+			exchange := *p.Exchange
+			p.Exchange = &exchange
+		}
+	}
+	if g.Deck != nil {
+		c.Deck = &Deck{Cards: slices.Clone(g.Deck.Cards)}
+	}
+	c.Discard = slices.Clone(g.Discard)
+	c.Winners = slices.Clone(g.Winners)
+	return c
+}
+
+// cloneFigures copies only the players and their figures, which is all an
+// effect changes, see applyEffect. The copy shares the cards with g.
+func (g *Game) cloneFigures() *Game {
+	c := *g
+	c.Players = make([]*PlayerInGame, len(g.Players))
+	for i, p := range g.Players {
+		cp := *p
+		cp.Figures = slices.Clone(p.Figures)
+		c.Players[i] = &cp
+	}
+	return &c
+}
+
+func cloneCards(cards []Card) []Card {
+	if cards == nil {
+		return nil
+	}
+	c := make([]Card, len(cards))
+	for i, card := range cards {
+		c[i] = card
+		c[i].Moves = slices.Clone(card.Moves)
+		c[i].Effects = slices.Clone(card.Effects)
+	}
+	return c
+}
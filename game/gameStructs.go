@@ -1,29 +1,50 @@
 package game
 
+import "math/rand/v2"
+
 type Game struct {
-	Players []*PlayerInGame
-	Deck    *Deck
+	Players     []*PlayerInGame
+	Deck        *Deck
+	Discard     []Card // played cards, the last one on top
+	DeckVersion string // version of the deck definition the game was dealt from
+	Turn        int    // index into Players of the player to play next
+	Round       int    // starts at 1
+	Dealer      int    // index into Players of the dealer of the current round
+	Rules       Rules
+	Phase       Phase
+	Winners     []string // IDs of the winning player or team, the game is over then
+	Version     uint64   // incremented on every state change so clients can detect stale views
+	Passes      int      // players that passed in a row, see Rules.AllowPass
+
+	rng *rand.Rand // shuffles the discard pile into the deck
 }
 
 type PlayerInGame struct {
-	ID      string
-	Name    string
-	Hand    []Card
-	Figures []Figure
+	ID       string
+	Name     string
+	Partner  string // ID of the partner sitting opposite in team play
+	Hand     []Card
+	Exchange *Card // card chosen for the partner during the exchange
+	Figures  []Figure
 }
 
 type Deck struct {
 	Cards []Card
 }
 
+// Figure is one piece of a player. Position is a square of the track or of
+// the home lane, depending on Status, see board.go.
 type Figure struct {
-	ID       int
-	Status   string
-	Position int
+	ID       int          `json:"id"`
+	Status   FigureStatus `json:"status"`
+	Position int          `json:"position"`
 }
 
+// Card carries the moves and effects of its definition, so a saved game
+// keeps its rules when the deck definition changes
 type Card struct {
-	ID    int
-	Type  string
-	Moves int
+	ID      int      `json:"id"`
+	Type    string   `json:"type"`
+	Moves   []int    `json:"moves,omitempty"`
+	Effects []Effect `json:"effects"`
 }
@@ -0,0 +1,295 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// checkInvariants fails the test if g is in a state the engine must never
+// reach. ids are the IDs of every card of the game, before is the game
+// before the last step or nil.
+func checkInvariants(t *testing.T, g *Game, ids []int, before *Game) {
+	t.Helper()
+
+	// Cards are neither lost nor duplicated
+	var got []int
+	for _, c := range g.allCards() {
+		got = append(got, c.ID)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, ids) {
+		t.Fatalf("cards %v, want %v\nposition: %s", got, ids, g.Notation())
+	}
+
+	track := make(map[int]string)
+	for seat, p := range g.Players {
+		if len(p.Figures) != FiguresPerPlayer {
+			t.Fatalf("%s has %d figures\nposition: %s", p.ID, len(p.Figures), g.Notation())
+		}
+		home := make(map[int]bool)
+		for i, f := range p.Figures {
+			if f.ID != i {
+				t.Fatalf("figure %d of %s has ID %d", i, p.ID, f.ID)
+			}
+			switch f.Status {
+			case FigureOnTrack:
+				if other, ok := track[f.Position]; ok || f.Position < 0 || f.Position >= g.trackLength() {
+					t.Fatalf("figure %d of %s on track square %d with %q\nposition: %s", i, p.ID, f.Position, other, g.Notation())
+				}
+				track[f.Position] = p.ID
+			case FigureInHome:
+				if home[f.Position] || f.Position < 0 || f.Position >= HomeSize {
+					t.Fatalf("figure %d of %s on home square %d\nposition: %s", i, p.ID, f.Position, g.Notation())
+				}
+				home[f.Position] = true
+			case FigureInStart:
+			default:
+				t.Fatalf("figure %d of %s has status %q", i, p.ID, f.Status)
+			}
+
+			// A figure in the home lane stays there and only moves on
+			if before != nil {
+				if prev := before.Players[seat].Figures[i]; prev.Status == FigureInHome && (f.Status != FigureInHome || f.Position < prev.Position) {
+					t.Fatalf("home figure %d of %s moved from %+v to %+v\nbefore: %s\nafter: %s", i, p.ID, prev, f, before.Notation(), g.Notation())
+				}
+			}
+		}
+	}
+
+	// Exactly one player is to play, and can
+	if g.Turn < 0 || g.Turn >= len(g.Players) {
+		t.Fatalf("Turn = %d with %d players", g.Turn, len(g.Players))
+	}
+	if len(g.Winners) > 0 || g.Phase != PhasePlay {
+		return
+	}
+	for seat, p := range g.Players {
+		moves, err := g.LegalMoves(p.ID)
+		switch {
+		case seat != g.Turn && !errors.Is(err, ErrNotYourTurn):
+			t.Fatalf("%s can play out of turn: %v", p.ID, err)
+		case seat == g.Turn && (err != nil || len(moves) == 0):
+			t.Fatalf("Advance stopped at %s without a legal move: %v\nposition: %s", p.ID, err, g.Notation())
+		}
+	}
+}
+
+func cardIDs(g *Game) []int {
+	var ids []int
+	for _, c := range g.allCards() {
+		ids = append(ids, c.ID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// newRandomGame seats 2 to 4 players, in teams or not, with random house
+// rules and deals the first round
+func newRandomGame(t testing.TB, rng *rand.Rand) *Game {
+	d, err := StandardDeck()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	players := make([]PlayerInGame, 2+rng.IntN(3))
+	for i := range players {
+		players[i] = PlayerInGame{ID: fmt.Sprintf("p%d", i)}
+	}
+	if len(players) == 4 && rng.IntN(2) == 0 {
+		for i := range players {
+			players[i].Partner = players[(i+2)%4].ID
+		}
+	}
+	rules := Rules{
+		MandatoryCapture: rng.IntN(2) == 0,
+		AllowPass:        rng.IntN(2) == 0,
+		UnprotectedStart: rng.IntN(2) == 0,
+	}
+	if rng.IntN(2) == 0 {
+		rules.HandSizes = []int{1 + rng.IntN(maxHandSize)}
+	}
+
+	g := NewGame(players, d, rules, rand.New(rand.NewPCG(rng.Uint64(), rng.Uint64())))
+	g.Advance()
+	return g
+}
+
+// randomStep makes the current player act: mostly a random legal move,
+// sometimes a pass or a time out, during the exchange a random card for
+// the partner. It reports false once the game is over.
+func randomStep(t testing.TB, g *Game, rng *rand.Rand) bool {
+	if len(g.Winners) > 0 || g.handsEmpty() {
+		return false
+	}
+
+	if g.Phase == PhaseExchange {
+		for _, p := range g.Players {
+			if p.Partner != "" && p.Exchange == nil {
+				if err := g.ExchangeCard(p.ID, p.Hand[rng.IntN(len(p.Hand))].ID); err != nil {
+					t.Fatalf("exchange of %s: %v\nposition: %s", p.ID, err, g.Notation())
+				}
+				break
+			}
+		}
+		g.Advance()
+		return true
+	}
+
+	p := g.CurrentPlayer()
+	switch n := rng.IntN(20); {
+	case n == 0 && g.Rules.AllowPass:
+		if _, err := g.Pass(p.ID); err != nil {
+			t.Fatalf("pass of %s: %v", p.ID, err)
+		}
+	case n == 1:
+		if _, err := g.TimeOut(); err != nil {
+			t.Fatalf("time out of %s: %v", p.ID, err)
+		}
+	default:
+		moves, err := g.LegalMoves(p.ID)
+		if err != nil || len(moves) == 0 {
+			t.Fatalf("no legal move for %s: %v\nposition: %s", p.ID, err, g.Notation())
+		}
+		move := moves[rng.IntN(len(moves))]
+		if err := g.PlayCard(p.ID, move.Play); err != nil {
+			t.Fatalf("legal move %+v rejected: %v\nposition: %s", move.Play, err, g.Notation())
+		}
+	}
+	g.Advance()
+	return true
+}
+
+func TestRandomGamesKeepInvariants(t *testing.T) {
+	games, steps := 20, 200
+	if testing.Short() {
+		games, steps = 5, 100
+	}
+
+	for seed := range uint64(games) {
+		t.Run(fmt.Sprintf("seed %d", seed), func(t *testing.T) {
+			rng := rand.New(rand.NewPCG(seed, 0))
+			g := newRandomGame(t, rng)
+			ids := cardIDs(g)
+			checkInvariants(t, g, ids, nil)
+
+			for range steps {
+				before := g.Clone()
+				if !randomStep(t, g, rng) {
+					break
+				}
+				checkInvariants(t, g, ids, before)
+			}
+		})
+	}
+}
+
+func TestRejectedPlaysLeaveTheGameUnchanged(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	g := newRandomGame(t, rng)
+	effects := []Effect{EffectMove, EffectBackward, EffectStart, EffectSplit, EffectSwap, "teleport"}
+
+	for range 200 {
+		if !randomStep(t, g, rng) {
+			break
+		}
+		if g.Phase != PhasePlay || len(g.Winners) > 0 {
+			continue
+		}
+
+		p := g.CurrentPlayer()
+		play := Play{
+			CardID:   p.Hand[rng.IntN(len(p.Hand))].ID,
+			Effect:   effects[rng.IntN(len(effects))],
+			FigureID: rng.IntN(FiguresPerPlayer + 1),
+			Moves:    rng.IntN(15) - 1,
+		}
+		position := g.Notation()
+		if err := g.PlayCard(p.ID, play); err != nil && g.Notation() != position {
+			t.Fatalf("rejected play %+v changed the game: %v\nbefore: %s\nafter: %s", play, err, position, g.Notation())
+		}
+		g.Advance()
+	}
+}
+
+func FuzzPlayCard(f *testing.F) {
+	f.Add(uint64(1), uint8(10), uint8(0), uint8(0), uint8(0), int8(3), uint8(0), 2, 5)
+	f.Add(uint64(7), uint8(40), uint8(1), uint8(3), uint8(2), int8(7), uint8(1), 1, 6)
+	f.Add(uint64(3), uint8(0), uint8(2), uint8(4), uint8(1), int8(-4), uint8(3), math.MaxInt, math.MaxInt)
+
+	effects := []Effect{EffectMove, EffectBackward, EffectStart, EffectSplit, EffectSwap, EffectJoker}
+	f.Fuzz(func(t *testing.T, seed uint64, steps, cardIndex, effect, figure uint8, moves int8, target uint8, first, second int) {
+		rng := rand.New(rand.NewPCG(seed, 0))
+		g := newRandomGame(t, rng)
+		ids := cardIDs(g)
+		for range int(steps) % 60 {
+			if !randomStep(t, g, rng) {
+				return
+			}
+		}
+		if g.Phase != PhasePlay || len(g.Winners) > 0 {
+			return
+		}
+
+		p := g.CurrentPlayer()
+		c := p.Hand[int(cardIndex)%len(p.Hand)]
+		play := Play{
+			CardID:   c.ID,
+			Effect:   effects[int(effect)%len(effects)],
+			FigureID: int(figure) % (FiguresPerPlayer + 1),
+			Moves:    int(moves),
+		}
+		if kinds := g.cardKinds(); slices.Contains(c.Effects, EffectJoker) && len(kinds) > 0 {
+			kind := kinds[int(target)%len(kinds)]
+			play.As = kind.Type
+			play.Effect = kind.Effects[int(effect)%len(kind.Effects)]
+		}
+		if play.Effect == EffectSplit {
+			// The second figure may be the first one again
+			play.Split = []SplitStep{{FigureID: play.FigureID, Steps: first}, {FigureID: int(target) % FiguresPerPlayer, Steps: second}}
+		}
+		if play.Effect == EffectSwap {
+			other := g.Players[(g.Turn+1+int(target))%len(g.Players)]
+			play.Target = &FigureRef{PlayerID: other.ID, FigureID: int(target) % FiguresPerPlayer}
+		}
+
+		before := g.Clone()
+		position := g.Notation()
+		if err := g.PlayCard(p.ID, play); err != nil {
+			if g.Notation() != position {
+				t.Fatalf("rejected play %+v changed the game: %v\nbefore: %s\nafter: %s", play, err, position, g.Notation())
+			}
+			return
+		}
+		g.Advance()
+		checkInvariants(t, g, ids, before)
+	})
+}
+
+func FuzzParseNotation(f *testing.F) {
+	f.Add("v1 test 1/1/0/0 play a,Alice,,t5.s.s.s,two1,-;b,Bob,,t7.s.s.s,king2,- - - - - 0")
+	f.Add("v1 test 2/0/1/1 exchange a,,c,h0.h1.s.t3,-,two1;b,,d,s.s.s.s,king2,-;c,,a,s.s.s.s,-,-;d,,b,s.s.s.s,-,- - - a,c capture,pass,hands=2.1,timer=30 9")
+	f.Add("v1 test 1/1/0/0 play a%2Cb,%F0,,t63.s.s.s,-,- - - - - 0")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		g, err := ParseNotation(s, notationDeck)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidPosition) {
+				t.Fatalf("ParseNotation(%q) = %v, want ErrInvalidPosition", s, err)
+			}
+			return
+		}
+
+		n := g.Notation()
+		again, err := ParseNotation(n, notationDeck)
+		if err != nil {
+			t.Fatalf("ParseNotation(%q) rejects its own notation %q: %v", s, n, err)
+		}
+		if again.Notation() != n {
+			t.Fatalf("notation of %q changed on the round trip: %q, %q", s, n, again.Notation())
+		}
+	})
+}
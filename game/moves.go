@@ -0,0 +1,220 @@
+package game
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Move is a legal play together with the figures it changes
+type Move struct {
+	Play    Play           `json:"play"`
+	Changes []FigureChange `json:"changes"`
+	Capture bool           `json:"capture,omitempty"` // sends a figure of an opponent back to its start area
+}
+
+// FigureChange is where a figure ends up after a move. Captured figures of
+// other players are listed too.
+type FigureChange struct {
+	PlayerID string `json:"playerID"`
+	Figure   Figure `json:"figure"`
+}
+
+// Skip records a player whose hand was discarded because none of its cards
+// could be played, or who passed and kept the hand
+type Skip struct {
+	PlayerID  string `json:"playerID"`
+	Discarded []Card `json:"discarded,omitempty"`
+	Passed    bool   `json:"passed,omitempty"`
+}
+
+// LegalMoves lists every play the player can make now. Plays that leave the
+// figures in the same places are listed once per card type: a split shows up
+// once per outcome and not once per order of its steps, and of two cards of
+// the same type only the first is listed. With Rules.MandatoryCapture only
+// the captures are listed if there are any.
+func (g *Game) LegalMoves(playerID string) ([]Move, error) {
+	seat := g.seatOf(playerID)
+	if seat < 0 {
+		return nil, ErrNotInGame
+	}
+	if len(g.Winners) > 0 {
+		return nil, ErrGameOver
+	}
+	if g.Phase == PhaseExchange {
+		return nil, ErrExchangePending
+	}
+	if seat != g.Turn {
+		return nil, ErrNotYourTurn
+	}
+	return g.legalMoves(seat, false), nil
+}
+
+// legalMoves tries every candidate play on a copy of the game. With first
+// set it stops at the first legal one, which need not be a capture.
+func (g *Game) legalMoves(seat int, first bool) []Move {
+	var moves []Move
+	controlled := g.controlledSeat(seat)
+	kinds := g.cardKinds()
+	outcomes := make(map[string]bool)
+	for _, c := range g.Players[seat].Hand {
+		for _, candidate := range g.candidates(controlled, c, kinds) {
+			card, err := resolveCard(c, candidate.As, kinds)
+			if err != nil {
+				continue
+			}
+			next := g.cloneFigures()
+			if next.applyEffect(controlled, card, candidate) != nil {
+				continue
+			}
+			changes := g.changes(next)
+			outcome := fmt.Sprint(c.Type, changes)
+			if outcomes[outcome] {
+				continue
+			}
+			outcomes[outcome] = true
+			moves = append(moves, Move{Play: candidate, Changes: changes, Capture: g.captures(next, controlled)})
+			if first {
+				return moves
+			}
+		}
+	}
+
+	if g.Rules.MandatoryCapture {
+		captures := slices.DeleteFunc(slices.Clone(moves), func(m Move) bool { return !m.Capture })
+		if len(captures) > 0 {
+			return captures
+		}
+	}
+	return moves
+}
+
+// captures reports whether a play that moved the figures of seat sent a
+// figure of an opponent back to its start area. Knocking out a partner's
+// figure is no capture.
+func (g *Game) captures(next *Game, seat int) bool {
+	for s, p := range g.Players {
+		if s == seat || s == g.partnerSeat(seat) {
+			continue
+		}
+		for i, f := range p.Figures {
+			if f.Status != FigureInStart && next.Players[s].Figures[i].Status == FigureInStart {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// candidates lists the plays of a card with the figures of seat to try,
+// legal or not. A joker is tried as every one of kinds.
+func (g *Game) candidates(seat int, c Card, kinds []Card) []Play {
+	if slices.Contains(c.Effects, EffectJoker) {
+		var plays []Play
+		for _, kind := range kinds {
+			for _, p := range g.candidates(seat, kind, nil) {
+				p.CardID = c.ID
+				p.As = kind.Type
+				plays = append(plays, p)
+			}
+		}
+		return plays
+	}
+
+	figures := g.Players[seat].Figures
+	var plays []Play
+	for _, effect := range c.Effects {
+		switch effect {
+		case EffectMove, EffectBackward:
+			for _, f := range figures {
+				for _, m := range c.Moves {
+					plays = append(plays, Play{CardID: c.ID, Effect: effect, FigureID: f.ID, Moves: m})
+				}
+			}
+
+		case EffectStart:
+			for _, f := range figures {
+				plays = append(plays, Play{CardID: c.ID, Effect: effect, FigureID: f.ID})
+			}
+
+		case EffectSplit:
+			for _, split := range splits(len(figures), c.Moves[0]) {
+				plays = append(plays, Play{CardID: c.ID, Effect: effect, Split: split})
+			}
+
+		case EffectSwap:
+			for _, f := range figures {
+				for targetSeat, other := range g.Players {
+					if targetSeat == seat {
+						continue
+					}
+					for _, target := range other.Figures {
+						plays = append(plays, Play{CardID: c.ID, Effect: effect, FigureID: f.ID,
+							Target: &FigureRef{PlayerID: other.ID, FigureID: target.ID}})
+					}
+				}
+			}
+		}
+	}
+	return plays
+}
+
+// splits lists every way to split total steps across distinct figures, in
+// every order, since moving one figure first can clear the way for another
+func splits(figures, total int) [][]SplitStep {
+	var all [][]SplitStep
+	var walk func(used []bool, steps []SplitStep, left int)
+	walk = func(used []bool, steps []SplitStep, left int) {
+		if left == 0 {
+			all = append(all, slices.Clone(steps))
+			return
+		}
+		for id := range figures {
+			if used[id] {
+				continue
+			}
+			used[id] = true
+			for n := 1; n <= left; n++ {
+				walk(used, append(steps, SplitStep{FigureID: id, Steps: n}), left-n)
+			}
+			used[id] = false
+		}
+	}
+	walk(make([]bool, figures), nil, total)
+	return all
+}
+
+// cardKinds returns one card of every type in the game except the jokers,
+// in the order they first appear
+func (g *Game) cardKinds() []Card {
+	var kinds []Card
+	seen := make(map[string]bool)
+	for _, c := range g.allCards() {
+		if !seen[c.Type] && !slices.Contains(c.Effects, EffectJoker) {
+			seen[c.Type] = true
+			kinds = append(kinds, c)
+		}
+	}
+	return kinds
+}
+
+// changes lists the figures that differ between g and next
+func (g *Game) changes(next *Game) []FigureChange {
+	var changes []FigureChange
+	for seat, p := range g.Players {
+		for i, f := range p.Figures {
+			if after := next.Players[seat].Figures[i]; after != f {
+				changes = append(changes, FigureChange{PlayerID: p.ID, Figure: after})
+			}
+		}
+	}
+	return changes
+}
+
+func (g *Game) handsEmpty() bool {
+	for _, p := range g.Players {
+		if len(p.Hand) > 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestLegalMovesCanAllBePlayed(t *testing.T) {
+	g := newTestGame(t)
+	onTrack(g, 0, 0, 5)
+	onTrack(g, 0, 1, 20)
+	onTrack(g, 1, 0, 9)
+	g.Players[0].Hand = []Card{
+		card(1, "ace", []int{1, 11}, EffectMove, EffectStart),
+		card(2, "four", []int{4}, EffectMove, EffectBackward),
+		card(3, "seven", []int{7}, EffectSplit),
+		card(4, "jack", nil, EffectSwap),
+		card(5, "joker", nil, EffectJoker),
+	}
+
+	moves, err := g.LegalMoves("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byEffect := make(map[Effect]int)
+	for _, m := range moves {
+		byEffect[m.Play.Effect]++
+
+		next := g.Clone()
+		if err := next.PlayCard("a", m.Play); err != nil {
+			t.Errorf("listed move %+v is rejected: %v", m.Play, err)
+		}
+		if len(m.Changes) == 0 {
+			t.Errorf("move %+v changes no figure", m.Play)
+		}
+	}
+
+	for _, effect := range []Effect{EffectMove, EffectBackward, EffectStart, EffectSplit, EffectSwap} {
+		if byEffect[effect] == 0 {
+			t.Errorf("no legal %s move listed", effect)
+		}
+	}
+}
+
+func TestLegalMovesListCaptures(t *testing.T) {
+	g := newTestGame(t)
+	onTrack(g, 0, 0, 5)
+	onTrack(g, 1, 0, 7)
+	g.Players[0].Hand = []Card{card(1, "two", []int{2}, EffectMove)}
+
+	moves, err := g.LegalMoves("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 1 {
+		t.Fatalf("moves %+v, want exactly one", moves)
+	}
+
+	want := []FigureChange{
+		{PlayerID: "a", Figure: Figure{ID: 0, Status: FigureOnTrack, Position: 7}},
+		{PlayerID: "b", Figure: Figure{ID: 0, Status: FigureInStart}},
+	}
+	if got := moves[0].Changes; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("changes %+v, want %+v", got, want)
+	}
+}
+
+func TestLegalMovesOfAFullHandStayFew(t *testing.T) {
+	d, err := StandardDeck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGame([]PlayerInGame{{ID: "a", Name: "Alice"}, {ID: "b", Name: "Bob"}}, d, Rules{}, rand.New(rand.NewPCG(1, 2)))
+	g.Phase = PhasePlay
+	for id := range FiguresPerPlayer {
+		onTrack(g, 0, id, 3+7*id)
+	}
+	// A hand of seven cards, all of them sevens and jokers
+	for _, c := range g.Deck.Cards {
+		if (c.Type == "seven" || c.Type == "joker") && len(g.Players[0].Hand) < 7 {
+			g.Players[0].Hand = append(g.Players[0].Hand, c)
+		}
+	}
+
+	moves, err := g.LegalMoves("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A seven has 120 outcomes on four figures, each order of its steps used
+	// to be listed on its own, for every seven in the hand
+	if len(moves) > 300 {
+		t.Errorf("%d legal moves", len(moves))
+	}
+
+	seen := make(map[string]bool)
+	for _, m := range moves {
+		i := slices.IndexFunc(g.Players[0].Hand, func(c Card) bool { return c.ID == m.Play.CardID })
+		outcome := fmt.Sprint(g.Players[0].Hand[i].Type, m.Changes)
+		if seen[outcome] {
+			t.Fatalf("outcome %s listed twice", outcome)
+		}
+		seen[outcome] = true
+	}
+}
+
+func TestLegalMovesOnlyForTheCurrentPlayer(t *testing.T) {
+	g := newTestGame(t)
+	if _, err := g.LegalMoves("b"); !errors.Is(err, ErrNotYourTurn) {
+		t.Errorf("err = %v, want ErrNotYourTurn", err)
+	}
+}
+
+func TestSplitsCoverEveryOrder(t *testing.T) {
+	// 2 steps across 2 figures: 0:2, 0:1+1:1, 1:2, 1:1+0:1
+	if got := len(splits(2, 2)); got != 4 {
+		t.Errorf("len(splits(2, 2)) = %d, want 4", got)
+	}
+	for _, split := range splits(FiguresPerPlayer, 7) {
+		total := 0
+		for _, s := range split {
+			total += s.Steps
+		}
+		if total != 7 {
+			t.Fatalf("split %+v adds up to %d", split, total)
+		}
+	}
+}
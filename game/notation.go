@@ -0,0 +1,407 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A position is a whole game written as one line of space separated
+// fields, for bug reports and tests:
+//
+//	v1 standard-1 2/1/0/0 play a,Alice,,t5.s.s.h3,ace12.two40,-;b,Bob,,s.s.s.s,-,- seven3.king7 two9 - pass,hands=4.4 17
+//
+// The fields are the notation version, the deck version, round/dealer/
+// turn/passes, the phase, the players separated by ';', the deck from the
+// bottom to the top card, the discard pile from the bottom to the top card,
+// the winners separated by ',', the rules and the state version. A player
+// is id,name,partner,figures,hand,exchange card. A figure is s for the
+// start area, tN for track square N or hN for home square N, a card is its
+// type followed by its ID. IDs and names are URL query escaped and '-'
+// stands for an empty list. The rules are '-' for the standard rules or a
+// ',' separated list of capture, pass, unprotected, hands=N.N... and
+// timer=N.
+
+const notationVersion = "v1"
+
+var ErrInvalidPosition = errors.New("invalid position")
+
+func invalidPosition(format string, args ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{ErrInvalidPosition}, args...)...)
+}
+
+// Notation writes the game as a position
+func (g *Game) Notation() string {
+	players := make([]string, len(g.Players))
+	for i, p := range g.Players {
+		exchange := "-"
+		if p.Exchange != nil {
+			exchange = formatCard(*p.Exchange)
+		}
+		players[i] = strings.Join([]string{
+			url.QueryEscape(p.ID), url.QueryEscape(p.Name), url.QueryEscape(p.Partner),
+			formatFigures(p.Figures), formatCards(p.Hand), exchange,
+		}, ",")
+	}
+
+	var deck []Card
+	if g.Deck != nil {
+		deck = g.Deck.Cards
+	}
+	winners := "-"
+	if len(g.Winners) > 0 {
+		escaped := make([]string, len(g.Winners))
+		for i, id := range g.Winners {
+			escaped[i] = url.QueryEscape(id)
+		}
+		winners = strings.Join(escaped, ",")
+	}
+
+	return strings.Join([]string{
+		notationVersion,
+		url.QueryEscape(g.DeckVersion),
+		fmt.Sprintf("%d/%d/%d/%d", g.Round, g.Dealer, g.Turn, g.Passes),
+		string(g.Phase),
+		strings.Join(players, ";"),
+		formatCards(deck),
+		formatCards(g.Discard),
+		winners,
+		formatRules(g.Rules),
+		strconv.FormatUint(g.Version, 10),
+	}, " ")
+}
+
+// ParseNotation reads a position written by Notation. The cards get their
+// moves and effects from deck, which must be the definition the position
+// was dealt from. The position must be one the game can be in: every card
+// ID at most once, every figure on a square of the board and no two figures
+// on one square.
+func ParseNotation(s string, deck *DeckDefinition) (*Game, error) {
+	fields := strings.Split(strings.TrimSpace(s), " ")
+	if len(fields) != 10 {
+		return nil, invalidPosition("%d fields, want 10", len(fields))
+	}
+	if fields[0] != notationVersion {
+		return nil, invalidPosition("unknown notation %q", fields[0])
+	}
+
+	g := &Game{Deck: &Deck{}}
+	var err error
+	if g.DeckVersion, err = url.QueryUnescape(fields[1]); err != nil {
+		return nil, invalidPosition("deck version: %v", err)
+	}
+	if g.DeckVersion != deck.Version {
+		return nil, invalidPosition("dealt from deck %q, not %q", g.DeckVersion, deck.Version)
+	}
+
+	counters, err := parseInts(fields[2], "/")
+	if err != nil || len(counters) != 4 {
+		return nil, invalidPosition("counters %q", fields[2])
+	}
+	g.Round, g.Dealer, g.Turn, g.Passes = counters[0], counters[1], counters[2], counters[3]
+
+	switch g.Phase = Phase(fields[3]); g.Phase {
+	case PhasePlay, PhaseExchange:
+	default:
+		return nil, invalidPosition("unknown phase %q", fields[3])
+	}
+
+	for _, player := range strings.Split(fields[4], ";") {
+		p, err := parsePlayer(player, deck)
+		if err != nil {
+			return nil, err
+		}
+		g.Players = append(g.Players, p)
+	}
+	if g.Deck.Cards, err = parseCards(fields[5], deck); err != nil {
+		return nil, err
+	}
+	if g.Discard, err = parseCards(fields[6], deck); err != nil {
+		return nil, err
+	}
+	if fields[7] != "-" {
+		for _, id := range strings.Split(fields[7], ",") {
+			winner, err := url.QueryUnescape(id)
+			if err != nil || g.seatOf(winner) < 0 {
+				return nil, invalidPosition("unknown winner %q", id)
+			}
+			g.Winners = append(g.Winners, winner)
+		}
+	}
+	if g.Rules, err = parseRules(fields[8]); err != nil {
+		return nil, err
+	}
+	if g.Version, err = strconv.ParseUint(fields[9], 10, 64); err != nil {
+		return nil, invalidPosition("version %q", fields[9])
+	}
+
+	if err := g.checkPosition(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// checkPosition checks what the fields of a parsed position can not check
+// on their own
+func (g *Game) checkPosition() error {
+	if len(g.Players) < MinPlayers || len(g.Players) > MaxPlayers {
+		return invalidPosition("%d players, want %d to %d", len(g.Players), MinPlayers, MaxPlayers)
+	}
+	teams := slices.ContainsFunc(g.Players, func(p *PlayerInGame) bool { return p.Partner != "" })
+	if teams && len(g.Players) != teamSeats {
+		return invalidPosition("team play with %d players, want %d", len(g.Players), teamSeats)
+	}
+	if g.Phase == PhaseExchange && !teams {
+		return invalidPosition("card exchange without partners")
+	}
+	if g.Turn < 0 || g.Turn >= len(g.Players) || g.Dealer < 0 || g.Dealer >= len(g.Players) {
+		return invalidPosition("turn %d or dealer %d is not a seat", g.Turn, g.Dealer)
+	}
+	if g.Round < 0 || g.Passes < 0 {
+		return invalidPosition("negative round or passes")
+	}
+
+	ids := make(map[int]bool)
+	for _, c := range g.allCards() {
+		if ids[c.ID] {
+			return invalidPosition("card %d appears twice", c.ID)
+		}
+		ids[c.ID] = true
+	}
+
+	seen := make(map[string]bool)
+	track := make(map[int]bool)
+	for seat, p := range g.Players {
+		if seen[p.ID] {
+			return invalidPosition("player %q is seated twice", p.ID)
+		}
+		seen[p.ID] = true
+		if partner := g.partnerSeat(seat); p.Partner != "" && (partner < 0 || partner == seat || g.Players[partner].Partner != p.ID) {
+			return invalidPosition("%q and %q are not partners", p.ID, p.Partner)
+		}
+		// Partners sit opposite each other
+		if teams && g.partnerSeat(seat) != (seat+teamSeats/2)%teamSeats {
+			return invalidPosition("%q does not sit opposite a partner", p.ID)
+		}
+
+		home := make(map[int]bool)
+		for _, f := range p.Figures {
+			switch f.Status {
+			case FigureOnTrack:
+				if f.Position >= g.trackLength() || track[f.Position] {
+					return invalidPosition("figure %d of %q on track square %d", f.ID, p.ID, f.Position)
+				}
+				track[f.Position] = true
+			case FigureInHome:
+				if f.Position >= HomeSize || home[f.Position] {
+					return invalidPosition("figure %d of %q on home square %d", f.ID, p.ID, f.Position)
+				}
+				home[f.Position] = true
+			}
+		}
+	}
+	return nil
+}
+
+func parsePlayer(s string, deck *DeckDefinition) (*PlayerInGame, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 6 {
+		return nil, invalidPosition("player %q has %d fields, want 6", s, len(fields))
+	}
+
+	p := &PlayerInGame{}
+	for i, dst := range []*string{&p.ID, &p.Name, &p.Partner} {
+		v, err := url.QueryUnescape(fields[i])
+		if err != nil {
+			return nil, invalidPosition("player %q: %v", s, err)
+		}
+		*dst = v
+	}
+	if p.ID == "" {
+		return nil, invalidPosition("player %q has no ID", s)
+	}
+
+	var err error
+	if p.Figures, err = parseFigures(fields[3]); err != nil {
+		return nil, err
+	}
+	if p.Hand, err = parseCards(fields[4], deck); err != nil {
+		return nil, err
+	}
+	if fields[5] != "-" {
+		c, err := parseCard(fields[5], deck)
+		if err != nil {
+			return nil, err
+		}
+		p.Exchange = &c
+	}
+	return p, nil
+}
+
+func formatFigures(figures []Figure) string {
+	s := make([]string, len(figures))
+	for i, f := range figures {
+		switch f.Status {
+		case FigureOnTrack:
+			s[i] = "t" + strconv.Itoa(f.Position)
+		case FigureInHome:
+			s[i] = "h" + strconv.Itoa(f.Position)
+		default:
+			s[i] = "s"
+		}
+	}
+	return strings.Join(s, ".")
+}
+
+func parseFigures(s string) ([]Figure, error) {
+	tokens := strings.Split(s, ".")
+	if len(tokens) != FiguresPerPlayer {
+		return nil, invalidPosition("%d figures in %q, want %d", len(tokens), s, FiguresPerPlayer)
+	}
+
+	figures := make([]Figure, len(tokens))
+	for id, t := range tokens {
+		f := Figure{ID: id, Status: FigureInStart}
+		if t != "s" {
+			if t == "" {
+				return nil, invalidPosition("empty figure in %q", s)
+			}
+			switch t[0] {
+			case 't':
+				f.Status = FigureOnTrack
+			case 'h':
+				f.Status = FigureInHome
+			default:
+				return nil, invalidPosition("figure %q", t)
+			}
+			pos, err := strconv.Atoi(t[1:])
+			if err != nil || pos < 0 {
+				return nil, invalidPosition("figure %q", t)
+			}
+			f.Position = pos
+		}
+		figures[id] = f
+	}
+	return figures, nil
+}
+
+func formatCard(c Card) string {
+	return c.Type + strconv.Itoa(c.ID)
+}
+
+func formatCards(cards []Card) string {
+	if len(cards) == 0 {
+		return "-"
+	}
+	s := make([]string, len(cards))
+	for i, c := range cards {
+		s[i] = formatCard(c)
+	}
+	return strings.Join(s, ".")
+}
+
+// parseCard reads a card type followed by the card's ID
+func parseCard(s string, deck *DeckDefinition) (Card, error) {
+	i := strings.IndexAny(s, "0123456789")
+	if i <= 0 {
+		return Card{}, invalidPosition("card %q", s)
+	}
+	id, err := strconv.Atoi(s[i:])
+	if err != nil {
+		return Card{}, invalidPosition("card %q", s)
+	}
+	def, ok := deck.Card(s[:i])
+	if !ok {
+		return Card{}, invalidPosition("no card %q in deck %s", s[:i], deck.Version)
+	}
+	return Card{ID: id, Type: def.Type, Moves: slices.Clone(def.Moves), Effects: slices.Clone(def.Effects)}, nil
+}
+
+func parseCards(s string, deck *DeckDefinition) ([]Card, error) {
+	if s == "-" {
+		return nil, nil
+	}
+	var cards []Card
+	for _, t := range strings.Split(s, ".") {
+		c, err := parseCard(t, deck)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, c)
+	}
+	return cards, nil
+}
+
+func formatRules(r Rules) string {
+	var s []string
+	if r.MandatoryCapture {
+		s = append(s, "capture")
+	}
+	if r.AllowPass {
+		s = append(s, "pass")
+	}
+	if r.UnprotectedStart {
+		s = append(s, "unprotected")
+	}
+	if len(r.HandSizes) > 0 {
+		sizes := make([]string, len(r.HandSizes))
+		for i, n := range r.HandSizes {
+			sizes[i] = strconv.Itoa(n)
+		}
+		s = append(s, "hands="+strings.Join(sizes, "."))
+	}
+	if r.TurnTimerSeconds > 0 {
+		s = append(s, "timer="+strconv.Itoa(r.TurnTimerSeconds))
+	}
+	if len(s) == 0 {
+		return "-"
+	}
+	return strings.Join(s, ",")
+}
+
+func parseRules(s string) (Rules, error) {
+	var r Rules
+	if s == "-" {
+		return r, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		key, value, _ := strings.Cut(item, "=")
+		var err error
+		switch key {
+		case "capture":
+			r.MandatoryCapture = true
+		case "pass":
+			r.AllowPass = true
+		case "unprotected":
+			r.UnprotectedStart = true
+		case "hands":
+			r.HandSizes, err = parseInts(value, ".")
+		case "timer":
+			r.TurnTimerSeconds, err = strconv.Atoi(value)
+		default:
+			return Rules{}, invalidPosition("unknown rule %q", item)
+		}
+		if err != nil {
+			return Rules{}, invalidPosition("rule %q", item)
+		}
+	}
+	if err := r.Validate(); err != nil {
+		return Rules{}, fmt.Errorf("%w: %w", ErrInvalidPosition, err)
+	}
+	return r, nil
+}
+
+func parseInts(s, sep string) ([]int, error) {
+	var ints []int
+	for _, t := range strings.Split(s, sep) {
+		n, err := strconv.Atoi(t)
+		if err != nil {
+			return nil, err
+		}
+		ints = append(ints, n)
+	}
+	return ints, nil
+}
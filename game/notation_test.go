@@ -0,0 +1,113 @@
+package game
+
+import (
+	"errors"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var notationDeck = &DeckDefinition{Version: "test", Cards: []CardDefinition{
+	{Type: "two", Count: 2, Moves: []int{2}, Effects: []Effect{EffectMove}},
+	{Type: "king", Count: 2, Moves: []int{13}, Effects: []Effect{EffectMove, EffectStart}},
+	{Type: "jack", Count: 1, Effects: []Effect{EffectSwap}},
+}}
+
+func TestNotationRoundTrip(t *testing.T) {
+	d, err := StandardDeck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	players := []PlayerInGame{{ID: "a", Name: "Alice Smith"}, {ID: "b,c", Name: "Bob;"}, {ID: "c", Name: ""}, {ID: "d", Name: "Dörte"}}
+	for seat := range players {
+		players[seat].Partner = players[(seat+2)%4].ID
+	}
+	g := NewGame(players, d, Rules{AllowPass: true, HandSizes: []int{4, 3}, TurnTimerSeconds: 30}, rand.New(rand.NewPCG(1, 2)))
+	onTrack(g, 0, 1, 12)
+	inHome(g, 1, 0, 3)
+	g.Discard = append(g.Discard, g.Deck.Cards[:3]...)
+	g.Deck.Cards = g.Deck.Cards[3:]
+
+	s := g.Notation()
+	parsed, err := ParseNotation(s, d)
+	if err != nil {
+		t.Fatalf("ParseNotation(%q): %v", s, err)
+	}
+	if again := parsed.Notation(); again != s {
+		t.Fatalf("notation changed on the round trip:\n%s\n%s", s, again)
+	}
+
+	for seat, p := range g.Players {
+		if !reflect.DeepEqual(p, parsed.Players[seat]) {
+			t.Errorf("seat %d: %+v, want %+v", seat, parsed.Players[seat], p)
+		}
+	}
+	if !reflect.DeepEqual(g.Deck, parsed.Deck) || !reflect.DeepEqual(g.Discard, parsed.Discard) ||
+		!reflect.DeepEqual(g.Rules, parsed.Rules) || g.Version != parsed.Version || g.Phase != parsed.Phase {
+		t.Error("parsed game differs from the original")
+	}
+}
+
+func TestGameStartsFromNotation(t *testing.T) {
+	g, err := ParseNotation("v1 test 1/1/0/0 play a,Alice,,t5.s.s.s,two1,-;b,Bob,,t7.s.s.s,king2,- - - - - 0", notationDeck)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.PlayCard("a", Play{CardID: 1, Effect: EffectMove, FigureID: 0, Moves: 2}); err != nil {
+		t.Fatal(err)
+	}
+	want := "v1 test 1/1/1/0 play a,Alice,,t7.s.s.s,-,-;b,Bob,,s.s.s.s,king2,- - two1 - - 1"
+	if got := g.Notation(); got != want {
+		t.Errorf("after the capture:\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParseNotationRejectsBadPositions(t *testing.T) {
+	const valid = "v1 test 1/1/0/0 play a,,,t5.s.s.s,two1,-;b,,,s.s.s.s,-,- king2 - - - 0"
+	if _, err := ParseNotation(valid, notationDeck); err != nil {
+		t.Fatalf("valid position: %v", err)
+	}
+	const teams = "v1 test 1/3/0/0 exchange a,,c,t5.s.s.s,two1,-;b,,d,s.s.s.s,-,-;c,,a,s.s.s.s,-,-;d,,b,s.s.s.s,king2,- - - - - 0"
+	if _, err := ParseNotation(teams, notationDeck); err != nil {
+		t.Fatalf("valid team position: %v", err)
+	}
+
+	tests := map[string][2]string{
+		"field missing":      {" 0", ""},
+		"other notation":     {"v1 ", "v9 "},
+		"other deck":         {" test ", " standard-1 "},
+		"turn not a seat":    {"1/1/0/0", "1/1/2/0"},
+		"unknown phase":      {" play ", " deal "},
+		"one player":         {";b,,,s.s.s.s,-,-", ""},
+		"same player twice":  {"b,,,", "a,,,"},
+		"three figures":      {"t5.s.s.s", "t5.s.s"},
+		"unknown figure":     {"t5.s.s.s", "x5.s.s.s"},
+		"off the track":      {"t5.s.s.s", "t32.s.s.s"},
+		"two on one square":  {"t5.s.s.s", "t5.t5.s.s"},
+		"card twice":         {"king2", "two1"},
+		"unknown card":       {"king2", "queen2"},
+		"no card ID":         {"king2", "king"},
+		"unknown winner":     {"king2 - -", "king2 - x"},
+		"unknown rule":       {" - 0", " double 0"},
+		"invalid rule":       {" - 0", " timer=1 0"},
+		"one-sided partners": {"a,,,", "a,,b,"},
+		"five players":       {";b,,,s.s.s.s,-,-", ";b,,,s.s.s.s,-,-;c,,,s.s.s.s,-,-;d,,,s.s.s.s,-,-;e,,,s.s.s.s,-,-"},
+		"two partners":       {"a,,,t5.s.s.s,two1,-;b,,,", "a,,b,t5.s.s.s,two1,-;b,,a,"},
+		"partners side by side": {"a,,,t5.s.s.s,two1,-;b,,,s.s.s.s,-,-",
+			"a,,b,t5.s.s.s,two1,-;b,,a,s.s.s.s,-,-;c,,d,s.s.s.s,-,-;d,,c,s.s.s.s,-,-"},
+		"exchange without partners": {" play ", " exchange "},
+	}
+	for name, edit := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := strings.Replace(valid, edit[0], edit[1], 1)
+			if s == valid {
+				t.Fatalf("%q not found", edit[0])
+			}
+			if _, err := ParseNotation(s, notationDeck); !errors.Is(err, ErrInvalidPosition) {
+				t.Errorf("ParseNotation(%q) = %v, want ErrInvalidPosition", s, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var (
+	ErrNotInGame     = errors.New("player is not in the game")
+	ErrNotYourTurn   = errors.New("not your turn")
+	ErrGameOver      = errors.New("the game is over")
+	ErrCardNotInHand = errors.New("card is not in your hand")
+	ErrInvalidPlay   = errors.New("invalid play")
+)
+
+// Play is what a player does with one card of their hand. Which fields are
+// needed depends on Effect:
+//
+//   - move, backward: FigureID and Moves
+//   - start: FigureID
+//   - split: Split, the steps add up to the card's move value
+//   - swap: FigureID and Target
+//
+// A joker is played with As set to the card it stands in for and Effect set
+// to one of that card's effects.
+type Play struct {
+	CardID   int         `json:"cardID"`
+	Effect   Effect      `json:"effect"`
+	As       string      `json:"as,omitempty"`
+	FigureID int         `json:"figureID"`
+	Moves    int         `json:"moves,omitempty"`
+	Split    []SplitStep `json:"split,omitempty"`
+	Target   *FigureRef  `json:"target,omitempty"`
+}
+
+// SplitStep moves one figure part of a split card's value
+type SplitStep struct {
+	FigureID int `json:"figureID"`
+	Steps    int `json:"steps"`
+}
+
+// FigureRef names a figure of any player
+type FigureRef struct {
+	PlayerID string `json:"playerID"`
+	FigureID int    `json:"figureID"`
+}
+
+func invalidPlay(format string, args ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{ErrInvalidPlay}, args...)...)
+}
+
+// PlayCard plays a card of the current player. The game is only changed if
+// the whole play is valid; the card then goes onto the discard pile and the
+// turn passes on.
+func (g *Game) PlayCard(playerID string, play Play) error {
+	seat := g.seatOf(playerID)
+	if seat < 0 {
+		return ErrNotInGame
+	}
+	if len(g.Winners) > 0 {
+		return ErrGameOver
+	}
+	if g.Phase == PhaseExchange {
+		return ErrExchangePending
+	}
+	if seat != g.Turn {
+		return ErrNotYourTurn
+	}
+
+	hand := g.Players[seat].Hand
+	i := slices.IndexFunc(hand, func(c Card) bool { return c.ID == play.CardID })
+	if i < 0 {
+		return ErrCardNotInHand
+	}
+
+	card, err := resolveCard(hand[i], play.As, g.cardKinds())
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(card.Effects, play.Effect) {
+		return invalidPlay("%s can not be played as %s", card.Type, play.Effect)
+	}
+
+	controlled := g.controlledSeat(seat)
+	next := g.Clone()
+	if err := next.applyEffect(controlled, card, play); err != nil {
+		return err
+	}
+	if g.Rules.MandatoryCapture && !g.captures(next, controlled) && g.canCapture(seat) {
+		return invalidPlay("a figure must be captured")
+	}
+	next.checkWinners()
+
+	p := next.Players[seat]
+	next.Discard = append(next.Discard, p.Hand[i])
+	p.Hand = slices.Delete(p.Hand, i, i+1)
+	next.Turn = (next.Turn + 1) % len(next.Players)
+	next.Passes = 0
+	next.Version++
+
+	*g = *next
+	return nil
+}
+
+// canCapture reports whether the player at seat has a capturing play
+func (g *Game) canCapture(seat int) bool {
+	return slices.ContainsFunc(g.legalMoves(seat, false), func(m Move) bool { return m.Capture })
+}
+
+// resolveCard returns the card a joker stands in for, or the card itself.
+// The stand-in is looked up among the kinds of cards of this game, see
+// cardKinds, so it follows the deck definition the game was dealt from.
+func resolveCard(card Card, as string, kinds []Card) (Card, error) {
+	if !slices.Contains(card.Effects, EffectJoker) {
+		if as != "" {
+			return Card{}, invalidPlay("only a joker can stand in for another card")
+		}
+		return card, nil
+	}
+	if as == "" {
+		return Card{}, invalidPlay("a joker needs the card it stands in for")
+	}
+
+	for _, c := range kinds {
+		if c.Type == as {
+			return Card{ID: card.ID, Type: c.Type, Moves: c.Moves, Effects: c.Effects}, nil
+		}
+	}
+	return Card{}, invalidPlay("no card %q in this deck", as)
+}
+
+// allCards returns the cards in the deck, the hands, the exchange and the
+// discard pile
+func (g *Game) allCards() []Card {
+	var cards []Card
+	if g.Deck != nil {
+		cards = append(cards, g.Deck.Cards...)
+	}
+	for _, p := range g.Players {
+		cards = append(cards, p.Hand...)
+		if p.Exchange != nil {
+			cards = append(cards, *p.Exchange)
+		}
+	}
+	return append(cards, g.Discard...)
+}
+
+// applyEffect changes g, which must be a copy, as play says. seat is the
+// player whose figures are moved.
+func (g *Game) applyEffect(seat int, card Card, play Play) error {
+	switch play.Effect {
+	case EffectMove, EffectBackward:
+		if !slices.Contains(card.Moves, play.Moves) {
+			return invalidPlay("%s does not move %d", card.Type, play.Moves)
+		}
+		steps := play.Moves
+		if play.Effect == EffectBackward {
+			steps = -steps
+		}
+		return g.moveFigure(seat, play.FigureID, steps)
+
+	case EffectStart:
+		return g.enterTrack(seat, play.FigureID)
+
+	case EffectSplit:
+		if len(play.Split) == 0 {
+			return invalidPlay("split needs at least one step")
+		}
+		// Every figure moves once, by at most the card's value, so the sum
+		// can not overflow
+		total := 0
+		moved := make(map[int]bool)
+		for _, s := range play.Split {
+			if s.Steps <= 0 || s.Steps > card.Moves[0] {
+				return invalidPlay("split steps must be between 1 and %d", card.Moves[0])
+			}
+			if moved[s.FigureID] {
+				return invalidPlay("figure %d appears twice in the split", s.FigureID)
+			}
+			moved[s.FigureID] = true
+			total += s.Steps
+		}
+		if total != card.Moves[0] {
+			return invalidPlay("split steps add up to %d, not %d", total, card.Moves[0])
+		}
+		for _, s := range play.Split {
+			if err := g.moveFigure(seat, s.FigureID, s.Steps); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case EffectSwap:
+		if play.Target == nil {
+			return invalidPlay("swap needs a target figure")
+		}
+		own, err := g.figure(seat, play.FigureID)
+		if err != nil {
+			return err
+		}
+		targetSeat := g.seatOf(play.Target.PlayerID)
+		if targetSeat < 0 || targetSeat == seat {
+			return invalidPlay("swap needs a figure of another player")
+		}
+		other, err := g.figure(targetSeat, play.Target.FigureID)
+		if err != nil {
+			return err
+		}
+		if own.Status != FigureOnTrack || other.Status != FigureOnTrack {
+			return invalidPlay("only figures on the track can be swapped")
+		}
+		if g.blocking(own.Position) || g.blocking(other.Position) {
+			return invalidPlay("a figure on its start square can not be swapped")
+		}
+		own.Position, other.Position = other.Position, own.Position
+		return nil
+	}
+
+	return invalidPlay("unknown effect %q", play.Effect)
+}
+
+// figure returns the figure of the player at seat
+func (g *Game) figure(seat, figureID int) (*Figure, error) {
+	figures := g.Players[seat].Figures
+	if figureID < 0 || figureID >= len(figures) {
+		return nil, invalidPlay("no figure %d", figureID)
+	}
+	return &figures[figureID], nil
+}
@@ -0,0 +1,208 @@
+package game
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// newTestGame seats two players without dealing. Figures and hands are set
+// up by the tests.
+func newTestGame(t *testing.T) *Game {
+	t.Helper()
+
+	g := NewGame([]PlayerInGame{{ID: "a", Name: "Alice"}, {ID: "b", Name: "Bob"}}, &DeckDefinition{Version: "test"}, Rules{}, rand.New(rand.NewPCG(1, 2)))
+	g.Deck = &Deck{}
+	return g
+}
+
+func card(id int, cardType string, moves []int, effects ...Effect) Card {
+	return Card{ID: id, Type: cardType, Moves: moves, Effects: effects}
+}
+
+func onTrack(g *Game, seat, figureID, position int) {
+	g.Players[seat].Figures[figureID] = Figure{ID: figureID, Status: FigureOnTrack, Position: position}
+}
+
+func TestPlayCardEffects(t *testing.T) {
+	tests := []struct {
+		name  string
+		card  Card
+		play  Play
+		setup func(g *Game)
+		check func(t *testing.T, g *Game)
+	}{
+		{
+			name: "move",
+			card: card(1, "ace", []int{1, 11}, EffectMove, EffectStart),
+			play: Play{CardID: 1, Effect: EffectMove, FigureID: 0, Moves: 11},
+			setup: func(g *Game) {
+				onTrack(g, 0, 0, 3)
+			},
+			check: func(t *testing.T, g *Game) {
+				if pos := g.Players[0].Figures[0].Position; pos != 14 {
+					t.Errorf("position %d, want 14", pos)
+				}
+			},
+		},
+		{
+			name: "backward wraps around the track",
+			card: card(1, "four", []int{4}, EffectMove, EffectBackward),
+			play: Play{CardID: 1, Effect: EffectBackward, FigureID: 0, Moves: 4},
+			setup: func(g *Game) {
+				onTrack(g, 0, 0, 1)
+			},
+			check: func(t *testing.T, g *Game) {
+				if pos := g.Players[0].Figures[0].Position; pos != 29 {
+					t.Errorf("position %d, want 29", pos)
+				}
+			},
+		},
+		{
+			name: "start",
+			card: card(1, "king", []int{13}, EffectMove, EffectStart),
+			play: Play{CardID: 1, Effect: EffectStart, FigureID: 2},
+			check: func(t *testing.T, g *Game) {
+				f := g.Players[0].Figures[2]
+				if f.Status != FigureOnTrack || f.Position != 0 {
+					t.Errorf("figure %+v, want on track at 0", f)
+				}
+			},
+		},
+		{
+			name: "split",
+			card: card(1, "seven", []int{7}, EffectSplit),
+			play: Play{CardID: 1, Effect: EffectSplit, Split: []SplitStep{{FigureID: 0, Steps: 3}, {FigureID: 1, Steps: 4}}},
+			setup: func(g *Game) {
+				onTrack(g, 0, 0, 0)
+				onTrack(g, 0, 1, 10)
+			},
+			check: func(t *testing.T, g *Game) {
+				if a, b := g.Players[0].Figures[0].Position, g.Players[0].Figures[1].Position; a != 3 || b != 14 {
+					t.Errorf("positions %d and %d, want 3 and 14", a, b)
+				}
+			},
+		},
+		{
+			name: "swap",
+			card: card(1, "jack", nil, EffectSwap),
+			play: Play{CardID: 1, Effect: EffectSwap, FigureID: 0, Target: &FigureRef{PlayerID: "b", FigureID: 3}},
+			setup: func(g *Game) {
+				onTrack(g, 0, 0, 5)
+				onTrack(g, 1, 3, 20)
+			},
+			check: func(t *testing.T, g *Game) {
+				if a, b := g.Players[0].Figures[0].Position, g.Players[1].Figures[3].Position; a != 20 || b != 5 {
+					t.Errorf("positions %d and %d, want 20 and 5", a, b)
+				}
+			},
+		},
+		{
+			name: "joker",
+			card: card(1, "joker", nil, EffectJoker),
+			play: Play{CardID: 1, Effect: EffectMove, As: "five", FigureID: 0, Moves: 5},
+			setup: func(g *Game) {
+				onTrack(g, 0, 0, 0)
+				g.Discard = []Card{card(9, "five", []int{5}, EffectMove)}
+			},
+			check: func(t *testing.T, g *Game) {
+				if pos := g.Players[0].Figures[0].Position; pos != 5 {
+					t.Errorf("position %d, want 5", pos)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t)
+			g.Players[0].Hand = []Card{tt.card}
+			if tt.setup != nil {
+				tt.setup(g)
+			}
+
+			version := g.Version
+			if err := g.PlayCard("a", tt.play); err != nil {
+				t.Fatalf("PlayCard: %v", err)
+			}
+			tt.check(t, g)
+
+			if len(g.Players[0].Hand) != 0 {
+				t.Error("card is still in the hand")
+			}
+			if top := g.Discard[len(g.Discard)-1]; top.ID != tt.card.ID {
+				t.Errorf("top of discard pile is card %d, want %d", top.ID, tt.card.ID)
+			}
+			if g.Turn != 1 || g.Version != version+1 {
+				t.Errorf("Turn = %d, Version = %d, want 1 and %d", g.Turn, g.Version, version+1)
+			}
+		})
+	}
+}
+
+func TestPlayCardRejectsInvalidPlays(t *testing.T) {
+	tests := []struct {
+		name     string
+		playerID string
+		card     Card
+		play     Play
+		want     error
+	}{
+		{"not in game", "x", card(1, "two", []int{2}, EffectMove), Play{CardID: 1, Effect: EffectMove, Moves: 2}, ErrNotInGame},
+		{"not your turn", "b", card(1, "two", []int{2}, EffectMove), Play{CardID: 1, Effect: EffectMove, Moves: 2}, ErrNotYourTurn},
+		{"card not in hand", "a", card(1, "two", []int{2}, EffectMove), Play{CardID: 2, Effect: EffectMove, Moves: 2}, ErrCardNotInHand},
+		{"effect not on card", "a", card(1, "two", []int{2}, EffectMove), Play{CardID: 1, Effect: EffectSwap}, ErrInvalidPlay},
+		{"wrong move value", "a", card(1, "two", []int{2}, EffectMove), Play{CardID: 1, Effect: EffectMove, Moves: 3}, ErrInvalidPlay},
+		{"figure in start", "a", card(1, "two", []int{2}, EffectMove), Play{CardID: 1, Effect: EffectMove, FigureID: 1, Moves: 2}, ErrInvalidPlay},
+		{"no such figure", "a", card(1, "two", []int{2}, EffectMove), Play{CardID: 1, Effect: EffectMove, FigureID: 7, Moves: 2}, ErrInvalidPlay},
+		{"start on track", "a", card(1, "ace", []int{1}, EffectMove, EffectStart), Play{CardID: 1, Effect: EffectStart, FigureID: 0}, ErrInvalidPlay},
+		{"split sum", "a", card(1, "seven", []int{7}, EffectSplit), Play{CardID: 1, Effect: EffectSplit, Split: []SplitStep{{FigureID: 0, Steps: 6}}}, ErrInvalidPlay},
+		{"split overflow", "a", card(1, "seven", []int{7}, EffectSplit), Play{CardID: 1, Effect: EffectSplit, Split: []SplitStep{{FigureID: 3, Steps: math.MaxInt}, {FigureID: 3, Steps: math.MaxInt}, {FigureID: 0, Steps: 9}}}, ErrInvalidPlay},
+		{"split figure twice", "a", card(1, "seven", []int{7}, EffectSplit), Play{CardID: 1, Effect: EffectSplit, Split: []SplitStep{{FigureID: 0, Steps: 3}, {FigureID: 0, Steps: 4}}}, ErrInvalidPlay},
+		{"split into start", "a", card(1, "seven", []int{7}, EffectSplit), Play{CardID: 1, Effect: EffectSplit, Split: []SplitStep{{FigureID: 0, Steps: 6}, {FigureID: 1, Steps: 1}}}, ErrInvalidPlay},
+		{"swap own figure", "a", card(1, "jack", nil, EffectSwap), Play{CardID: 1, Effect: EffectSwap, Target: &FigureRef{PlayerID: "a", FigureID: 1}}, ErrInvalidPlay},
+		{"swap figure in start", "a", card(1, "jack", nil, EffectSwap), Play{CardID: 1, Effect: EffectSwap, Target: &FigureRef{PlayerID: "b", FigureID: 0}}, ErrInvalidPlay},
+		{"joker without stand-in", "a", card(1, "joker", nil, EffectJoker), Play{CardID: 1, Effect: EffectMove, Moves: 2}, ErrInvalidPlay},
+		{"joker as unknown card", "a", card(1, "joker", nil, EffectJoker), Play{CardID: 1, Effect: EffectMove, As: "eleven", Moves: 11}, ErrInvalidPlay},
+		{"stand-in for a normal card", "a", card(1, "two", []int{2}, EffectMove), Play{CardID: 1, Effect: EffectMove, As: "two", Moves: 2}, ErrInvalidPlay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGame(t)
+			g.Players[0].Hand = []Card{tt.card}
+			onTrack(g, 0, 0, 0)
+			onTrack(g, 0, 2, 30)
+			g.Players[0].Figures[3] = Figure{ID: 3, Status: FigureInHome, Position: 1}
+			before := g.Notation()
+
+			err := g.PlayCard(tt.playerID, tt.play)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+
+			// A rejected play leaves the game as it was
+			if after := g.Notation(); after != before {
+				t.Errorf("game changed by a rejected play: %s, was %s", after, before)
+			}
+		})
+	}
+}
+
+func TestViewHidesOtherHands(t *testing.T) {
+	g := newTestGame(t)
+	g.Players[0].Hand = []Card{card(1, "two", []int{2}, EffectMove)}
+	g.Players[1].Hand = []Card{card(2, "three", []int{3}, EffectMove), card(3, "four", []int{4}, EffectMove)}
+
+	v := g.ViewFor("a")
+	if len(v.Hand) != 1 || v.Hand[0].ID != 1 {
+		t.Errorf("hand %+v, want only card 1", v.Hand)
+	}
+	if v.Players[1].HandSize != 2 {
+		t.Errorf("HandSize = %d, want 2", v.Players[1].HandSize)
+	}
+	if v.Turn != "a" {
+		t.Errorf("Turn = %q, want a", v.Turn)
+	}
+}
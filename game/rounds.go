@@ -0,0 +1,125 @@
+package game
+
+import (
+	"math/rand/v2"
+	"slices"
+)
+
+// A game is played in rounds. The dealer deals HandSizes[0] of the rules to
+// every player in the first round, HandSizes[1] in the second and so on, starting
+// over after the last size. The player after the dealer begins, and the
+// dealer moves on by one seat every round. When the deck runs out the
+// discard pile is shuffled into a new deck.
+
+// DefaultHandSizes is the usual sequence of hand sizes
+var DefaultHandSizes = []int{6, 5, 4, 3, 2}
+
+// maxIdleRounds is how many rounds in a row Advance deals without a single
+// legal play before it gives up. Only a deck without usable cards gets there.
+const maxIdleRounds = 20
+
+// Progress is what happened while the game moved on to a player that can
+// play
+type Progress struct {
+	Skips        []Skip
+	RoundStarted bool
+}
+
+// random returns the game's random source. Restored games get a new one.
+func (g *Game) random() *rand.Rand {
+	if g.rng == nil {
+		g.rng = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	return g.rng
+}
+
+// handSize is the number of cards dealt in the current round
+func (g *Game) handSize() int {
+	sizes := g.Rules.handSizes()
+	return sizes[max(g.Round-1, 0)%len(sizes)]
+}
+
+// dealRound starts the next round: the dealer moves on, every player gets
+// the round's hand size and the player after the dealer begins. It reports
+// false if there was no card left to deal.
+func (g *Game) dealRound() bool {
+	if g.Round > 0 {
+		g.Dealer = (g.Dealer + 1) % len(g.Players)
+	}
+	g.Round++
+	g.Passes = 0
+	g.Version++
+
+	dealt := 0
+	for range g.handSize() {
+		for i := range g.Players {
+			p := g.Players[(g.Dealer+1+i)%len(g.Players)]
+			if c, ok := g.draw(); ok {
+				p.Hand = append(p.Hand, c)
+				dealt++
+			}
+		}
+	}
+
+	g.Turn = (g.Dealer + 1) % len(g.Players)
+	g.startRound()
+	return dealt > 0
+}
+
+// draw takes the top card of the deck, reshuffling the discard pile into the
+// deck when it is empty
+func (g *Game) draw() (Card, bool) {
+	if g.Deck == nil {
+		g.Deck = &Deck{}
+	}
+	if len(g.Deck.Cards) == 0 {
+		if len(g.Discard) == 0 {
+			return Card{}, false
+		}
+		g.Deck.Cards, g.Discard = g.Discard, nil
+		g.random().Shuffle(len(g.Deck.Cards), func(i, j int) {
+			g.Deck.Cards[i], g.Deck.Cards[j] = g.Deck.Cards[j], g.Deck.Cards[i]
+		})
+	}
+
+	last := len(g.Deck.Cards) - 1
+	c := g.Deck.Cards[last]
+	g.Deck.Cards = slices.Delete(g.Deck.Cards, last, last+1)
+	return c, true
+}
+
+// Advance passes the turn on while the current player can not play. A
+// player with cards but no legal play discards the whole hand, or passes
+// if the rules allow it; a player without cards is passed over. Once every
+// player with cards passed in a row, all hands are discarded. Once every
+// hand is empty the next round is dealt. Nothing happens during the card
+// exchange or after the game is won.
+func (g *Game) Advance() Progress {
+	var progress Progress
+	idle := 0
+	for len(g.Winners) == 0 && g.Phase != PhaseExchange {
+		if g.handsEmpty() {
+			if idle >= maxIdleRounds || !g.dealRound() {
+				break
+			}
+			idle++
+			progress.RoundStarted = true
+			continue
+		}
+		if g.Passes > 0 && g.Passes >= g.playersWithCards() {
+			progress.Skips = append(progress.Skips, g.discardHands()...)
+			continue
+		}
+
+		if len(g.CurrentPlayer().Hand) > 0 {
+			if len(g.legalMoves(g.Turn, true)) > 0 {
+				break
+			}
+			progress.Skips = append(progress.Skips, g.skipTurn())
+			continue
+		}
+		g.Turn = (g.Turn + 1) % len(g.Players)
+		g.Version++
+	}
+	return progress
+}
@@ -0,0 +1,112 @@
+package game
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+func TestRoundsDealShrinkingHands(t *testing.T) {
+	d, err := StandardDeck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGame([]PlayerInGame{{ID: "a"}, {ID: "b"}, {ID: "c"}}, d, Rules{}, rand.New(rand.NewPCG(1, 2)))
+
+	for round, size := range []int{6, 5, 4, 3, 2, 6} {
+		if g.Round != round+1 {
+			t.Fatalf("Round = %d, want %d", g.Round, round+1)
+		}
+		for _, p := range g.Players {
+			if len(p.Hand) != size {
+				t.Fatalf("round %d: %s has %d cards, want %d", g.Round, p.ID, len(p.Hand), size)
+			}
+		}
+
+		// The player after the dealer begins, the dealer moves on every round
+		if dealer := (round + 2) % 3; g.Dealer != dealer || g.Turn != (dealer+1)%3 {
+			t.Fatalf("round %d: Dealer = %d, Turn = %d, want dealer %d", g.Round, g.Dealer, g.Turn, dealer)
+		}
+
+		for _, p := range g.Players {
+			g.Discard = append(g.Discard, p.Hand...)
+			p.Hand = nil
+		}
+		g.dealRound()
+	}
+}
+
+func TestDrawReshufflesTheDiscardPile(t *testing.T) {
+	g := newTestGame(t)
+	g.Deck.Cards = []Card{card(1, "two", []int{2}, EffectMove)}
+	g.Discard = []Card{card(2, "three", []int{3}, EffectMove), card(3, "four", []int{4}, EffectMove)}
+
+	drawn := make(map[int]bool)
+	for range 3 {
+		c, ok := g.draw()
+		if !ok {
+			t.Fatal("draw failed with cards in the discard pile")
+		}
+		drawn[c.ID] = true
+	}
+	if len(drawn) != 3 || len(g.Discard) != 0 {
+		t.Errorf("drew %v, discard pile %v", drawn, g.Discard)
+	}
+	if _, ok := g.draw(); ok {
+		t.Error("drew a card from an empty deck and discard pile")
+	}
+}
+
+func TestStuckPlayerDiscardsHand(t *testing.T) {
+	g := newTestGame(t)
+	// Every figure is in the start area, only a start card could be played
+	g.Players[0].Hand = []Card{card(1, "two", []int{2}, EffectMove), card(2, "jack", nil, EffectSwap)}
+	g.Players[1].Hand = []Card{card(3, "king", []int{13}, EffectMove, EffectStart)}
+
+	progress := g.Advance()
+
+	if skips := progress.Skips; len(skips) != 1 || skips[0].PlayerID != "a" || len(skips[0].Discarded) != 2 {
+		t.Fatalf("skips %+v, want a with two cards", skips)
+	}
+	if progress.RoundStarted {
+		t.Error("new round started while b still has a card")
+	}
+	if len(g.Players[0].Hand) != 0 || len(g.Discard) != 2 {
+		t.Errorf("hand %v and discard pile %v after the skip", g.Players[0].Hand, g.Discard)
+	}
+	if g.Turn != 1 {
+		t.Errorf("Turn = %d, want 1", g.Turn)
+	}
+}
+
+func TestAdvanceDealsTheNextRound(t *testing.T) {
+	g := newTestGame(t)
+	g.Players[1].Hand = []Card{card(1, "two", []int{2}, EffectMove)}
+	g.Deck.Cards = []Card{card(2, "king", []int{13}, EffectMove, EffectStart)}
+
+	progress := g.Advance()
+
+	if !progress.RoundStarted || g.Round != 2 || g.Dealer != 0 {
+		t.Fatalf("RoundStarted = %v, Round = %d, Dealer = %d, want round 2 dealt by seat 0",
+			progress.RoundStarted, g.Round, g.Dealer)
+	}
+	// b discarded the two. Dealing starts after the dealer: b gets the king
+	// from the deck, a the two from the reshuffled discard pile
+	a, b := g.Players[0].Hand, g.Players[1].Hand
+	if len(a) != 1 || a[0].ID != 1 || len(b) != 1 || b[0].ID != 2 {
+		t.Errorf("hands %v and %v after the deal", a, b)
+	}
+	if g.Turn != 1 {
+		t.Errorf("Turn = %d, want the player after the dealer", g.Turn)
+	}
+}
+
+func TestAdvanceGivesUpWithoutPlayableCards(t *testing.T) {
+	g := newTestGame(t)
+	g.Players[0].Hand = []Card{card(1, "two", []int{2}, EffectMove)}
+
+	progress := g.Advance()
+
+	if !progress.RoundStarted || g.Round != 1+maxIdleRounds {
+		t.Errorf("Round = %d, want %d", g.Round, 1+maxIdleRounds)
+	}
+}
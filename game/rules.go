@@ -0,0 +1,158 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Rules are the house rules of a game. The zero value is the standard
+// ruleset.
+type Rules struct {
+	// MandatoryCapture forces a capturing play whenever one is possible
+	MandatoryCapture bool `json:"mandatoryCapture"`
+	// AllowPass lets a player pass the turn and keep the hand. A player who
+	// can not play passes too instead of discarding; the hands are only
+	// discarded once nobody with cards can or wants to play.
+	AllowPass bool `json:"allowPass"`
+	// UnprotectedStart makes start squares unsafe: a figure on its own
+	// start square can be passed, captured and swapped
+	UnprotectedStart bool `json:"unprotectedStart"`
+	// HandSizes is the sequence of cards dealt per round, DefaultHandSizes
+	// if empty
+	HandSizes []int `json:"handSizes,omitempty"`
+	// TurnTimerSeconds is how long a player has for a turn, 0 for no limit.
+	// A player who runs out of time passes or discards like a stuck player.
+	TurnTimerSeconds int `json:"turnTimerSeconds,omitempty"`
+}
+
+const (
+	maxHandSize   = 8
+	maxHandRounds = 10
+	minTurnTimer  = 10
+	maxTurnTimer  = 600
+)
+
+var (
+	ErrInvalidRules   = errors.New("invalid rules")
+	ErrPassNotAllowed = errors.New("passing is not allowed")
+	ErrNothingToPass  = errors.New("nothing to pass")
+)
+
+// StandardRules returns the standard ruleset
+func StandardRules() Rules {
+	return Rules{}
+}
+
+// IsStandard reports whether r plays like the standard ruleset. Only
+// standard games are rated, the lobby list marks them.
+func (r Rules) IsStandard() bool {
+	return !r.MandatoryCapture && !r.AllowPass && !r.UnprotectedStart && r.TurnTimerSeconds == 0 &&
+		(len(r.HandSizes) == 0 || slices.Equal(r.HandSizes, DefaultHandSizes))
+}
+
+// Validate checks the hand sizes and the turn timer
+func (r Rules) Validate() error {
+	if len(r.HandSizes) > maxHandRounds {
+		return fmt.Errorf("%w: at most %d hand sizes", ErrInvalidRules, maxHandRounds)
+	}
+	for _, n := range r.HandSizes {
+		if n < 1 || n > maxHandSize {
+			return fmt.Errorf("%w: hand sizes must be between 1 and %d", ErrInvalidRules, maxHandSize)
+		}
+	}
+	if r.TurnTimerSeconds != 0 && (r.TurnTimerSeconds < minTurnTimer || r.TurnTimerSeconds > maxTurnTimer) {
+		return fmt.Errorf("%w: the turn timer must be between %d and %d seconds", ErrInvalidRules, minTurnTimer, maxTurnTimer)
+	}
+	return nil
+}
+
+// Pass ends the turn of the current player, who keeps the hand. Only
+// allowed with Rules.AllowPass.
+func (g *Game) Pass(playerID string) (Skip, error) {
+	seat := g.seatOf(playerID)
+	if seat < 0 {
+		return Skip{}, ErrNotInGame
+	}
+	if len(g.Winners) > 0 {
+		return Skip{}, ErrGameOver
+	}
+	if g.Phase == PhaseExchange {
+		return Skip{}, ErrExchangePending
+	}
+	if seat != g.Turn {
+		return Skip{}, ErrNotYourTurn
+	}
+	if !g.Rules.AllowPass {
+		return Skip{}, ErrPassNotAllowed
+	}
+	return g.skipTurn(), nil
+}
+
+// TimeOut ends the turn of the current player after the turn timer ran
+// out. The player passes if the rules allow it and discards the hand
+// otherwise.
+func (g *Game) TimeOut() (Skip, error) {
+	if len(g.Winners) > 0 {
+		return Skip{}, ErrGameOver
+	}
+	if g.Phase == PhaseExchange {
+		return Skip{}, ErrExchangePending
+	}
+	if len(g.CurrentPlayer().Hand) == 0 {
+		return Skip{}, ErrNothingToPass
+	}
+	return g.skipTurn(), nil
+}
+
+// skipTurn makes the current player pass or discard the hand and passes
+// the turn on
+func (g *Game) skipTurn() Skip {
+	p := g.CurrentPlayer()
+	skip := Skip{PlayerID: p.ID}
+	if g.Rules.AllowPass {
+		skip.Passed = true
+		g.Passes++
+	} else {
+		skip.Discarded = p.Hand
+		g.Discard = append(g.Discard, p.Hand...)
+		p.Hand = nil
+	}
+	g.Turn = (g.Turn + 1) % len(g.Players)
+	g.Version++
+	return skip
+}
+
+// discardHands ends a round in which every player with cards passed
+func (g *Game) discardHands() []Skip {
+	var skips []Skip
+	for i := range g.Players {
+		p := g.Players[(g.Turn+i)%len(g.Players)]
+		if len(p.Hand) > 0 {
+			skips = append(skips, Skip{PlayerID: p.ID, Discarded: p.Hand})
+			g.Discard = append(g.Discard, p.Hand...)
+			p.Hand = nil
+		}
+	}
+	g.Passes = 0
+	g.Version++
+	return skips
+}
+
+// playersWithCards counts the players holding at least one card
+func (g *Game) playersWithCards() int {
+	n := 0
+	for _, p := range g.Players {
+		if len(p.Hand) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (r Rules) handSizes() []int {
+	if len(r.HandSizes) == 0 {
+		return DefaultHandSizes
+	}
+	return r.HandSizes
+}
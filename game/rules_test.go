@@ -0,0 +1,139 @@
+package game
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRulesValidate(t *testing.T) {
+	valid := []Rules{
+		StandardRules(),
+		{MandatoryCapture: true, AllowPass: true, UnprotectedStart: true},
+		{HandSizes: []int{4, 4, 4}, TurnTimerSeconds: 30},
+	}
+	for _, r := range valid {
+		if err := r.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v", r, err)
+		}
+	}
+
+	invalid := map[string]Rules{
+		"empty hand":      {HandSizes: []int{5, 0}},
+		"hand too large":  {HandSizes: []int{maxHandSize + 1}},
+		"too many rounds": {HandSizes: make([]int, maxHandRounds+1)},
+		"timer too short": {TurnTimerSeconds: 1},
+		"timer too long":  {TurnTimerSeconds: maxTurnTimer + 1},
+		"negative timer":  {TurnTimerSeconds: -30},
+	}
+	for name, r := range invalid {
+		if err := r.Validate(); !errors.Is(err, ErrInvalidRules) {
+			t.Errorf("%s: err = %v, want ErrInvalidRules", name, err)
+		}
+	}
+
+	if !(Rules{HandSizes: DefaultHandSizes}).IsStandard() || (Rules{AllowPass: true}).IsStandard() ||
+		(Rules{HandSizes: []int{4}}).IsStandard() {
+		t.Error("IsStandard is wrong")
+	}
+}
+
+func TestUnprotectedStartSquareCanBeCaptured(t *testing.T) {
+	g := newTestGame(t)
+	onTrack(g, 0, 0, 14)
+	onTrack(g, 1, 0, startSquare(1))
+	g.Players[0].Hand = []Card{card(1, "two", []int{2}, EffectMove)}
+	play := Play{CardID: 1, Effect: EffectMove, FigureID: 0, Moves: 2}
+
+	if err := g.PlayCard("a", play); !errors.Is(err, ErrInvalidPlay) {
+		t.Fatalf("landed on a protected start square: %v", err)
+	}
+
+	g.Rules.UnprotectedStart = true
+	if err := g.PlayCard("a", play); err != nil {
+		t.Fatal(err)
+	}
+	if f := g.Players[1].Figures[0]; f.Status != FigureInStart {
+		t.Errorf("figure on the start square is %+v, want captured", f)
+	}
+}
+
+func TestMandatoryCapture(t *testing.T) {
+	g := newTestGame(t)
+	g.Rules.MandatoryCapture = true
+	onTrack(g, 0, 0, 3)
+	onTrack(g, 0, 1, 10)
+	onTrack(g, 1, 0, 5)
+	g.Players[0].Hand = []Card{card(1, "two", []int{2}, EffectMove)}
+
+	moves, err := g.LegalMoves("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 1 || !moves[0].Capture || moves[0].Play.FigureID != 0 {
+		t.Fatalf("moves %+v, want only the capture", moves)
+	}
+
+	if err := g.PlayCard("a", Play{CardID: 1, Effect: EffectMove, FigureID: 1, Moves: 2}); !errors.Is(err, ErrInvalidPlay) {
+		t.Errorf("passed up a capture: %v", err)
+	}
+	if err := g.PlayCard("a", moves[0].Play); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPassKeepsHandUntilEveryonePassed(t *testing.T) {
+	g := newTestGame(t)
+	onTrack(g, 0, 0, 3)
+	onTrack(g, 1, 0, 20)
+	g.Players[0].Hand = []Card{card(1, "two", []int{2}, EffectMove)}
+	g.Players[1].Hand = []Card{card(2, "three", []int{3}, EffectMove)}
+
+	if _, err := g.Pass("a"); !errors.Is(err, ErrPassNotAllowed) {
+		t.Fatalf("passed with standard rules: %v", err)
+	}
+
+	g.Rules.AllowPass = true
+	skip, err := g.Pass("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !skip.Passed || len(g.Players[0].Hand) != 1 || g.Turn != 1 {
+		t.Fatalf("skip %+v, hand %v, Turn = %d after the pass", skip, g.Players[0].Hand, g.Turn)
+	}
+	if progress := g.Advance(); len(progress.Skips) != 0 {
+		t.Fatalf("b was skipped: %+v", progress.Skips)
+	}
+
+	if _, err := g.Pass("b"); err != nil {
+		t.Fatal(err)
+	}
+	progress := g.Advance()
+	if len(progress.Skips) != 2 || !progress.RoundStarted || g.Passes != 0 {
+		t.Errorf("skips %+v, RoundStarted = %v, Passes = %d, want both hands discarded and a new round",
+			progress.Skips, progress.RoundStarted, g.Passes)
+	}
+}
+
+func TestTimeOutDiscardsOrPasses(t *testing.T) {
+	g := newTestGame(t)
+	onTrack(g, 0, 0, 3)
+	g.Players[0].Hand = []Card{card(1, "two", []int{2}, EffectMove)}
+	g.Players[1].Hand = []Card{card(2, "three", []int{3}, EffectMove)}
+
+	skip, err := g.TimeOut()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skip.Discarded) != 1 || len(g.Players[0].Hand) != 0 || g.Turn != 1 {
+		t.Errorf("skip %+v, Turn = %d, want a's hand discarded", skip, g.Turn)
+	}
+
+	g.Rules.AllowPass = true
+	skip, err = g.TimeOut()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !skip.Passed || len(g.Players[1].Hand) != 1 {
+		t.Errorf("skip %+v, want b to pass and keep the hand", skip)
+	}
+}
@@ -0,0 +1,129 @@
+package game
+
+import (
+	"errors"
+	"slices"
+)
+
+// In team play four players form two teams with the partners sitting
+// opposite each other, seats 0 and 2 against 1 and 3. Every round starts
+// with a blind exchange: each player gives the partner one card, and the
+// cards change hands once all players have chosen. A player whose figures
+// are all home moves the partner's figures, and a team wins once both
+// partners are finished.
+
+// teamSeats is the number of players in team play
+const teamSeats = 4
+
+// Phase is the part of a round the game is in
+type Phase string
+
+const (
+	PhasePlay     Phase = "play"
+	PhaseExchange Phase = "exchange" // partners choose the card to give each other
+)
+
+var (
+	ErrExchangePending = errors.New("partners are still exchanging cards")
+	ErrNoExchange      = errors.New("no card exchange now")
+	ErrAlreadyChosen   = errors.New("card for the partner already chosen")
+)
+
+// partnerSeat returns the seat of the player's partner, or -1
+func (g *Game) partnerSeat(seat int) int {
+	if g.Players[seat].Partner == "" {
+		return -1
+	}
+	return g.seatOf(g.Players[seat].Partner)
+}
+
+// controlledSeat is the seat whose figures the player at seat moves: their
+// own, or their partner's once they are finished
+func (g *Game) controlledSeat(seat int) int {
+	if partner := g.partnerSeat(seat); partner >= 0 && g.Players[seat].Finished() {
+		return partner
+	}
+	return seat
+}
+
+// exchanging reports whether the player at seat takes part in the exchange
+// of this round. Partners only exchange if both have a card to give.
+func (g *Game) exchanging(seat int) bool {
+	partner := g.partnerSeat(seat)
+	hasCard := func(p *PlayerInGame) bool { return p.Exchange != nil || len(p.Hand) > 0 }
+	return partner >= 0 && hasCard(g.Players[seat]) && hasCard(g.Players[partner])
+}
+
+// startRound opens the exchange in team play, the round starts right away
+// otherwise.
+func (g *Game) startRound() {
+	g.Phase = PhasePlay
+	for seat := range g.Players {
+		if g.exchanging(seat) {
+			g.Phase = PhaseExchange
+		}
+	}
+}
+
+// ExchangeCard sets aside a card of the player's hand for the partner. The
+// cards are handed over once every player has chosen.
+func (g *Game) ExchangeCard(playerID string, cardID int) error {
+	seat := g.seatOf(playerID)
+	if seat < 0 {
+		return ErrNotInGame
+	}
+	if g.Phase != PhaseExchange || !g.exchanging(seat) {
+		return ErrNoExchange
+	}
+
+	p := g.Players[seat]
+	if p.Exchange != nil {
+		return ErrAlreadyChosen
+	}
+	i := slices.IndexFunc(p.Hand, func(c Card) bool { return c.ID == cardID })
+	if i < 0 {
+		return ErrCardNotInHand
+	}
+
+	card := p.Hand[i]
+	p.Exchange = &card
+	p.Hand = slices.Delete(p.Hand, i, i+1)
+	g.Version++
+
+	for seat, p := range g.Players {
+		if g.exchanging(seat) && p.Exchange == nil {
+			return nil
+		}
+	}
+
+	for seat, p := range g.Players {
+		if p.Exchange != nil {
+			partner := g.partnerSeat(seat)
+			g.Players[partner].Hand = append(g.Players[partner].Hand, *p.Exchange)
+		}
+	}
+	for _, p := range g.Players {
+		p.Exchange = nil
+	}
+	g.Phase = PhasePlay
+	return nil
+}
+
+// checkWinners ends the game once a player, or in team play both partners,
+// have all figures home
+func (g *Game) checkWinners() {
+	for seat, p := range g.Players {
+		if !p.Finished() {
+			continue
+		}
+		partner := g.partnerSeat(seat)
+		if partner < 0 {
+			g.Winners = []string{p.ID}
+			return
+		}
+		if g.Players[partner].Finished() {
+			g.Winners = []string{p.ID, p.Partner}
+			return
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package game
+
+import (
+	"errors"
+	"testing"
+)
+
+func newTeamGame(t *testing.T) *Game {
+	t.Helper()
+
+	g := newBoardGame(t, 4)
+	for seat, p := range g.Players {
+		p.Partner = g.Players[(seat+2)%4].ID
+	}
+	return g
+}
+
+func TestPartnersExchangeCardsBlind(t *testing.T) {
+	g := newTeamGame(t)
+	for seat, p := range g.Players {
+		p.Hand = []Card{card(seat*10, "two", []int{2}, EffectMove), card(seat*10+1, "three", []int{3}, EffectMove)}
+	}
+	g.startRound()
+	if g.Phase != PhaseExchange {
+		t.Fatalf("Phase = %q, want exchange", g.Phase)
+	}
+
+	if err := g.PlayCard("p0", Play{CardID: 0, Effect: EffectMove, Moves: 2}); !errors.Is(err, ErrExchangePending) {
+		t.Errorf("play during the exchange: %v", err)
+	}
+
+	for seat, p := range g.Players {
+		if err := g.ExchangeCard(p.ID, seat*10); err != nil {
+			t.Fatalf("ExchangeCard(%s): %v", p.ID, err)
+		}
+		if seat == 0 {
+			if err := g.ExchangeCard(p.ID, 1); !errors.Is(err, ErrAlreadyChosen) {
+				t.Errorf("second exchange: %v", err)
+			}
+			// Nothing changes hands before everyone has chosen
+			if len(g.Players[2].Hand) != 2 || g.Players[2].Hand[0].ID != 20 {
+				t.Errorf("partner's hand %+v changed early", g.Players[2].Hand)
+			}
+		}
+	}
+
+	if g.Phase != PhasePlay {
+		t.Fatalf("Phase = %q after the exchange, want play", g.Phase)
+	}
+	for seat, p := range g.Players {
+		partner := (seat + 2) % 4
+		if len(p.Hand) != 2 || p.Hand[1].ID != partner*10 {
+			t.Errorf("hand of %s is %+v, want card %d from the partner", p.ID, p.Hand, partner*10)
+		}
+	}
+	if err := g.ExchangeCard("p0", 1); !errors.Is(err, ErrNoExchange) {
+		t.Errorf("exchange after the exchange phase: %v", err)
+	}
+}
+
+func TestExchangeSkipsPartnersWithoutCards(t *testing.T) {
+	g := newTeamGame(t)
+	g.Players[0].Hand = []Card{card(1, "two", []int{2}, EffectMove)}
+	g.Players[1].Hand = []Card{card(2, "two", []int{2}, EffectMove)}
+	g.Players[3].Hand = []Card{card(3, "two", []int{2}, EffectMove)}
+	g.startRound()
+
+	// p2 has nothing to give, so p0 and p2 skip the exchange
+	if err := g.ExchangeCard("p0", 1); !errors.Is(err, ErrNoExchange) {
+		t.Errorf("exchange with a partner without cards: %v", err)
+	}
+	if err := g.ExchangeCard("p1", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.ExchangeCard("p3", 3); err != nil {
+		t.Fatal(err)
+	}
+	if g.Phase != PhasePlay {
+		t.Fatalf("Phase = %q, want play once p1 and p3 exchanged", g.Phase)
+	}
+	if len(g.Players[0].Hand) != 1 || len(g.Players[2].Hand) != 0 || g.Players[1].Hand[0].ID != 3 || g.Players[3].Hand[0].ID != 2 {
+		t.Errorf("hands after the exchange %+v %+v %+v %+v", g.Players[0].Hand, g.Players[1].Hand, g.Players[2].Hand, g.Players[3].Hand)
+	}
+
+	// Without any pair to exchange the round starts right away
+	g.Players[1].Hand, g.Players[3].Hand = nil, nil
+	g.startRound()
+	if g.Phase != PhasePlay {
+		t.Errorf("Phase = %q without a pair to exchange", g.Phase)
+	}
+}
+
+func TestKnockingOutThePartnerIsNoCapture(t *testing.T) {
+	g := newTeamGame(t)
+	g.Rules.MandatoryCapture = true
+	onTrack(g, 0, 0, 3)
+	onTrack(g, 0, 1, 10)
+	onTrack(g, 2, 0, 5)
+	g.Players[0].Hand = []Card{card(1, "two", []int{2}, EffectMove)}
+
+	moves, err := g.LegalMoves("p0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 2 || moves[0].Capture || moves[1].Capture {
+		t.Errorf("moves %+v, want both moves and no capture", moves)
+	}
+}
+
+func TestFinishedPlayerMovesPartnersFigures(t *testing.T) {
+	g := newTeamGame(t)
+	for id := range FiguresPerPlayer {
+		inHome(g, 0, id, id)
+	}
+	onTrack(g, 2, 0, 40)
+	g.Players[0].Hand = []Card{card(1, "two", []int{2}, EffectMove)}
+
+	moves, err := g.LegalMoves("p0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 1 || moves[0].Changes[0].PlayerID != "p2" {
+		t.Fatalf("moves %+v, want one move of p2's figure", moves)
+	}
+
+	if err := g.PlayCard("p0", Play{CardID: 1, Effect: EffectMove, FigureID: 0, Moves: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if pos := g.Players[2].Figures[0].Position; pos != 42 {
+		t.Errorf("partner's figure at %d, want 42", pos)
+	}
+	if len(g.Winners) != 0 {
+		t.Errorf("Winners = %v before the partner finished", g.Winners)
+	}
+}
+
+func TestTeamWinsWhenBothPartnersAreHome(t *testing.T) {
+	g := newTeamGame(t)
+	for id := range FiguresPerPlayer {
+		inHome(g, 0, id, id)
+	}
+	for id := 1; id < FiguresPerPlayer; id++ {
+		inHome(g, 2, id, id)
+	}
+	onTrack(g, 2, 0, 31)
+	g.Players[0].Hand = []Card{card(1, "ace", []int{1, 11}, EffectMove, EffectStart)}
+
+	if err := g.PlayCard("p0", Play{CardID: 1, Effect: EffectMove, FigureID: 0, Moves: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Winners) != 2 || g.Winners[0] != "p0" || g.Winners[1] != "p2" {
+		t.Errorf("Winners = %v, want p0 and p2", g.Winners)
+	}
+}
@@ -0,0 +1,65 @@
+package game
+
+// View is the game as one player may see it: their own hand, but only the
+// number of cards in the other hands and in the deck.
+type View struct {
+	Version     uint64       `json:"version"`
+	DeckVersion string       `json:"deckVersion"`
+	Turn        string       `json:"turn"` // ID of the player to play next
+	Round       int          `json:"round"`
+	Dealer      string       `json:"dealer"`   // ID of the dealer of the round
+	HandSize    int          `json:"handSize"` // cards dealt this round
+	Phase       Phase        `json:"phase"`
+	Winners     []string     `json:"winners,omitempty"`
+	DeckSize    int          `json:"deckSize"`
+	TopDiscard  *Card        `json:"topDiscard,omitempty"`
+	Hand        []Card       `json:"hand"`
+	Players     []PlayerView `json:"players"`
+}
+
+type PlayerView struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Partner   string   `json:"partner,omitempty"`
+	HandSize  int      `json:"handSize"`
+	Exchanged bool     `json:"exchanged,omitempty"` // has chosen the card for the partner
+	Figures   []Figure `json:"figures"`
+}
+
+// ViewFor returns the view of the given player. Spectators get an empty hand.
+func (g *Game) ViewFor(playerID string) View {
+	v := View{
+		Version:     g.Version,
+		DeckVersion: g.DeckVersion,
+		Turn:        g.CurrentPlayer().ID,
+		Round:       g.Round,
+		Dealer:      g.Players[g.Dealer].ID,
+		HandSize:    g.handSize(),
+		Phase:       g.Phase,
+		Winners:     g.Winners,
+		Hand:        []Card{},
+		Players:     make([]PlayerView, len(g.Players)),
+	}
+	if g.Deck != nil {
+		v.DeckSize = len(g.Deck.Cards)
+	}
+	if n := len(g.Discard); n > 0 {
+		top := g.Discard[n-1]
+		v.TopDiscard = &top
+	}
+
+	for i, p := range g.Players {
+		if p.ID == playerID {
+			v.Hand = cloneCards(p.Hand)
+		}
+		v.Players[i] = PlayerView{
+			ID:        p.ID,
+			Name:      p.Name,
+			Partner:   p.Partner,
+			HandSize:  len(p.Hand),
+			Exchanged: p.Exchange != nil,
+			Figures:   append([]Figure(nil), p.Figures...),
+		}
+	}
+	return v
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Daweenci/Web_Lobby/game"
+)
+
+func playCardHandler(msg PlayCardRequest) {
+	activePlayersLock.RLock()
+	player, ok := activePlayers[msg.PlayerID]
+	activePlayersLock.RUnlock()
+	if !ok {
+		log.Println("playCardHandler: Player not found")
+		disconnectPlayer(msg.PlayerID)
+		return
+	}
+
+	lobbiesLock.RLock()
+	lobby, ok := lobbies[msg.LobbyID]
+	lobbiesLock.RUnlock()
+	if !ok {
+		sendErrorToPlayer(player, msg.RequestID, ErrorLobbyNotFound, "Lobby not found")
+		return
+	}
+
+	progress, err := playTurn(lobby, player, func(g *game.Game, playerID string) ([]game.Skip, error) {
+		return nil, g.PlayCard(playerID, msg.Play)
+	})
+	if err != nil {
+		sendErrorToPlayer(player, msg.RequestID, gameErrorCode(err), err.Error())
+		return
+	}
+
+	broadcastGameProgress(lobby, progress)
+	persistLobby(lobby)
+}
+
+func exchangeCardHandler(msg ExchangeCardRequest) {
+	activePlayersLock.RLock()
+	player, ok := activePlayers[msg.PlayerID]
+	activePlayersLock.RUnlock()
+	if !ok {
+		log.Println("exchangeCardHandler: Player not found")
+		disconnectPlayer(msg.PlayerID)
+		return
+	}
+
+	lobbiesLock.RLock()
+	lobby, ok := lobbies[msg.LobbyID]
+	lobbiesLock.RUnlock()
+	if !ok {
+		sendErrorToPlayer(player, msg.RequestID, ErrorLobbyNotFound, "Lobby not found")
+		return
+	}
+
+	progress, err := playTurn(lobby, player, func(g *game.Game, playerID string) ([]game.Skip, error) {
+		return nil, g.ExchangeCard(playerID, msg.CardID)
+	})
+	if err != nil {
+		sendErrorToPlayer(player, msg.RequestID, gameErrorCode(err), err.Error())
+		return
+	}
+
+	broadcastGameProgress(lobby, progress)
+	persistLobby(lobby)
+}
+
+func passTurnHandler(msg PassTurnRequest) {
+	activePlayersLock.RLock()
+	player, ok := activePlayers[msg.PlayerID]
+	activePlayersLock.RUnlock()
+	if !ok {
+		log.Println("passTurnHandler: Player not found")
+		disconnectPlayer(msg.PlayerID)
+		return
+	}
+
+	lobbiesLock.RLock()
+	lobby, ok := lobbies[msg.LobbyID]
+	lobbiesLock.RUnlock()
+	if !ok {
+		sendErrorToPlayer(player, msg.RequestID, ErrorLobbyNotFound, "Lobby not found")
+		return
+	}
+
+	progress, err := playTurn(lobby, player, func(g *game.Game, playerID string) ([]game.Skip, error) {
+		skip, err := g.Pass(playerID)
+		return []game.Skip{skip}, err
+	})
+	if err != nil {
+		sendErrorToPlayer(player, msg.RequestID, gameErrorCode(err), err.Error())
+		return
+	}
+
+	broadcastGameProgress(lobby, progress)
+	persistLobby(lobby)
+}
+
+func getLegalMovesHandler(msg GetLegalMovesRequest) {
+	activePlayersLock.RLock()
+	player, ok := activePlayers[msg.PlayerID]
+	activePlayersLock.RUnlock()
+	if !ok {
+		log.Println("getLegalMovesHandler: Player not found")
+		disconnectPlayer(msg.PlayerID)
+		return
+	}
+
+	lobbiesLock.RLock()
+	lobby, ok := lobbies[msg.LobbyID]
+	lobbiesLock.RUnlock()
+	if !ok {
+		sendErrorToPlayer(player, msg.RequestID, ErrorLobbyNotFound, "Lobby not found")
+		return
+	}
+
+	version, moves, err := legalMovesIn(lobby, player)
+	if err != nil {
+		sendErrorToPlayer(player, msg.RequestID, gameErrorCode(err), err.Error())
+		return
+	}
+	if moves == nil {
+		moves = []game.Move{}
+	}
+
+	sendResponse(player, LegalMovesResponse{
+		BaseResponse: newReplyResponse(ResponseLegalMoves, msg.RequestID),
+		LobbyID:      lobby.ID,
+		Version:      version,
+		Moves:        moves,
+	})
+}
+
+// ErrGameNotStarted is returned for a game action in a lobby without a game
+var ErrGameNotStarted = errors.New("The game has not started")
+
+// playTurn runs the player's action on the lobby's game and advances the
+// game after it. The skips of the action come first in the progress.
+func playTurn(lobby *Lobby, player *Player, action func(g *game.Game, playerID string) ([]game.Skip, error)) (game.Progress, error) {
+	lobby.Lock.Lock()
+	defer lobby.Lock.Unlock()
+
+	if lobby.Game == nil {
+		return game.Progress{}, ErrGameNotStarted
+	}
+	skips, err := action(lobby.Game, actingPlayerID(lobby, player))
+	if err != nil {
+		return game.Progress{}, err
+	}
+	progress := lobby.Game.Advance()
+	progress.Skips = append(skips, progress.Skips...)
+	resetTurnTimer(lobby)
+	return progress, nil
+}
+
+// legalMovesIn returns the player's legal moves and the game version they
+// were computed for
+func legalMovesIn(lobby *Lobby, player *Player) (uint64, []game.Move, error) {
+	lobby.Lock.RLock()
+	defer lobby.Lock.RUnlock()
+
+	if lobby.Game == nil {
+		return 0, nil, ErrGameNotStarted
+	}
+	moves, err := lobby.Game.LegalMoves(actingPlayerID(lobby, player))
+	return lobby.Game.Version, moves, err
+}
+
+// gameErrorCode maps the errors of the game engine to error codes
+func gameErrorCode(err error) ErrorCode {
+	switch {
+	case errors.Is(err, ErrGameNotStarted):
+		return ErrorGameNotStarted
+	case errors.Is(err, game.ErrNotYourTurn), errors.Is(err, game.ErrNotInGame), errors.Is(err, game.ErrExchangePending):
+		return ErrorNotYourTurn
+	case errors.Is(err, game.ErrNoExchange), errors.Is(err, game.ErrAlreadyChosen):
+		return ErrorNoExchange
+	case errors.Is(err, game.ErrCardNotInHand), errors.Is(err, game.ErrInvalidPlay), errors.Is(err, game.ErrPassNotAllowed):
+		return ErrorInvalidPlay
+	case errors.Is(err, game.ErrGameOver):
+		return ErrorGameOver
+	default:
+		return ErrorInternal
+	}
+}
+
+// gameViews returns the view of the game of every player in the lobby, or
+// nil if no game runs, and the end of the current turn
+func gameViews(lobby *Lobby) (map[*Player]game.View, time.Time) {
+	lobby.Lock.RLock()
+	defer lobby.Lock.RUnlock()
+
+	if lobby.Game == nil {
+		return nil, time.Time{}
+	}
+	views := make(map[*Player]game.View, len(lobby.Players))
+	for _, p := range lobby.Players {
+		views[p] = lobby.Game.ViewFor(actingPlayerID(lobby, p))
+	}
+	return views, lobby.turnDeadline
+}
+
+// broadcastGameState sends every player of the lobby their view of the game
+func broadcastGameState(lobby *Lobby) {
+	views, deadline := gameViews(lobby)
+	var turnDeadline int64
+	if !deadline.IsZero() {
+		turnDeadline = deadline.UnixMilli()
+	}
+	for p, view := range views {
+		sendResponse(p, GameStateResponse{
+			BaseResponse: newBaseResponse(ResponseGameState),
+			LobbyID:      lobby.ID,
+			Game:         view,
+			TurnDeadline: turnDeadline,
+		})
+	}
+}
+
+// broadcastRoundStarted sends every player of the lobby their new hand
+func broadcastRoundStarted(lobby *Lobby) {
+	views, _ := gameViews(lobby)
+	for p, view := range views {
+		sendResponse(p, RoundStartedResponse{
+			BaseResponse: newBaseResponse(ResponseRoundStarted),
+			LobbyID:      lobby.ID,
+			Round:        view.Round,
+			Dealer:       view.Dealer,
+			Game:         view,
+		})
+	}
+}
+
+// broadcastGameProgress tells the lobby about the skipped players and a new
+// round, then sends the game state
+func broadcastGameProgress(lobby *Lobby, progress game.Progress) {
+	broadcastTurnsSkipped(lobby, progress.Skips)
+	if progress.RoundStarted {
+		broadcastRoundStarted(lobby)
+	}
+	broadcastGameState(lobby)
+}
+
+// broadcastTurnsSkipped tells the lobby about every player that had to
+// discard their hand or passed
+func broadcastTurnsSkipped(lobby *Lobby, skips []game.Skip) {
+	if len(skips) == 0 {
+		return
+	}
+
+	lobby.Lock.RLock()
+	players := make([]*Player, len(lobby.Players))
+	copy(players, lobby.Players)
+	lobby.Lock.RUnlock()
+
+	for _, skip := range skips {
+		discarded := skip.Discarded
+		if skip.Passed {
+			log.Printf("Player %s passed in lobby %s", skip.PlayerID, lobby.ID)
+			discarded = []game.Card{}
+		} else {
+			log.Printf("Player %s discarded %d cards in lobby %s", skip.PlayerID, len(skip.Discarded), lobby.ID)
+		}
+		for _, p := range players {
+			sendResponse(p, TurnSkippedResponse{
+				BaseResponse: newBaseResponse(ResponseTurnSkipped),
+				LobbyID:      lobby.ID,
+				PlayerID:     skip.PlayerID,
+				Discarded:    discarded,
+				Passed:       skip.Passed,
+			})
+		}
+	}
+}
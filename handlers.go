@@ -4,21 +4,12 @@ import (
 	"errors"
 	"log"
 
+	"github.com/Daweenci/Web_Lobby/game"
 	"github.com/google/uuid"
 )
 
 func joinLobbyHandler(msg JoinLobbyRequest) {
 
-	// Check if lobby exists
-	lobbiesLock.RLock()
-	lobby, ok := lobbies[msg.LobbyID]
-	lobbiesLock.RUnlock()
-	if !ok {
-		log.Println("joinLobbyHandler: Lobby not found")
-		// Lobby not found, silently ignore or send a response if needed
-		return
-	}
-
 	// Check if player is connected
 	activePlayersLock.RLock()
 	player, ok := activePlayers[msg.PlayerID]
@@ -29,106 +20,141 @@ func joinLobbyHandler(msg JoinLobbyRequest) {
 		return
 	}
 
-	lobby.Lock.Lock()
-	// Check if player is already in the lobby
-	for _, p := range lobby.Players {
-		if p.ID == msg.PlayerID {
-			// Already in the lobby, silently ignore or send a response if needed
-			lobby.Lock.Unlock()
-			return
-		}
+	// Check if lobby exists
+	lobbiesLock.RLock()
+	lobby, ok := lobbies[msg.LobbyID]
+	lobbiesLock.RUnlock()
+	if !ok {
+		sendResponse(player, LobbyJoinFailedResponse{
+			BaseResponse: newReplyResponse(ResponseJoinLobbyFailed, msg.RequestID),
+			Code:         ErrorLobbyNotFound,
+			Message:      "Lobby not found",
+		})
+		return
 	}
 
-	// Check password
-	if lobby.Password != msg.Password {
+	lobbyResponse, joined, code, errMsg := joinLobby(lobby, player, msg.Password)
+	if code != "" {
 		sendResponse(player, LobbyJoinFailedResponse{
-			BaseResponse: newBaseResponse(ResponseJoinLobbyFailed),
-			Message:      "Incorrect password",
+			BaseResponse: newReplyResponse(ResponseJoinLobbyFailed, msg.RequestID),
+			Code:         code,
+			Message:      errMsg,
 		})
-		lobby.Lock.Unlock()
 		return
 	}
-
-	// Check lobby capacity
-	if len(lobby.Players) >= lobby.MaxPlayers {
-		lobbyFullResponse := LobbyJoinFailedResponse{
-			BaseResponse: newBaseResponse(ResponseJoinLobbyFailed),
-			Message:      "Lobby is full",
-		}
-		sendResponse(player, lobbyFullResponse)
-		lobby.Lock.Unlock()
+	// Already in the lobby, silently ignore
+	if !joined {
 		return
 	}
 
-	// Add player and respond
-	lobby.Players = append(lobby.Players, player)
-	lobbyResponse := LobbyDTO{
-		ID:         lobby.ID,
-		Name:       lobby.Name,
-		MaxPlayers: lobby.MaxPlayers,
-		IsPrivate:  lobby.IsPrivate,
-		Players:    toPlayerResponses(lobby.Players),
-		GameStart:  lobby.GameStart,
-	}
-	lobby.Lock.Unlock()
 	successfulJoinResponse := SuccessfulJoinLobbyResponse{
-		BaseResponse: newBaseResponse(ResponseJoinLobbySuccessful),
+		BaseResponse: newReplyResponse(ResponseJoinLobbySuccessful, msg.RequestID),
 		Lobby:        lobbyResponse,
 	}
 	sendResponse(player, successfulJoinResponse)
 	broadcastLobbyUpdate(lobby)
-	broadcastLobbies()
+	broadcastLobbyChanged(lobby)
 }
 
-func leaveLobbyHandler(msg LeaveLobbyRequest) {
-	lobbiesLock.RLock()
-	lobby, ok := lobbies[msg.LobbyID]
-	lobbiesLock.RUnlock()
-	if !ok {
-		log.Println("leaveLobbyHandler: Lobby not found")
-		return
+// joinLobby seats the player in the lobby and returns the lobby after the
+// join. joined is false if the player already sits there.
+func joinLobby(lobby *Lobby, player *Player, password string) (dto LobbyDTO, joined bool, code ErrorCode, errMsg string) {
+	lobby.Lock.Lock()
+	defer lobby.Lock.Unlock()
+
+	switch {
+	// The last player left since the lookup
+	case lobby.removed:
+		return LobbyDTO{}, false, ErrorLobbyNotFound, "Lobby not found"
+	case isSeated(lobby, player.ID):
+		return LobbyDTO{}, false, "", ""
+	case lobby.Password != password:
+		return LobbyDTO{}, false, ErrorIncorrectPassword, "Incorrect password"
+	case len(lobby.Players) >= lobby.MaxPlayers:
+		return LobbyDTO{}, false, ErrorLobbyFull, "Lobby is full"
 	}
 
+	lobby.Players = append(lobby.Players, player)
+	lobby.Version++
+	return toLobbyDTO(lobby), true, "", ""
+}
+
+func leaveLobbyHandler(msg LeaveLobbyRequest) {
 	activePlayersLock.RLock()
 	player, ok := activePlayers[msg.PlayerID]
 	activePlayersLock.RUnlock()
 	if !ok {
 		log.Println("leaveLobbyHandler: Player not found")
-		disconnectPlayer(player.ID)
+		disconnectPlayer(msg.PlayerID)
 		return
 	}
 
-	lobby.Lock.Lock()
-	for i := len(lobby.GameStart) - 1; i >= 0; i-- {
-		if lobby.GameStart[i].ID == player.ID {
-			lobby.GameStart = append(lobby.GameStart[:i], lobby.GameStart[i+1:]...)
-			break
-		}
+	lobbiesLock.RLock()
+	lobby, ok := lobbies[msg.LobbyID]
+	lobbiesLock.RUnlock()
+	if !ok {
+		sendErrorToPlayer(player, msg.RequestID, ErrorLobbyNotFound, "Lobby not found")
+		return
 	}
 
-	for i := len(lobby.Players) - 1; i >= 0; i-- {
-		if lobby.Players[i].ID == player.ID {
-			lobby.Players = append(lobby.Players[:i], lobby.Players[i+1:]...)
-			break
-		}
+	kept, lobbyDeleted := leaveLobby(lobby, player.ID)
+	// The others play on, the seat is kept like for a dropped connection
+	if kept {
+		sendResponse(player, LobbyLeftResponse{
+			BaseResponse: newReplyResponse(ResponseLobbyLeft, msg.RequestID),
+		})
+		broadcastLobbyUpdate(lobby)
+		broadcastLobbyChanged(lobby)
+		return
 	}
-	lobby.Lock.Unlock()
 
-	lobbyDeleted := false
-	if len(lobby.Players) == 0 {
+	if lobbyDeleted {
 		lobbiesLock.Lock()
 		delete(lobbies, lobby.ID)
 		lobbiesLock.Unlock()
-		lobbyDeleted = true
 	}
 	if !lobbyDeleted {
 		broadcastLobbyUpdate(lobby)
 	}
 
 	sendResponse(player, LobbyLeftResponse{
-		BaseResponse: newBaseResponse(ResponseLobbyLeft),
+		BaseResponse: newReplyResponse(ResponseLobbyLeft, msg.RequestID),
 	})
-	broadcastLobbies()
+	if lobbyDeleted {
+		broadcastLobbyRemoved(lobby.ID)
+	} else {
+		broadcastLobbyChanged(lobby)
+	}
+}
+
+// leaveLobby takes the player out of the lobby. kept reports that the seat
+// in the running game stays as an offline one, removed that the lobby is
+// empty now and must be deleted.
+func leaveLobby(lobby *Lobby, playerID string) (kept, removed bool) {
+	lobby.Lock.Lock()
+	defer lobby.Lock.Unlock()
+
+	if keepSeatOffline(lobby, playerID) {
+		return true, false
+	}
+
+	for i := len(lobby.GameStart) - 1; i >= 0; i-- {
+		if lobby.GameStart[i].ID == playerID {
+			lobby.GameStart = append(lobby.GameStart[:i], lobby.GameStart[i+1:]...)
+			break
+		}
+	}
+
+	for i := len(lobby.Players) - 1; i >= 0; i-- {
+		if lobby.Players[i].ID == playerID {
+			lobby.Players = append(lobby.Players[:i], lobby.Players[i+1:]...)
+			break
+		}
+	}
+	lobby.Version++
+	syncLobbyGame(lobby)
+	lobby.removed = len(lobby.Players) == 0
+	return false, lobby.removed
 }
 
 func createLobbyHandler(msg CreateLobbyRequest) {
@@ -141,100 +167,178 @@ func createLobbyHandler(msg CreateLobbyRequest) {
 		return
 	}
 	lobbyID := uuid.New().String()
+	rules := game.StandardRules()
+	if msg.Rules != nil {
+		rules = *msg.Rules
+	}
 
 	newLobby := &Lobby{
-		ID:         lobbyID,
-		Name:       msg.LobbyName,
-		MaxPlayers: msg.MaxPlayers,
-		IsPrivate:  msg.IsPrivate,
-		Password:   msg.Password,
-		Players:    []*Player{player},
-		GameStart:  []PlayerStarted{},
-	}
+		ID:          lobbyID,
+		Name:        msg.LobbyName,
+		MaxPlayers:  msg.MaxPlayers,
+		IsPrivate:   msg.IsPrivate,
+		Password:    msg.Password,
+		Players:     []*Player{player},
+		GameStart:   []PlayerStarted{},
+		TeamPlay:    msg.TeamPlay,
+		RandomTeams: msg.RandomTeams,
+		Teams:       make(map[string]int),
+		Rules:       rules,
+		Version:     1,
+	}
+	newLobbyResponse := toLobbyDTO(newLobby)
 
 	lobbiesLock.Lock()
 	lobbies[lobbyID] = newLobby
 	lobbiesLock.Unlock()
 
-	newLobbyResponse := LobbyDTO{
-		ID:         newLobby.ID,
-		Name:       newLobby.Name,
-		MaxPlayers: newLobby.MaxPlayers,
-		IsPrivate:  newLobby.IsPrivate,
-		Players:    toPlayerResponses(newLobby.Players),
-		GameStart:  newLobby.GameStart,
-	}
-
 	createLobbyResponse := CreateLobbyResponse{
-		BaseResponse: newBaseResponse(ResponseLobbyCreated),
+		BaseResponse: newReplyResponse(ResponseLobbyCreated, msg.RequestID),
 		Lobby:        newLobbyResponse,
 	}
 	sendResponse(player, createLobbyResponse)
-	broadcastLobbies()
+	broadcastLobbyAdded(newLobby)
 }
 
 func startGameHandler(msg StartGame) {
+	activePlayersLock.RLock()
+	player, ok := activePlayers[msg.PlayerID]
+	activePlayersLock.RUnlock()
+	if !ok {
+		log.Println("StartGameHandler: Player not found")
+		disconnectPlayer(msg.PlayerID)
+		return
+	}
+
 	lobbiesLock.RLock()
 	lobby, ok := lobbies[msg.LobbyID]
 	lobbiesLock.RUnlock()
 	if !ok {
-		log.Println("StartGameHandler: Lobby not found")
+		sendErrorToPlayer(player, msg.RequestID, ErrorLobbyNotFound, "Lobby not found")
 		return
 	}
 
-	activePlayersLock.RLock()
-	player, ok := activePlayers[msg.PlayerID]
-	activePlayersLock.RUnlock()
-	if !ok {
-		log.Println("StartGameHandler: Player not found")
-		disconnectPlayer(msg.PlayerID)
+	gameStarted, code, errMsg := pressStart(lobby, player.ID)
+	if code != "" {
+		sendErrorToPlayer(player, msg.RequestID, code, errMsg)
 		return
 	}
 
+	broadcastLobbyUpdate(lobby)
+	broadcastLobbyChanged(lobby)
+	if gameStarted {
+		broadcastRoundStarted(lobby)
+	}
+}
+
+// pressStart marks the player as ready and reports whether that started the
+// game
+func pressStart(lobby *Lobby, playerID string) (started bool, code ErrorCode, errMsg string) {
 	lobby.Lock.Lock()
-	alreadyStarted := false
+	defer lobby.Lock.Unlock()
+
+	if !isSeated(lobby, playerID) {
+		return false, ErrorNotInLobby, "You are not in this lobby"
+	}
 	for _, p := range lobby.GameStart {
-		if p.ID == player.ID {
-			alreadyStarted = true
-			break
+		if p.ID == playerID {
+			return false, "", ""
 		}
 	}
-	if !alreadyStarted {
-		lobby.GameStart = append(lobby.GameStart, PlayerStarted{ID: player.ID})
-	}
-	lobby.Lock.Unlock()
-
-	broadcastLobbyUpdate(lobby)
+	lobby.GameStart = append(lobby.GameStart, PlayerStarted{ID: playerID})
+	lobby.Version++
+	return syncLobbyGame(lobby), "", ""
 }
 
 func cancelGameHandler(msg CancelGame) {
+	activePlayersLock.RLock()
+	player, ok := activePlayers[msg.PlayerID]
+	activePlayersLock.RUnlock()
+	if !ok {
+		log.Println("cancelGameHandler: Player not found")
+		disconnectPlayer(msg.PlayerID)
+		return
+	}
+
 	lobbiesLock.RLock()
 	lobby, ok := lobbies[msg.LobbyID]
 	lobbiesLock.RUnlock()
 	if !ok {
-		log.Println("cancelGameHandler: Lobby not found")
+		sendErrorToPlayer(player, msg.RequestID, ErrorLobbyNotFound, "Lobby not found")
 		return
 	}
 
-	activePlayersLock.RLock()
-	player, ok := activePlayers[msg.PlayerID]
-	activePlayersLock.RUnlock()
-	if !ok {
-		log.Println("cancelGameHandler: Player not found")
-		disconnectPlayer(msg.PlayerID)
+	if code, errMsg := withdrawStart(lobby, player.ID); code != "" {
+		sendErrorToPlayer(player, msg.RequestID, code, errMsg)
 		return
 	}
 
+	broadcastLobbyUpdate(lobby)
+	broadcastLobbyChanged(lobby)
+}
+
+// withdrawStart takes back the player's start before the game runs
+func withdrawStart(lobby *Lobby, playerID string) (ErrorCode, string) {
 	lobby.Lock.Lock()
+	defer lobby.Lock.Unlock()
+
+	if !isSeated(lobby, playerID) {
+		return ErrorNotInLobby, "You are not in this lobby"
+	}
+	// A running game is not called off by one player
+	if lobby.Game != nil {
+		return ErrorGameAlreadyStarted, "The game has already started"
+	}
 	for i := len(lobby.GameStart) - 1; i >= 0; i-- {
-		if lobby.GameStart[i].ID == player.ID {
+		if lobby.GameStart[i].ID == playerID {
 			lobby.GameStart = append(lobby.GameStart[:i], lobby.GameStart[i+1:]...)
+			lobby.Version++
 			break
 		}
 	}
-	lobby.Lock.Unlock()
+	return "", ""
+}
 
-	broadcastLobbyUpdate(lobby)
+func resyncHandler(msg ResyncRequest) {
+	activePlayersLock.RLock()
+	player, ok := activePlayers[msg.PlayerID]
+	activePlayersLock.RUnlock()
+	if !ok {
+		log.Println("resyncHandler: Player not found")
+		disconnectPlayer(msg.PlayerID)
+		return
+	}
+
+	var currentLobby *LobbyDTO
+	var currentGame *game.View
+	if lobby := findPlayerLobby(player.ID); lobby != nil {
+		dto, view := resyncLobby(lobby, player)
+		currentLobby, currentGame = &dto, view
+	}
+
+	subscribedLobbies, _ := player.lobbySub.reset(getSortedLobbiesList())
+	sendResponse(player, SnapshotResponse{
+		BaseResponse:          newReplyResponse(ResponseSnapshot, msg.RequestID),
+		Player:                PlayerDTO{ID: player.ID, Name: player.Name},
+		Lobbies:               subscribedLobbies,
+		Lobby:                 currentLobby,
+		Game:                  currentGame,
+		PendingFriendRequests: getPendingFriendRequests(player.ID),
+		FriendsList:           getFriendsWithOnlineStatus(player.ID),
+	})
+}
+
+// resyncLobby returns the lobby and the player's view of its game, or a
+// nil view if no game runs
+func resyncLobby(lobby *Lobby, player *Player) (LobbyDTO, *game.View) {
+	lobby.Lock.RLock()
+	defer lobby.Lock.RUnlock()
+
+	if lobby.Game == nil {
+		return toLobbyDTO(lobby), nil
+	}
+	view := lobby.Game.ViewFor(actingPlayerID(lobby, player))
+	return toLobbyDTO(lobby), &view
 }
 
 func sendFriendRequestHandler(msg AddFriendRequest) {
@@ -249,33 +353,33 @@ func sendFriendRequestHandler(msg AddFriendRequest) {
 
 	friendID, friendOK := getPlayerIdByName(msg.FriendName)
 	if !friendOK {
-		sendFriendRequestResult(player, false, "Player not found")
+		sendFriendRequestResult(player, msg.RequestID, ErrorPlayerNotFound, "Player not found")
 		return
 	}
 
 	if msg.PlayerID == friendID {
-		sendFriendRequestResult(player, false, "You cannot add yourself")
+		sendFriendRequestResult(player, msg.RequestID, ErrorCannotAddSelf, "You cannot add yourself")
 		return
 	}
 
 	if areFriends(msg.PlayerID, friendID) {
-		sendFriendRequestResult(player, false, "You are already friends")
+		sendFriendRequestResult(player, msg.RequestID, ErrorAlreadyFriends, "You are already friends")
 		return
 	}
 
 	err := createFriendRequest(msg.PlayerID, friendID)
 	if err != nil {
 		if errors.Is(err, ErrFriendRequestExists) {
-			sendFriendRequestResult(player, false, "Friend request already sent")
+			sendFriendRequestResult(player, msg.RequestID, ErrorFriendRequestExists, "Friend request already sent")
 			return
 		}
 
 		log.Printf("sendFriendRequestHandler: %v", err)
-		sendFriendRequestResult(player, false, "Error creating friend request")
+		sendFriendRequestResult(player, msg.RequestID, ErrorInternal, "Error creating friend request")
 		return
 	}
 
-	sendFriendRequestResult(player, true, "Friend request sent")
+	sendFriendRequestResult(player, msg.RequestID, "", "Friend request sent")
 
 	activePlayersLock.RLock()
 	friend, ok := activePlayers[friendID]
@@ -291,14 +395,32 @@ func sendFriendRequestHandler(msg AddFriendRequest) {
 	})
 }
 
-func sendFriendRequestResult(player *Player, success bool, message string) {
+// sendFriendRequestResult answers an add_friend request, an empty code means success
+func sendFriendRequestResult(player *Player, requestID string, code ErrorCode, message string) {
 	sendResponse(player, FriendRequestSentResponse{
-		BaseResponse: newBaseResponse(ResponseFriendRequestSent),
-		Success:      success,
+		BaseResponse: newReplyResponse(ResponseFriendRequestSent, requestID),
+		Success:      code == "",
+		Code:         code,
 		Message:      message,
 	})
 }
 
+func getPendingFriendRequestsHandler(msg GetPendingFriendRequestsRequest) {
+	activePlayersLock.RLock()
+	player, ok := activePlayers[msg.PlayerID]
+	activePlayersLock.RUnlock()
+	if !ok {
+		log.Println("getPendingFriendRequestsHandler: Player not found")
+		disconnectPlayer(msg.PlayerID)
+		return
+	}
+
+	sendResponse(player, PendingFriendRequestsResponse{
+		BaseResponse:          newReplyResponse(ResponsePendingFriendRequests, msg.RequestID),
+		PendingFriendRequests: getPendingFriendRequests(player.ID),
+	})
+}
+
 func acceptFriendRequestHandler(msg AcceptFriendRequestRequest) {
 	friendID := msg.FriendID
 	playerID := msg.PlayerID
@@ -311,7 +433,11 @@ func acceptFriendRequestHandler(msg AcceptFriendRequestRequest) {
 		activePlayersLock.RUnlock()
 
 		if ok {
-			sendErrorToPlayer(player, "Error handling friend request")
+			code := ErrorInternal
+			if errors.Is(err, ErrFriendRequestNotFound) {
+				code = ErrorFriendRequestNotFound
+			}
+			sendErrorToPlayer(player, msg.RequestID, code, "Error handling friend request")
 		}
 
 		log.Printf("acceptFriendRequestHandler: %v", err)
@@ -329,7 +455,7 @@ func acceptFriendRequestHandler(msg AcceptFriendRequestRequest) {
 	}
 	pendingFriendRequestsPlayer := getPendingFriendRequests(playerID)
 	pendingFriendRequestsResponsePlayer := PendingFriendRequestsResponse{
-		BaseResponse:          newBaseResponse(ResponsePendingFriendRequests),
+		BaseResponse:          newReplyResponse(ResponsePendingFriendRequests, msg.RequestID),
 		PendingFriendRequests: pendingFriendRequestsPlayer,
 	}
 	sendResponse(player, pendingFriendRequestsResponsePlayer)
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// lastError returns the code of the last error queued for the player
+func lastError(t *testing.T, p *Player) ErrorCode {
+	t.Helper()
+	var code ErrorCode
+	for {
+		select {
+		case msg := <-p.Send:
+			var r ErrorResponse
+			if err := json.Unmarshal(msg, &r); err != nil {
+				t.Fatal(err)
+			}
+			if r.Type == ResponseError {
+				code = r.Code
+			}
+		default:
+			return code
+		}
+	}
+}
+
+func TestStartGameNeedsASeat(t *testing.T) {
+	useTestLobbies(t)
+
+	host, guest, stranger := newPlayer("host", "Host", nil), newPlayer("guest", "Guest", nil), newPlayer("stranger", "Stranger", nil)
+	for _, p := range []*Player{host, guest, stranger} {
+		activePlayers[p.ID] = p
+	}
+	lobby := &Lobby{ID: "lobby", MaxPlayers: 2, Players: []*Player{host, guest}, GameStart: []PlayerStarted{}, Version: 1}
+	lobbies[lobby.ID] = lobby
+
+	startGameHandler(StartGame{BaseRequest: BaseRequest{PlayerID: host.ID}, LobbyID: lobby.ID})
+	startGameHandler(StartGame{BaseRequest: BaseRequest{PlayerID: stranger.ID}, LobbyID: lobby.ID})
+	if code := lastError(t, stranger); code != ErrorNotInLobby {
+		t.Errorf("start_game of a stranger answered %q, want %q", code, ErrorNotInLobby)
+	}
+	if len(lobby.GameStart) != 1 || lobby.Game != nil {
+		t.Fatalf("a stranger pressed start: %+v, game %v", lobby.GameStart, lobby.Game)
+	}
+
+	cancelGameHandler(CancelGame{BaseRequest: BaseRequest{PlayerID: stranger.ID}, LobbyID: lobby.ID})
+	if code := lastError(t, stranger); code != ErrorNotInLobby {
+		t.Errorf("cancel_game of a stranger answered %q, want %q", code, ErrorNotInLobby)
+	}
+}
+
+func TestLeavingKeepsTheGameRunning(t *testing.T) {
+	useTestLobbies(t)
+
+	alice, bob := newPlayer("p1", "Alice", nil), newPlayer("p2", "Bob", nil)
+	activePlayers[alice.ID] = alice
+	activePlayers[bob.ID] = bob
+	lobby := &Lobby{
+		ID:         "lobby-1",
+		MaxPlayers: 2,
+		Players:    []*Player{alice, bob},
+		GameStart:  []PlayerStarted{{ID: "p1"}, {ID: "p2"}},
+		Version:    1,
+	}
+	syncLobbyGame(lobby)
+	lobbies[lobby.ID] = lobby
+	g := lobby.Game
+
+	leaveLobbyHandler(LeaveLobbyRequest{BaseRequest: BaseRequest{PlayerID: bob.ID}, LobbyID: lobby.ID})
+	if lobby.Game != g || len(lobby.Players) != 2 || !lobby.Players[1].offline() {
+		t.Fatalf("after the leave: game %p, players %+v", lobby.Game, lobby.Players)
+	}
+
+	// Leaving the last seat closes the lobby, also for a join that found it before
+	releaseOfflineSeat(lobby, lobby.Players[1])
+	leaveLobbyHandler(LeaveLobbyRequest{BaseRequest: BaseRequest{PlayerID: alice.ID}, LobbyID: lobby.ID})
+	if _, ok := lobbies[lobby.ID]; ok || !lobby.removed {
+		t.Fatal("empty lobby was not removed")
+	}
+	carol := newPlayer("p3", "Carol", nil)
+	activePlayers[carol.ID] = carol
+	lobbies[lobby.ID] = lobby
+	joinLobbyHandler(JoinLobbyRequest{BaseRequest: BaseRequest{PlayerID: carol.ID}, LobbyID: lobby.ID})
+	if len(lobby.Players) != 0 {
+		t.Errorf("joined a removed lobby: %+v", lobby.Players)
+	}
+}
@@ -0,0 +1,708 @@
+// Package protogen derives a JSON Schema document and TypeScript definitions
+// from the Go protocol types, so that the client never has to duplicate them
+// by hand.
+//
+// Every exported struct with at least one json tag is a protocol type, and
+// every exported named string or number type with typed constants becomes an
+// enum. A type whose doc comment contains "protogen:ignore" is skipped.
+package protogen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Paths relative to the module root
+const (
+	SchemaFile     = "protocol.schema.json"
+	TypeScriptFile = "client/src/protocol.ts"
+)
+
+// DefaultDirs are the packages holding protocol types, relative to the module root
+var DefaultDirs = []string{".", "game"}
+
+const generatedNotice = "Code generated by protogen from the Go protocol types. DO NOT EDIT."
+
+type Output struct {
+	Schema     []byte
+	TypeScript []byte
+}
+
+type enumType struct {
+	name   string
+	doc    string
+	basic  string // "string" or "number"
+	values []enumValue
+}
+
+type enumValue struct {
+	name  string
+	value any
+	doc   string
+}
+
+type structType struct {
+	name   string
+	doc    string
+	fields []*ast.Field
+	tagged bool     // has at least one json tag
+	embeds []string // names of embedded types
+}
+
+type field struct {
+	jsonName string
+	typ      ast.Expr
+	optional bool
+	doc      string
+}
+
+type model struct {
+	enums      []*enumType
+	structs    []*structType
+	candidates []*structType // every exported struct, narrowed down to structs in resolve
+	enumsByNm  map[string]*enumType
+	structsBy  map[string]*structType
+	aliases    map[string]string // named basic types without constants
+}
+
+// Generate parses the Go files in dirs and renders both documents.
+func Generate(dirs ...string) (Output, error) {
+	m := &model{
+		enumsByNm: make(map[string]*enumType),
+		structsBy: make(map[string]*structType),
+		aliases:   make(map[string]string),
+	}
+
+	for _, dir := range dirs {
+		if err := m.parseDir(dir); err != nil {
+			return Output{}, err
+		}
+	}
+	m.resolve()
+
+	ts, err := m.typeScript()
+	if err != nil {
+		return Output{}, err
+	}
+	schema, err := m.schema()
+	if err != nil {
+		return Output{}, err
+	}
+
+	return Output{Schema: schema, TypeScript: ts}, nil
+}
+
+func (m *model) parseDir(dir string) error {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", dir, err)
+	}
+
+	for _, pkg := range pkgs {
+		files := make([]string, 0, len(pkg.Files))
+		for name := range pkg.Files {
+			files = append(files, name)
+		}
+		sort.Strings(files)
+
+		// Types first, so constants can find their enum
+		for _, name := range files {
+			if err := m.collectTypes(pkg.Files[name]); err != nil {
+				return fmt.Errorf("%s: %w", filepath.Base(name), err)
+			}
+		}
+		for _, name := range files {
+			m.collectConsts(pkg.Files[name])
+		}
+	}
+
+	// Named basic types without constants are plain aliases
+	kept := m.enums[:0]
+	for _, e := range m.enums {
+		if len(e.values) == 0 {
+			delete(m.enumsByNm, e.name)
+			m.aliases[e.name] = e.basic
+			continue
+		}
+		kept = append(kept, e)
+	}
+	m.enums = kept
+
+	return nil
+}
+
+func (m *model) collectTypes(file *ast.File) error {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if !ts.Name.IsExported() || ts.TypeParams != nil {
+				continue
+			}
+
+			doc := docText(ts.Doc)
+			if doc == "" && len(gen.Specs) == 1 {
+				doc = docText(gen.Doc)
+			}
+			if strings.Contains(doc, "protogen:ignore") {
+				continue
+			}
+
+			name := ts.Name.Name
+			if m.declared(name) {
+				return fmt.Errorf("duplicate protocol type %s", name)
+			}
+
+			switch t := ts.Type.(type) {
+			case *ast.StructType:
+				st := &structType{name: name, doc: doc, fields: t.Fields.List, tagged: hasJSONTags(t)}
+				for _, f := range t.Fields.List {
+					if ident, ok := f.Type.(*ast.Ident); ok && len(f.Names) == 0 {
+						st.embeds = append(st.embeds, ident.Name)
+					}
+				}
+				m.candidates = append(m.candidates, st)
+
+			case *ast.Ident:
+				basic := basicKind(t.Name)
+				if basic == "" {
+					continue
+				}
+				e := &enumType{name: name, doc: doc, basic: basic}
+				m.enums = append(m.enums, e)
+				m.enumsByNm[name] = e
+			}
+		}
+	}
+	return nil
+}
+
+// resolve keeps the structs that have json tags or embed a protocol struct,
+// like a request that carries nothing but its BaseRequest.
+func (m *model) resolve() {
+	for changed := true; changed; {
+		changed = false
+		for _, st := range m.candidates {
+			if _, done := m.structsBy[st.name]; done {
+				continue
+			}
+			protocol := st.tagged
+			for _, name := range st.embeds {
+				if _, ok := m.structsBy[name]; ok {
+					protocol = true
+				}
+			}
+			if protocol {
+				m.structsBy[st.name] = st
+				changed = true
+			}
+		}
+	}
+
+	// Keep source order
+	for _, st := range m.candidates {
+		if m.structsBy[st.name] == st {
+			m.structs = append(m.structs, st)
+		}
+	}
+}
+
+func (m *model) declared(name string) bool {
+	if _, exists := m.enumsByNm[name]; exists {
+		return true
+	}
+	for _, st := range m.candidates {
+		if st.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *model) collectConsts(file *ast.File) {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+
+		for _, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			ident, ok := vs.Type.(*ast.Ident)
+			if !ok || len(vs.Values) != len(vs.Names) {
+				continue
+			}
+			e, ok := m.enumsByNm[ident.Name]
+			if !ok {
+				continue
+			}
+
+			for i, name := range vs.Names {
+				lit, ok := vs.Values[i].(*ast.BasicLit)
+				if !ok || !name.IsExported() {
+					continue
+				}
+				value, err := literalValue(lit)
+				if err != nil {
+					continue
+				}
+
+				doc := docText(vs.Doc)
+				if doc == "" {
+					doc = docText(vs.Comment)
+				}
+				e.values = append(e.values, enumValue{name: name.Name, value: value, doc: doc})
+			}
+		}
+	}
+}
+
+// flatten resolves embedded structs and json tags into the wire fields
+func (m *model) flatten(st *structType) ([]field, error) {
+	return m.fieldsOf(st, true)
+}
+
+// fieldsOf returns the wire fields of st, with or without the fields of
+// embedded structs.
+func (m *model) fieldsOf(st *structType, inline bool) ([]field, error) {
+	var fields []field
+	for _, f := range st.fields {
+		tag := ""
+		if f.Tag != nil {
+			raw, _ := strconv.Unquote(f.Tag.Value)
+			tag = reflect.StructTag(raw).Get("json")
+		}
+
+		if len(f.Names) == 0 {
+			if tag != "" {
+				return nil, fmt.Errorf("%s: tagged embedded fields are not supported", st.name)
+			}
+			ident, ok := f.Type.(*ast.Ident)
+			if !ok {
+				return nil, fmt.Errorf("%s: unsupported embedded field", st.name)
+			}
+			embedded, ok := m.structsBy[ident.Name]
+			if !ok {
+				return nil, fmt.Errorf("%s: embedded type %s is not a protocol type", st.name, ident.Name)
+			}
+			if !inline {
+				continue
+			}
+			inner, err := m.flatten(embedded)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, inner...)
+			continue
+		}
+
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		optional := false
+		for _, opt := range parts[1:] {
+			if opt == "omitempty" || opt == "omitzero" {
+				optional = true
+			}
+		}
+
+		doc := docText(f.Doc)
+		if doc == "" {
+			doc = docText(f.Comment)
+		}
+
+		for _, name := range f.Names {
+			if !name.IsExported() {
+				continue
+			}
+			jsonName := parts[0]
+			if jsonName == "" {
+				jsonName = name.Name
+			}
+			fields = append(fields, field{jsonName: jsonName, typ: f.Type, optional: optional, doc: doc})
+		}
+	}
+	return fields, nil
+}
+
+func (m *model) typeScript() ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// %s\n", generatedNotice)
+	fmt.Fprintf(&b, "// Run `go generate` in the module root after changing them.\n")
+
+	for _, e := range m.enums {
+		b.WriteString("\n")
+		writeTSDoc(&b, "", e.doc)
+		fmt.Fprintf(&b, "export const %s = {\n", pluralize(e.name))
+		for _, v := range e.values {
+			writeTSDoc(&b, "  ", v.doc)
+			fmt.Fprintf(&b, "  %s: %s,\n", v.name, tsLiteral(v.value))
+		}
+		b.WriteString("} as const;\n\n")
+		fmt.Fprintf(&b, "export type %s = (typeof %s)[keyof typeof %s];\n", e.name, pluralize(e.name), pluralize(e.name))
+	}
+
+	for _, name := range sortedKeys(m.aliases) {
+		fmt.Fprintf(&b, "\nexport type %s = %s;\n", name, m.aliases[name])
+	}
+
+	for _, st := range m.structs {
+		fields, err := m.fieldsOf(st, false)
+		if err != nil {
+			return nil, err
+		}
+
+		b.WriteString("\n")
+		writeTSDoc(&b, "", st.doc)
+
+		if len(fields) == 0 && len(st.embeds) > 0 {
+			fmt.Fprintf(&b, "export type %s = %s;\n", st.name, strings.Join(st.embeds, " & "))
+			continue
+		}
+
+		extends := ""
+		if len(st.embeds) > 0 {
+			extends = " extends " + strings.Join(st.embeds, ", ")
+		}
+		fmt.Fprintf(&b, "export interface %s%s {\n", st.name, extends)
+		for _, f := range fields {
+			typ, err := m.tsType(f.typ)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", st.name, f.jsonName, err)
+			}
+			writeTSDoc(&b, "  ", f.doc)
+			opt := ""
+			if f.optional {
+				opt = "?"
+			}
+			fmt.Fprintf(&b, "  %s%s: %s;\n", f.jsonName, opt, typ)
+		}
+		b.WriteString("}\n")
+	}
+
+	return b.Bytes(), nil
+}
+
+func (m *model) tsType(e ast.Expr) (string, error) {
+	switch t := e.(type) {
+	case *ast.Ident:
+		if t.Name == "any" {
+			return "unknown", nil
+		}
+		if basic := basicKind(t.Name); basic != "" {
+			return basic, nil
+		}
+		return m.namedType(t.Name)
+
+	case *ast.StarExpr:
+		inner, err := m.tsType(t.X)
+		if err != nil {
+			return "", err
+		}
+		return inner + " | null", nil
+
+	case *ast.ArrayType:
+		if ident, ok := t.Elt.(*ast.Ident); ok && ident.Name == "byte" {
+			return "string", nil
+		}
+		inner, err := m.tsType(t.Elt)
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(inner, " ") {
+			inner = "(" + inner + ")"
+		}
+		return inner + "[]", nil
+
+	case *ast.MapType:
+		inner, err := m.tsType(t.Value)
+		if err != nil {
+			return "", err
+		}
+		return "Record<string, " + inner + ">", nil
+
+	case *ast.SelectorExpr:
+		switch qualifiedName(t) {
+		case "time.Time":
+			return "string", nil
+		case "time.Duration":
+			return "number", nil
+		case "json.RawMessage":
+			return "unknown", nil
+		}
+		return m.namedType(t.Sel.Name)
+
+	case *ast.InterfaceType:
+		return "unknown", nil
+	}
+
+	return "", fmt.Errorf("unsupported type %T", e)
+}
+
+func (m *model) namedType(name string) (string, error) {
+	if _, ok := m.structsBy[name]; ok {
+		return name, nil
+	}
+	if _, ok := m.enumsByNm[name]; ok {
+		return name, nil
+	}
+	if _, ok := m.aliases[name]; ok {
+		return name, nil
+	}
+	return "", fmt.Errorf("type %s is not a protocol type", name)
+}
+
+func (m *model) schema() ([]byte, error) {
+	defs := make(map[string]any)
+
+	for _, e := range m.enums {
+		values := make([]any, 0, len(e.values))
+		for _, v := range e.values {
+			values = append(values, v.value)
+		}
+		def := map[string]any{"type": schemaBasic(e.basic), "enum": values}
+		if e.doc != "" {
+			def["description"] = e.doc
+		}
+		defs[e.name] = def
+	}
+
+	for name, basic := range m.aliases {
+		defs[name] = map[string]any{"type": schemaBasic(basic)}
+	}
+
+	for _, st := range m.structs {
+		fields, err := m.flatten(st)
+		if err != nil {
+			return nil, err
+		}
+
+		props := make(map[string]any)
+		required := make([]string, 0)
+		for _, f := range fields {
+			prop, err := m.schemaType(f.typ)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", st.name, f.jsonName, err)
+			}
+			if f.doc != "" {
+				prop["description"] = f.doc
+			}
+			props[f.jsonName] = prop
+			if !f.optional {
+				required = append(required, f.jsonName)
+			}
+		}
+
+		def := map[string]any{
+			"type":       "object",
+			"properties": props,
+			"required":   required,
+		}
+		if st.doc != "" {
+			def["description"] = st.doc
+		}
+		defs[st.name] = def
+	}
+
+	doc := map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"title":       "Kartenspiel websocket protocol",
+		"description": generatedNotice,
+		"$defs":       defs,
+	}
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+func (m *model) schemaType(e ast.Expr) (map[string]any, error) {
+	switch t := e.(type) {
+	case *ast.Ident:
+		if t.Name == "any" {
+			return map[string]any{}, nil
+		}
+		if basic := basicKind(t.Name); basic != "" {
+			return map[string]any{"type": schemaBasicFor(t.Name)}, nil
+		}
+		if _, err := m.namedType(t.Name); err != nil {
+			return nil, err
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Name}, nil
+
+	case *ast.StarExpr:
+		inner, err := m.schemaType(t.X)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"anyOf": []any{inner, map[string]any{"type": "null"}}}, nil
+
+	case *ast.ArrayType:
+		if ident, ok := t.Elt.(*ast.Ident); ok && ident.Name == "byte" {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}, nil
+		}
+		inner, err := m.schemaType(t.Elt)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": inner}, nil
+
+	case *ast.MapType:
+		inner, err := m.schemaType(t.Value)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "additionalProperties": inner}, nil
+
+	case *ast.SelectorExpr:
+		switch qualifiedName(t) {
+		case "time.Time":
+			return map[string]any{"type": "string", "format": "date-time"}, nil
+		case "time.Duration":
+			return map[string]any{"type": "integer"}, nil
+		case "json.RawMessage":
+			return map[string]any{}, nil
+		}
+		if _, err := m.namedType(t.Sel.Name); err != nil {
+			return nil, err
+		}
+		return map[string]any{"$ref": "#/$defs/" + t.Sel.Name}, nil
+
+	case *ast.InterfaceType:
+		return map[string]any{}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported type %T", e)
+}
+
+func hasJSONTags(t *ast.StructType) bool {
+	for _, f := range t.Fields.List {
+		if f.Tag != nil && strings.Contains(f.Tag.Value, `json:"`) {
+			return true
+		}
+	}
+	return false
+}
+
+// basicKind maps a Go basic type to its TypeScript type, "" if not basic
+func basicKind(name string) string {
+	switch name {
+	case "string":
+		return "string"
+	case "bool":
+		return "boolean"
+	case "int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64",
+		"float32", "float64", "byte", "rune":
+		return "number"
+	}
+	return ""
+}
+
+func schemaBasic(tsBasic string) string {
+	switch tsBasic {
+	case "boolean":
+		return "boolean"
+	case "number":
+		return "number"
+	}
+	return "string"
+}
+
+func schemaBasicFor(goName string) string {
+	if strings.HasPrefix(goName, "float") {
+		return "number"
+	}
+	if basicKind(goName) == "number" {
+		return "integer"
+	}
+	return schemaBasic(basicKind(goName))
+}
+
+func literalValue(lit *ast.BasicLit) (any, error) {
+	switch lit.Kind {
+	case token.STRING:
+		return strconv.Unquote(lit.Value)
+	case token.INT:
+		return strconv.ParseInt(lit.Value, 0, 64)
+	case token.FLOAT:
+		return strconv.ParseFloat(lit.Value, 64)
+	}
+	return nil, fmt.Errorf("unsupported literal %s", lit.Value)
+}
+
+func tsLiteral(v any) string {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(v)
+}
+
+func qualifiedName(t *ast.SelectorExpr) string {
+	if pkg, ok := t.X.(*ast.Ident); ok {
+		return pkg.Name + "." + t.Sel.Name
+	}
+	return t.Sel.Name
+}
+
+func docText(g *ast.CommentGroup) string {
+	if g == nil {
+		return ""
+	}
+	return strings.TrimSpace(g.Text())
+}
+
+func writeTSDoc(b *bytes.Buffer, indent, doc string) {
+	if doc == "" {
+		return
+	}
+	lines := strings.Split(doc, "\n")
+	if len(lines) == 1 {
+		fmt.Fprintf(b, "%s/** %s */\n", indent, lines[0])
+		return
+	}
+	fmt.Fprintf(b, "%s/**\n", indent)
+	for _, line := range lines {
+		fmt.Fprintf(b, "%s * %s\n", indent, line)
+	}
+	fmt.Fprintf(b, "%s */\n", indent)
+}
+
+func pluralize(name string) string {
+	switch {
+	case strings.HasSuffix(name, "s"):
+		return name + "es"
+	case strings.HasSuffix(name, "y"):
+		return strings.TrimSuffix(name, "y") + "ies"
+	}
+	return name + "s"
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
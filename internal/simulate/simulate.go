@@ -0,0 +1,251 @@
+// Package simulate plays complete games between bots inside the process, to
+// balance the rules and to find engine bugs. A bot picks one of its legal
+// moves at random and gives a random card to its partner.
+package simulate
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+
+	"github.com/Daweenci/Web_Lobby/game"
+)
+
+// Config says which games to play. Game i is dealt with seed FirstSeed+i,
+// so a run can be repeated and a single game replayed.
+type Config struct {
+	Games     int
+	FirstSeed uint64
+	Players   int
+	Teams     bool // partners sit opposite, needs four players
+	Rules     game.Rules
+	MaxTurns  int // a game without a winner after this many turns is stopped
+}
+
+// Report sums up a run. A turn is a played card or a player without a legal
+// move; lengths only count finished games.
+type Report struct {
+	Games      int        `json:"games"`
+	FirstSeed  uint64     `json:"firstSeed"`
+	Players    int        `json:"players"`
+	Teams      bool       `json:"teams"`
+	Rules      game.Rules `json:"rules"`
+	Unfinished int        `json:"unfinished"` // games stopped after MaxTurns
+
+	// Wins counts the games won per seat. In team play both partners win,
+	// so the rates add up to 2.
+	Wins    []int     `json:"wins"`
+	WinRate []float64 `json:"winRate"`
+
+	AverageTurns  float64 `json:"averageTurns"`
+	AverageRounds float64 `json:"averageRounds"`
+
+	// CardsPlayed counts the played cards by type, a joker counts as joker
+	CardsPlayed map[string]int `json:"cardsPlayed"`
+
+	Turns           int     `json:"turns"`
+	NoLegalMove     int     `json:"noLegalMove"`     // turns of players with cards but no legal move
+	NoLegalMoveRate float64 `json:"noLegalMoveRate"` // NoLegalMove per turn
+}
+
+// Run plays the games of c. It stops at the first error of the engine and
+// returns it with the seed and position of the game.
+func Run(c Config) (*Report, error) {
+	if c.Players < game.MinPlayers || c.Players > game.MaxPlayers || c.Teams && c.Players != 4 {
+		return nil, fmt.Errorf("can not seat %d players, a game has %d to %d and team play needs 4", c.Players, game.MinPlayers, game.MaxPlayers)
+	}
+	if err := c.Rules.Validate(); err != nil {
+		return nil, err
+	}
+	deck, err := game.StandardDeck()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{
+		Games:       c.Games,
+		FirstSeed:   c.FirstSeed,
+		Players:     c.Players,
+		Teams:       c.Teams,
+		Rules:       c.Rules,
+		Wins:        make([]int, c.Players),
+		WinRate:     make([]float64, c.Players),
+		CardsPlayed: make(map[string]int),
+	}
+	finishedTurns, finishedRounds := 0, 0
+	for i := range c.Games {
+		s := &simulation{config: c, report: r, seed: c.FirstSeed + uint64(i)}
+		g, err := s.play(deck)
+		if err != nil {
+			return nil, fmt.Errorf("seed %d: %w\nposition: %s", s.seed, err, g.Notation())
+		}
+		if len(g.Winners) == 0 {
+			r.Unfinished++
+			continue
+		}
+		for seat, p := range g.Players {
+			if slices.Contains(g.Winners, p.ID) {
+				r.Wins[seat]++
+			}
+		}
+		finishedTurns += s.turns
+		finishedRounds += g.Round
+	}
+
+	if finished := c.Games - r.Unfinished; finished > 0 {
+		r.AverageTurns = float64(finishedTurns) / float64(finished)
+		r.AverageRounds = float64(finishedRounds) / float64(finished)
+	}
+	if c.Games > 0 {
+		for seat, wins := range r.Wins {
+			r.WinRate[seat] = float64(wins) / float64(c.Games)
+		}
+	}
+	if r.Turns > 0 {
+		r.NoLegalMoveRate = float64(r.NoLegalMove) / float64(r.Turns)
+	}
+	return r, nil
+}
+
+// simulation is one game of a run
+type simulation struct {
+	config Config
+	report *Report
+	seed   uint64
+	turns  int
+}
+
+func (s *simulation) play(deck *game.DeckDefinition) (*game.Game, error) {
+	players := make([]game.PlayerInGame, s.config.Players)
+	for seat := range players {
+		players[seat] = game.PlayerInGame{ID: "p" + strconv.Itoa(seat), Name: "Bot " + strconv.Itoa(seat)}
+	}
+	if s.config.Teams {
+		for seat := range players {
+			players[seat].Partner = players[(seat+2)%4].ID
+		}
+	}
+
+	g := game.NewGame(players, deck, s.config.Rules, rand.New(rand.NewPCG(s.seed, 0)))
+	bot := rand.New(rand.NewPCG(s.seed, 1))
+	s.record(g.Advance())
+
+	for len(g.Winners) == 0 && s.turns < s.config.MaxTurns {
+		if g.Phase == game.PhaseExchange {
+			if err := s.exchange(g, bot); err != nil {
+				return g, err
+			}
+			s.record(g.Advance())
+			continue
+		}
+
+		p := g.CurrentPlayer()
+		moves, err := g.LegalMoves(p.ID)
+		if err != nil {
+			return g, err
+		}
+		if len(moves) == 0 {
+			return g, fmt.Errorf("no legal move for %s after Advance", p.ID)
+		}
+		move := moves[bot.IntN(len(moves))]
+		i := slices.IndexFunc(p.Hand, func(c game.Card) bool { return c.ID == move.Play.CardID })
+		if i < 0 {
+			return g, fmt.Errorf("legal move %+v plays a card %s does not hold", move.Play, p.ID)
+		}
+		cardType := p.Hand[i].Type
+		if err := g.PlayCard(p.ID, move.Play); err != nil {
+			return g, fmt.Errorf("legal move %+v rejected: %w", move.Play, err)
+		}
+		s.report.CardsPlayed[cardType]++
+		s.report.Turns++
+		s.turns++
+		s.record(g.Advance())
+	}
+	return g, nil
+}
+
+// exchange lets every player that still has to choose give a random card
+// to the partner
+func (s *simulation) exchange(g *game.Game, bot *rand.Rand) error {
+	for _, p := range g.Players {
+		if p.Partner == "" || p.Exchange != nil {
+			continue
+		}
+		if len(p.Hand) == 0 {
+			return fmt.Errorf("%s has no card to exchange", p.ID)
+		}
+		if err := g.ExchangeCard(p.ID, p.Hand[bot.IntN(len(p.Hand))].ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record counts the players Advance skipped for lack of a legal move. They
+// pass if the rules allow passing, and only discard otherwise; with passing
+// allowed a discarded hand means every player passed and the round ended.
+func (s *simulation) record(progress game.Progress) {
+	for _, skip := range progress.Skips {
+		if skip.Passed || !s.config.Rules.AllowPass {
+			s.report.NoLegalMove++
+			s.report.Turns++
+			s.turns++
+		}
+	}
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes the report as metric,key,value rows. The key is the seat
+// for per seat metrics and the card type for cards_played.
+func (r *Report) WriteCSV(w io.Writer) error {
+	rows := [][]string{
+		{"metric", "key", "value"},
+		{"games", "", strconv.Itoa(r.Games)},
+		{"first_seed", "", strconv.FormatUint(r.FirstSeed, 10)},
+		{"players", "", strconv.Itoa(r.Players)},
+		{"teams", "", strconv.FormatBool(r.Teams)},
+		{"unfinished", "", strconv.Itoa(r.Unfinished)},
+	}
+	for seat, wins := range r.Wins {
+		rows = append(rows,
+			[]string{"wins", strconv.Itoa(seat), strconv.Itoa(wins)},
+			[]string{"win_rate", strconv.Itoa(seat), formatFloat(r.WinRate[seat])})
+	}
+	rows = append(rows,
+		[]string{"average_turns", "", formatFloat(r.AverageTurns)},
+		[]string{"average_rounds", "", formatFloat(r.AverageRounds)})
+
+	types := make([]string, 0, len(r.CardsPlayed))
+	for t := range r.CardsPlayed {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	for _, t := range types {
+		rows = append(rows, []string{"cards_played", t, strconv.Itoa(r.CardsPlayed[t])})
+	}
+
+	rows = append(rows,
+		[]string{"turns", "", strconv.Itoa(r.Turns)},
+		[]string{"no_legal_move", "", strconv.Itoa(r.NoLegalMove)},
+		[]string{"no_legal_move_rate", "", formatFloat(r.NoLegalMoveRate)})
+
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
@@ -0,0 +1,84 @@
+package simulate
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+
+	"github.com/Daweenci/Web_Lobby/game"
+)
+
+func TestRunIsRepeatable(t *testing.T) {
+	c := Config{Games: 3, FirstSeed: 7, Players: 4, Teams: true, Rules: game.Rules{AllowPass: true}, MaxTurns: 2000}
+	r, err := Run(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	won := 0
+	for _, wins := range r.Wins {
+		won += wins
+	}
+	if won != 2*(r.Games-r.Unfinished) {
+		t.Errorf("%d wins in %d finished team games", won, r.Games-r.Unfinished)
+	}
+	played := 0
+	for _, n := range r.CardsPlayed {
+		played += n
+	}
+	if played == 0 || played+r.NoLegalMove != r.Turns {
+		t.Errorf("%d cards played and %d turns without a legal move in %d turns", played, r.NoLegalMove, r.Turns)
+	}
+
+	again, err := Run(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, again) {
+		t.Errorf("same seeds, different reports:\n%+v\n%+v", r, again)
+	}
+}
+
+func TestRunRejectsBadConfigs(t *testing.T) {
+	for _, c := range []Config{
+		{Games: 1, Players: 1},
+		{Games: 1, Players: 5},
+		{Games: 1, Players: 3, Teams: true},
+		{Games: 1, Players: 2, Rules: game.Rules{TurnTimerSeconds: 1}},
+	} {
+		if _, err := Run(c); err == nil {
+			t.Errorf("Run(%+v) succeeded", c)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	r := &Report{Games: 2, Players: 2, Wins: []int{1, 1}, WinRate: []float64{0.5, 0.5}, CardsPlayed: map[string]int{"two": 3, "ace": 1}}
+	var buf bytes.Buffer
+	if err := r.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[[2]string]string{
+		{"games", ""}:           "2",
+		{"win_rate", "1"}:       "0.5000",
+		{"cards_played", "ace"}: "1",
+		{"cards_played", "two"}: "3",
+	}
+	for _, row := range rows {
+		if v, ok := want[[2]string{row[0], row[1]}]; ok {
+			if row[2] != v {
+				t.Errorf("%s %s = %s, want %s", row[0], row[1], row[2], v)
+			}
+			delete(want, [2]string{row[0], row[1]})
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("rows missing: %v", want)
+	}
+}
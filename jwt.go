@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -11,23 +10,20 @@ import (
 func generateJWT(playerID string) (string, error) {
 	claims := jwt.MapClaims{
 		"playerID": playerID,
-		"exp":      time.Now().Add(24 * time.Hour).Unix(),
+		"exp":      time.Now().Add(config.JWTTTL).Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
-	secret := os.Getenv("JWT_SECRET")
-	return token.SignedString([]byte(secret))
+	return token.SignedString([]byte(config.JWTSecret))
 }
 
 func parseJWT(tokenString string) (string, error) {
-	secret := os.Getenv("JWT_SECRET")
-
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return []byte(secret), nil
+		return []byte(config.JWTSecret), nil
 	})
 
 	if err != nil || !token.Valid {
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	LobbyVisibilityAll     string = ""
+	LobbyVisibilityPublic  string = "public"
+	LobbyVisibilityPrivate string = "private"
+
+	maxLobbyPageSize = 100
+)
+
+var (
+	ErrInvalidVisibility = errors.New("visibility must be public, private or empty")
+	ErrInvalidMaxPlayers = errors.New("maxPlayers must not be negative")
+	ErrInvalidPageSize   = errors.New("pageSize must be between 0 and 100")
+)
+
+// lobbySubscription is the part of the lobby list a player is looking at.
+// Only lobbies matching the filter are pushed, and with a page size at most
+// that many lobbies are visible at once.
+type lobbySubscription struct {
+	lock     sync.Mutex
+	filter   LobbyFilter
+	pageSize int             // 0 means no limit
+	visible  map[string]bool // lobby IDs the client currently has in its list
+}
+
+type lobbyDelta int
+
+const (
+	lobbyDeltaNone lobbyDelta = iota
+	lobbyDeltaAdded
+	lobbyDeltaChanged
+	lobbyDeltaRemoved
+)
+
+func newLobbySubscription(filter LobbyFilter, pageSize int) *lobbySubscription {
+	return &lobbySubscription{
+		filter:   filter,
+		pageSize: pageSize,
+		visible:  make(map[string]bool),
+	}
+}
+
+func (f LobbyFilter) validate() error {
+	switch f.Visibility {
+	case LobbyVisibilityAll, LobbyVisibilityPublic, LobbyVisibilityPrivate:
+	default:
+		return ErrInvalidVisibility
+	}
+	if f.MaxPlayers < 0 {
+		return ErrInvalidMaxPlayers
+	}
+	return nil
+}
+
+func (f LobbyFilter) matches(l LobbyDTO) bool {
+	if f.Search != "" && !strings.Contains(strings.ToLower(l.Name), strings.ToLower(f.Search)) {
+		return false
+	}
+	if f.MaxPlayers > 0 && l.MaxPlayers != f.MaxPlayers {
+		return false
+	}
+	if f.Visibility == LobbyVisibilityPublic && l.IsPrivate {
+		return false
+	}
+	if f.Visibility == LobbyVisibilityPrivate && !l.IsPrivate {
+		return false
+	}
+	if f.HasFreeSeats && len(l.Players) >= l.MaxPlayers {
+		return false
+	}
+	if f.NotStarted && l.Started {
+		return false
+	}
+	if f.Rated && !l.Rated {
+		return false
+	}
+	return true
+}
+
+// subscribe switches to a new filter and page size, see reset.
+func (s *lobbySubscription) subscribe(filter LobbyFilter, pageSize int, all []LobbyDTO) ([]LobbyDTO, int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.filter = filter
+	s.pageSize = pageSize
+	return s.resetLocked(all)
+}
+
+// reset replaces the visible lobbies with the first page of matching lobbies
+// and returns that page together with the number of all matching lobbies.
+func (s *lobbySubscription) reset(all []LobbyDTO) ([]LobbyDTO, int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.resetLocked(all)
+}
+
+func (s *lobbySubscription) resetLocked(all []LobbyDTO) ([]LobbyDTO, int) {
+	page := make([]LobbyDTO, 0)
+	total := 0
+	s.visible = make(map[string]bool)
+	for _, l := range all {
+		if !s.filter.matches(l) {
+			continue
+		}
+		total++
+		if s.pageSize == 0 || len(page) < s.pageSize {
+			page = append(page, l)
+			s.visible[l.ID] = true
+		}
+	}
+	return page, total
+}
+
+// apply records a change of a lobby and tells which delta, if any, the
+// client needs to see. A nil lobby means it was removed.
+func (s *lobbySubscription) apply(lobbyID string, lobby *LobbyDTO) lobbyDelta {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	matches := lobby != nil && s.filter.matches(*lobby)
+	visible := s.visible[lobbyID]
+
+	switch {
+	case visible && matches:
+		return lobbyDeltaChanged
+	case visible:
+		delete(s.visible, lobbyID)
+		return lobbyDeltaRemoved
+	case matches && (s.pageSize == 0 || len(s.visible) < s.pageSize):
+		s.visible[lobbyID] = true
+		return lobbyDeltaAdded
+	}
+	return lobbyDeltaNone
+}
+
+func (s *lobbySubscription) isPaged() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.pageSize > 0
+}
+
+// backfill fills a page that has free room again with the next matching
+// lobbies the client doesn't have yet.
+func (s *lobbySubscription) backfill(all []LobbyDTO) []LobbyDTO {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.pageSize == 0 {
+		return nil
+	}
+
+	var added []LobbyDTO
+	for _, l := range all {
+		if len(s.visible) >= s.pageSize {
+			break
+		}
+		if s.visible[l.ID] || !s.filter.matches(l) {
+			continue
+		}
+		s.visible[l.ID] = true
+		added = append(added, l)
+	}
+	return added
+}
+
+// getSortedLobbiesList returns all lobbies ordered by name, which is the
+// order pages are filled in.
+func getSortedLobbiesList() []LobbyDTO {
+	all := getLobbiesList()
+	sort.Slice(all, func(i, j int) bool {
+		a, b := strings.ToLower(all[i].Name), strings.ToLower(all[j].Name)
+		if a != b {
+			return a < b
+		}
+		return all[i].ID < all[j].ID
+	})
+	return all
+}
+
+// publishLobbyDelta pushes the change of one lobby to every player whose
+// subscription is affected. Each kind of delta is marshalled at most once
+// per codec and the same payload is shared across recipients.
+func publishLobbyDelta(lobbyID string, lobby *LobbyDTO) {
+	type payloadKey struct {
+		delta lobbyDelta
+		codec codec
+	}
+	payloads := make(map[payloadKey][]byte)
+	payload := func(delta lobbyDelta, c codec) []byte {
+		key := payloadKey{delta, c}
+		if msg, ok := payloads[key]; ok {
+			return msg
+		}
+
+		var r Response
+		switch delta {
+		case lobbyDeltaAdded:
+			r = LobbyAddedResponse{BaseResponse: newBaseResponse(ResponseLobbyAdded), Lobby: *lobby}
+		case lobbyDeltaChanged:
+			r = LobbyChangedResponse{BaseResponse: newBaseResponse(ResponseLobbyChanged), Lobby: *lobby}
+		default:
+			r = LobbyRemovedResponse{BaseResponse: newBaseResponse(ResponseLobbyRemoved), LobbyID: lobbyID}
+		}
+
+		msg, err := c.marshal(r)
+		if err != nil {
+			log.Printf("MessageType:%v. Marshal error: %v", r.GetType(), err)
+		}
+		payloads[key] = msg
+		return msg
+	}
+
+	// Only needed when a paged subscription lost a lobby
+	var sortedLobbies []LobbyDTO
+
+	activePlayersLock.RLock()
+	activePlayersCopy := make([]*Player, 0, len(activePlayers))
+	for _, p := range activePlayers {
+		activePlayersCopy = append(activePlayersCopy, p)
+	}
+	activePlayersLock.RUnlock()
+
+	for _, player := range activePlayersCopy {
+		delta := player.lobbySub.apply(lobbyID, lobby)
+		if delta == lobbyDeltaNone {
+			continue
+		}
+		if msg := payload(delta, player.codec); msg != nil {
+			player.enqueue(msg)
+		}
+
+		if delta == lobbyDeltaRemoved && player.lobbySub.isPaged() {
+			if sortedLobbies == nil {
+				sortedLobbies = getSortedLobbiesList()
+			}
+			for _, l := range player.lobbySub.backfill(sortedLobbies) {
+				sendResponse(player, LobbyAddedResponse{
+					BaseResponse: newBaseResponse(ResponseLobbyAdded),
+					Lobby:        l,
+				})
+			}
+		}
+	}
+}
+
+func subscribeLobbiesHandler(msg SubscribeLobbiesRequest) {
+	activePlayersLock.RLock()
+	player, ok := activePlayers[msg.PlayerID]
+	activePlayersLock.RUnlock()
+	if !ok {
+		log.Println("subscribeLobbiesHandler: Player not found")
+		disconnectPlayer(msg.PlayerID)
+		return
+	}
+
+	page, total := player.lobbySub.subscribe(msg.Filter, msg.PageSize, getSortedLobbiesList())
+	sendResponse(player, LobbiesUpdateResponse{
+		BaseResponse: newReplyResponse(ResponseLobbyList, msg.RequestID),
+		Lobbies:      page,
+		Total:        total,
+		PageSize:     msg.PageSize,
+	})
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Daweenci/Web_Lobby/game"
+)
+
+func TestLobbyFilterMatches(t *testing.T) {
+	open := LobbyDTO{Name: "Friday Night", MaxPlayers: 4, Players: make([]PlayerDTO, 2), Rated: true}
+	full := LobbyDTO{Name: "Full house", MaxPlayers: 2, Players: make([]PlayerDTO, 2), IsPrivate: true, Started: true}
+
+	for _, tc := range []struct {
+		filter     LobbyFilter
+		open, full bool
+	}{
+		{LobbyFilter{}, true, true},
+		{LobbyFilter{Search: "night"}, true, false},
+		{LobbyFilter{MaxPlayers: 2}, false, true},
+		{LobbyFilter{Visibility: LobbyVisibilityPublic}, true, false},
+		{LobbyFilter{Visibility: LobbyVisibilityPrivate}, false, true},
+		{LobbyFilter{HasFreeSeats: true}, true, false},
+		{LobbyFilter{NotStarted: true}, true, false},
+		{LobbyFilter{Rated: true}, true, false},
+	} {
+		if got := tc.filter.matches(open); got != tc.open {
+			t.Errorf("%+v matches %q = %v, want %v", tc.filter, open.Name, got, tc.open)
+		}
+		if got := tc.filter.matches(full); got != tc.full {
+			t.Errorf("%+v matches %q = %v, want %v", tc.filter, full.Name, got, tc.full)
+		}
+	}
+}
+
+func TestOnlyStandardLobbiesAreRated(t *testing.T) {
+	for _, tc := range []struct {
+		lobby *Lobby
+		rated bool
+	}{
+		{&Lobby{Rules: game.StandardRules()}, true},
+		{&Lobby{Rules: game.Rules{AllowPass: true}}, false},
+		{&Lobby{Rules: game.StandardRules(), Practice: true}, false},
+	} {
+		if got := toLobbyDTO(tc.lobby).Rated; got != tc.rated {
+			t.Errorf("rules %+v, practice %v: rated = %v", tc.lobby.Rules, tc.lobby.Practice, got)
+		}
+	}
+}
+
+func TestLobbyFilterValidate(t *testing.T) {
+	if err := (LobbyFilter{Visibility: "hidden"}).validate(); err != ErrInvalidVisibility {
+		t.Errorf("unknown visibility: %v", err)
+	}
+	if err := (LobbyFilter{MaxPlayers: -1}).validate(); err != ErrInvalidMaxPlayers {
+		t.Errorf("negative maxPlayers: %v", err)
+	}
+	if err := (LobbyFilter{Visibility: LobbyVisibilityPublic, MaxPlayers: 4}).validate(); err != nil {
+		t.Errorf("valid filter: %v", err)
+	}
+}
+
+func TestPagedSubscriptionBackfillsRemovedLobbies(t *testing.T) {
+	all := []LobbyDTO{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+	s := newLobbySubscription(LobbyFilter{}, 2)
+
+	page, total := s.reset(all)
+	if len(page) != 2 || total != 3 {
+		t.Fatalf("page of %d of %d lobbies, want 2 of 3", len(page), total)
+	}
+	if delta := s.apply("c", &all[2]); delta != lobbyDeltaNone {
+		t.Errorf("change of a lobby on no page: %v", delta)
+	}
+	if delta := s.apply("a", &all[0]); delta != lobbyDeltaChanged {
+		t.Errorf("change of a visible lobby: %v", delta)
+	}
+	if delta := s.apply("a", nil); delta != lobbyDeltaRemoved {
+		t.Errorf("removal of a visible lobby: %v", delta)
+	}
+
+	added := s.backfill(all[1:])
+	if len(added) != 1 || added[0].ID != "c" {
+		t.Errorf("backfill = %+v, want lobby c", added)
+	}
+}
+
+// TestStartAndLeaveUpdateNotStartedFilter checks that a lobby leaves a
+// notStarted subscription when its game starts and comes back when a player
+// leaves the game.
+func TestStartAndLeaveUpdateNotStartedFilter(t *testing.T) {
+	oldPlayers, oldLobbies := activePlayers, lobbies
+	activePlayers, lobbies = make(map[string]*Player), make(map[string]*Lobby)
+	t.Cleanup(func() { activePlayers, lobbies = oldPlayers, oldLobbies })
+
+	watcher := newPlayer("watcher", "Watcher", nil)
+	watcher.lobbySub = newLobbySubscription(LobbyFilter{NotStarted: true}, 0)
+	host := newPlayer("host", "Host", nil)
+	guest := newPlayer("guest", "Guest", nil)
+	for _, p := range []*Player{watcher, host, guest} {
+		activePlayers[p.ID] = p
+	}
+	lobby := &Lobby{ID: "lobby", Name: "Lobby", MaxPlayers: 2, Players: []*Player{host, guest}, GameStart: []PlayerStarted{}, Version: 1}
+	lobbies[lobby.ID] = lobby
+	watcher.lobbySub.reset(getSortedLobbiesList())
+
+	lastDelta := func() MessageType {
+		var last MessageType
+		for {
+			select {
+			case msg := <-watcher.Send:
+				var r BaseResponse
+				if err := json.Unmarshal(msg, &r); err != nil {
+					t.Fatal(err)
+				}
+				last = r.Type
+			default:
+				return last
+			}
+		}
+	}
+
+	startGameHandler(StartGame{BaseRequest: BaseRequest{PlayerID: host.ID}, LobbyID: lobby.ID})
+	startGameHandler(StartGame{BaseRequest: BaseRequest{PlayerID: guest.ID}, LobbyID: lobby.ID})
+	if got := lastDelta(); got != ResponseLobbyRemoved {
+		t.Errorf("after the start the watcher got %q, want %q", got, ResponseLobbyRemoved)
+	}
+
+	// A running game can't be cancelled
+	cancelGameHandler(CancelGame{BaseRequest: BaseRequest{PlayerID: guest.ID}, LobbyID: lobby.ID})
+	if lobby.Game == nil || len(lobby.GameStart) != 2 {
+		t.Fatal("cancel_game ended the running game")
+	}
+	if got := lastDelta(); got != "" {
+		t.Errorf("after the rejected cancel the watcher got %q", got)
+	}
+
+	// The game goes on without the guest until the seat is released
+	leaveLobbyHandler(LeaveLobbyRequest{BaseRequest: BaseRequest{PlayerID: guest.ID}, LobbyID: lobby.ID})
+	if got := lastDelta(); got != "" {
+		t.Errorf("after the leave the watcher got %q", got)
+	}
+	releaseOfflineSeat(lobby, lobby.Players[1])
+	if got := lastDelta(); got != ResponseLobbyAdded {
+		t.Errorf("after the release the watcher got %q, want %q", got, ResponseLobbyAdded)
+	}
+	if fmt.Sprint(watcher.lobbySub.visible) != "map[lobby:true]" {
+		t.Errorf("visible lobbies %v", watcher.lobbySub.visible)
+	}
+}
+
+func TestStaleChangeDoesNotBringBackARemovedLobby(t *testing.T) {
+	useTestLobbies(t)
+	watcher := newPlayer("watcher", "Watcher", nil)
+	activePlayers[watcher.ID] = watcher
+	l := &Lobby{ID: "lobby", Name: "Lobby", MaxPlayers: 2, Players: []*Player{newPlayer("host", "Host", nil)}, Version: 1}
+	lobbies[l.ID] = l
+	broadcastLobbyAdded(l)
+
+	// The change finds the lobby, then waits for the lobby lock while the
+	// lobby is removed
+	var wg sync.WaitGroup
+	wg.Add(2)
+	l.Lock.Lock()
+	go func() {
+		defer wg.Done()
+		broadcastLobbyChanged(l)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	go func() {
+		defer wg.Done()
+		lobbiesLock.Lock()
+		delete(lobbies, l.ID)
+		lobbiesLock.Unlock()
+		broadcastLobbyRemoved(l.ID)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	l.Lock.Unlock()
+	wg.Wait()
+
+	if len(watcher.lobbySub.visible) > 0 {
+		t.Errorf("removed lobby still listed: %v", watcher.lobbySub.visible)
+	}
+}
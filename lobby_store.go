@@ -0,0 +1,422 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/Daweenci/Web_Lobby/game"
+)
+
+// Every change of a lobby is queued for the lobby persister, which writes the
+// latest snapshot of each changed lobby in one transaction per batch. The
+// saved lobbies are what restoreLobbies brings back after a restart or crash.
+// Queueing never waits for the disk: only the newest snapshot of a lobby is
+// kept until the persister gets to it.
+
+var (
+	// Snapshots waiting to be written by lobby ID, a nil snapshot deletes the
+	// lobby. nil while no persister runs, changes are not saved then
+	pendingLobbySaves map[string]*lobbySnapshot
+	lobbySavesWake    chan struct{} // tells the persister there is something to write
+	lobbySavesDone    chan struct{}
+	lobbySavesLock    sync.Mutex // guards the three above
+)
+
+// lobbySnapshot is how a lobby and its running game are stored in SQLite.
+// Players are stored without their connection.
+type lobbySnapshot struct {
+	ID          string
+	Name        string
+	MaxPlayers  int
+	IsPrivate   bool
+	Password    string
+	Players     []PlayerDTO
+	GameStart   []PlayerStarted
+	TeamPlay    bool
+	RandomTeams bool
+	Teams       map[string]int
+	Rules       game.Rules
+	Practice    bool
+	Version     uint64
+	Game        *game.Game
+}
+
+// toLobbySnapshot copies the lobby and its game, so the snapshot can be
+// written while the game goes on. The caller must hold lobby.Lock
+func toLobbySnapshot(l *Lobby) lobbySnapshot {
+	gameStart := make([]PlayerStarted, len(l.GameStart))
+	copy(gameStart, l.GameStart)
+	var g *game.Game
+	if l.Game != nil {
+		g = l.Game.Clone()
+	}
+
+	return lobbySnapshot{
+		ID:          l.ID,
+		Name:        l.Name,
+		MaxPlayers:  l.MaxPlayers,
+		IsPrivate:   l.IsPrivate,
+		Password:    l.Password,
+		Players:     toPlayerResponses(l.Players),
+		GameStart:   gameStart,
+		TeamPlay:    l.TeamPlay,
+		RandomTeams: l.RandomTeams,
+		Teams:       maps.Clone(l.Teams),
+		Rules:       l.Rules,
+		Practice:    l.Practice,
+		Version:     l.Version,
+		Game:        g,
+	}
+}
+
+// saveAllLobbies replaces the stored lobbies with the current ones
+func saveAllLobbies() error {
+	lobbiesLock.RLock()
+	snapshots := make([]lobbySnapshot, 0, len(lobbies))
+	for _, l := range lobbies {
+		l.Lock.RLock()
+		snapshots = append(snapshots, toLobbySnapshot(l))
+		l.Lock.RUnlock()
+	}
+	lobbiesLock.RUnlock()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM saved_lobbies`); err != nil {
+		return fmt.Errorf("failed to clear saved lobbies: %w", err)
+	}
+
+	for i := range snapshots {
+		if err := writeLobbySnapshot(tx, &snapshots[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit saved lobbies: %w", err)
+	}
+
+	log.Printf("Saved %d lobbies", len(snapshots))
+	return nil
+}
+
+// restoreLobbies loads the stored lobbies. Their players are offline until
+// they reconnect and reclaim their seats.
+func restoreLobbies() error {
+	rows, err := db.Query(`SELECT id, snapshot FROM saved_lobbies`)
+	if err != nil {
+		return fmt.Errorf("failed to load saved lobbies: %w", err)
+	}
+	defer rows.Close()
+
+	restored := 0
+	lobbiesLock.Lock()
+	defer lobbiesLock.Unlock()
+
+	for rows.Next() {
+		var id string
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return fmt.Errorf("failed to scan saved lobby: %w", err)
+		}
+
+		var s lobbySnapshot
+		if err := json.Unmarshal(data, &s); err != nil {
+			log.Printf("Skipping saved lobby %s: %v", id, err)
+			continue
+		}
+		if len(s.Players) == 0 {
+			continue
+		}
+
+		l := fromLobbySnapshot(s)
+		resetTurnTimer(l)
+		lobbies[s.ID] = l
+		restored++
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load saved lobbies: %w", err)
+	}
+
+	log.Printf("Restored %d lobbies", restored)
+	return nil
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// writeLobbySnapshot upserts s unless a newer version is already stored
+func writeLobbySnapshot(tx execer, s *lobbySnapshot) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lobby %s: %w", s.ID, err)
+	}
+
+	_, err = tx.Exec(`
+	INSERT INTO saved_lobbies (id, version, snapshot, saved_at)
+	VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+	ON CONFLICT(id) DO UPDATE SET
+		version = excluded.version,
+		snapshot = excluded.snapshot,
+		saved_at = excluded.saved_at
+	WHERE excluded.version >= saved_lobbies.version
+	`, s.ID, s.Version, data)
+	if err != nil {
+		return fmt.Errorf("failed to save lobby %s: %w", s.ID, err)
+	}
+	return nil
+}
+
+// persistLobby queues a snapshot of the lobby for the persister
+func persistLobby(l *Lobby) {
+	l.Lock.RLock()
+	s := toLobbySnapshot(l)
+	l.Lock.RUnlock()
+
+	queueLobbySave(s.ID, &s)
+}
+
+// persistLobbyRemoved queues the deletion of a saved lobby
+func persistLobbyRemoved(lobbyID string) {
+	queueLobbySave(lobbyID, nil)
+}
+
+// queueLobbySave replaces the pending save of the lobby unless that one is
+// newer. A deletion wins over any snapshot.
+func queueLobbySave(lobbyID string, s *lobbySnapshot) {
+	lobbySavesLock.Lock()
+	defer lobbySavesLock.Unlock()
+	if pendingLobbySaves == nil {
+		return
+	}
+
+	if prev, seen := pendingLobbySaves[lobbyID]; seen && (prev == nil || s != nil && s.Version < prev.Version) {
+		return
+	}
+	pendingLobbySaves[lobbyID] = s
+
+	select {
+	case lobbySavesWake <- struct{}{}:
+	default: // the persister was already told
+	}
+}
+
+// startLobbyPersister saves every lobby change from now on until
+// stopLobbyPersister is called.
+func startLobbyPersister() {
+	lobbySavesLock.Lock()
+	defer lobbySavesLock.Unlock()
+
+	pendingLobbySaves = make(map[string]*lobbySnapshot)
+	lobbySavesWake = make(chan struct{}, 1)
+	lobbySavesDone = make(chan struct{})
+	go runLobbyPersister(lobbySavesWake, lobbySavesDone)
+}
+
+// stopLobbyPersister writes the queued changes and stops the persister.
+// Later changes are not saved.
+func stopLobbyPersister() {
+	lobbySavesLock.Lock()
+	wake, done := lobbySavesWake, lobbySavesDone
+	lobbySavesWake = nil
+	lobbySavesLock.Unlock()
+
+	if wake == nil {
+		return
+	}
+	close(wake)
+	<-done
+}
+
+func runLobbyPersister(wake <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	for range wake {
+		writePendingLobbies(false)
+	}
+	writePendingLobbies(true)
+}
+
+// writePendingLobbies writes what was queued since the last batch. With
+// last set no more saves are queued afterwards.
+func writePendingLobbies(last bool) {
+	lobbySavesLock.Lock()
+	batch := pendingLobbySaves
+	if last {
+		pendingLobbySaves = nil
+	} else {
+		pendingLobbySaves = make(map[string]*lobbySnapshot)
+	}
+	lobbySavesLock.Unlock()
+
+	// Lobby IDs are never reused, so a late snapshot of a lobby that was
+	// removed meanwhile must not bring it back. Its deletion is either in
+	// this batch or queued after the snapshot.
+	lobbiesLock.RLock()
+	for id, s := range batch {
+		if _, exists := lobbies[id]; s != nil && !exists {
+			delete(batch, id)
+		}
+	}
+	lobbiesLock.RUnlock()
+
+	if err := writeLobbyBatch(batch); err != nil {
+		log.Printf("Failed to persist lobbies: %v", err)
+	}
+}
+
+func writeLobbyBatch(batch map[string]*lobbySnapshot) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for id, s := range batch {
+		if s == nil {
+			if _, err := tx.Exec(`DELETE FROM saved_lobbies WHERE id = ?`, id); err != nil {
+				return fmt.Errorf("failed to delete saved lobby %s: %w", id, err)
+			}
+			continue
+		}
+		if err := writeLobbySnapshot(tx, s); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func fromLobbySnapshot(s lobbySnapshot) *Lobby {
+	players := make([]*Player, len(s.Players))
+	for i, p := range s.Players {
+		players[i] = newOfflinePlayer(p.ID, p.Name)
+	}
+	teams := s.Teams
+	if teams == nil {
+		teams = make(map[string]int)
+	}
+
+	return &Lobby{
+		ID:          s.ID,
+		Name:        s.Name,
+		MaxPlayers:  s.MaxPlayers,
+		IsPrivate:   s.IsPrivate,
+		Password:    s.Password,
+		Players:     players,
+		GameStart:   s.GameStart,
+		TeamPlay:    s.TeamPlay,
+		RandomTeams: s.RandomTeams,
+		Teams:       teams,
+		Rules:       s.Rules,
+		Practice:    s.Practice,
+		Version:     s.Version,
+		Game:        s.Game,
+	}
+}
+
+// newOfflinePlayer holds the seat of a player that is not connected.
+// Messages to it are dropped.
+func newOfflinePlayer(id, name string) *Player {
+	p := newPlayer(id, name, nil)
+	p.close()
+	return p
+}
+
+// (p *Player) offline reports whether p only holds a seat for a player that
+// is not connected
+func (p *Player) offline() bool {
+	if p.Conn != nil {
+		return false
+	}
+	select {
+	case <-p.Done:
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseOfflineSeats frees the seats of restored players that did not come
+// back within the reclaim timeout.
+func releaseOfflineSeats() {
+	var offline []string
+	lobbiesLock.RLock()
+	for _, l := range lobbies {
+		l.Lock.RLock()
+		for _, p := range l.Players {
+			if p.offline() {
+				offline = append(offline, p.ID)
+			}
+		}
+		l.Lock.RUnlock()
+	}
+	lobbiesLock.RUnlock()
+
+	for _, playerID := range offline {
+		activePlayersLock.RLock()
+		_, online := activePlayers[playerID]
+		activePlayersLock.RUnlock()
+		if online {
+			continue
+		}
+
+		log.Printf("Player %s did not reclaim its seat within %s", playerID, config.SeatReclaimTimeout)
+		removePlayerFromLobbies(playerID)
+	}
+}
+
+// releaseOfflineSeat frees the seat a disconnected player held in a running
+// game, unless the player reconnected and took it back in the meantime.
+func releaseOfflineSeat(lobby *Lobby, seat *Player) {
+	lobby.Lock.RLock()
+	held := slices.Contains(lobby.Players, seat)
+	lobby.Lock.RUnlock()
+	if !held {
+		return
+	}
+
+	log.Printf("Player %s did not reclaim its seat within %s", seat.ID, config.SeatReclaimTimeout)
+	removePlayerFromLobbies(seat.ID)
+}
+
+// reclaimSeats gives a reconnected player back the seats it held while it
+// was offline.
+func reclaimSeats(player *Player) {
+	lobbiesLock.RLock()
+	var reclaimed []*Lobby
+	for _, l := range lobbies {
+		l.Lock.Lock()
+		for i, p := range l.Players {
+			if p.ID == player.ID && p != player {
+				l.Players[i] = player
+				l.Version++
+				reclaimed = append(reclaimed, l)
+			}
+		}
+		l.Lock.Unlock()
+	}
+	lobbiesLock.RUnlock()
+
+	for _, l := range reclaimed {
+		log.Printf("Player %s reclaimed its seat in lobby %s", player.ID, l.ID)
+		broadcastLobbyUpdate(l)
+		broadcastLobbyChanged(l)
+		broadcastGameState(l)
+	}
+}
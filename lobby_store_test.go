@@ -0,0 +1,293 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Daweenci/Web_Lobby/game"
+)
+
+// useTestLobbies swaps in empty lobby and player maps for the test
+func useTestLobbies(t *testing.T) {
+	t.Helper()
+
+	lobbiesLock.Lock()
+	oldLobbies := lobbies
+	lobbies = make(map[string]*Lobby)
+	lobbiesLock.Unlock()
+
+	activePlayersLock.Lock()
+	oldPlayers := activePlayers
+	activePlayers = make(map[string]*Player)
+	activePlayersLock.Unlock()
+
+	t.Cleanup(func() {
+		lobbiesLock.Lock()
+		lobbies = oldLobbies
+		lobbiesLock.Unlock()
+
+		activePlayersLock.Lock()
+		activePlayers = oldPlayers
+		activePlayersLock.Unlock()
+	})
+}
+
+func TestSavedLobbiesAreRestoredWithTheirGame(t *testing.T) {
+	useTestDB(t)
+	useTestLobbies(t)
+
+	l := &Lobby{
+		ID:         "lobby-1",
+		Name:       "Saved",
+		MaxPlayers: 2,
+		Password:   "pw",
+		Players:    []*Player{newPlayer("p1", "Alice", nil), newPlayer("p2", "Bob", nil)},
+		GameStart:  []PlayerStarted{{ID: "p1"}, {ID: "p2"}},
+		Rules:      game.Rules{AllowPass: true, HandSizes: []int{4}},
+		Version:    7,
+	}
+	syncLobbyGame(l)
+	if l.Game == nil {
+		t.Fatal("started lobby has no game")
+	}
+	l.Game.Players[1].Figures[0].Position = 12
+	lobbies[l.ID] = l
+
+	if err := saveAllLobbies(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	lobbies = make(map[string]*Lobby)
+	if err := restoreLobbies(); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+
+	got, ok := lobbies["lobby-1"]
+	if !ok {
+		t.Fatal("lobby was not restored")
+	}
+	if got.Name != "Saved" || got.Password != "pw" || got.Version != 7 || len(got.GameStart) != 2 {
+		t.Errorf("restored lobby %+v", got)
+	}
+	if got.Game == nil || got.Game.Players[1].Figures[0].Position != 12 {
+		t.Errorf("restored game %+v", got.Game)
+	}
+	if !got.Rules.AllowPass || !got.Game.Rules.AllowPass || len(got.Game.Players[0].Hand) != 4 {
+		t.Errorf("restored rules %+v, game rules %+v", got.Rules, got.Game.Rules)
+	}
+
+	// The players hold their seats while offline
+	if len(got.Players) != 2 || got.Players[0].ID != "p1" {
+		t.Fatalf("restored players %+v", got.Players)
+	}
+	select {
+	case <-got.Players[0].Done:
+	default:
+		t.Error("restored player is not offline")
+	}
+}
+
+func TestReconnectingPlayerReclaimsSeat(t *testing.T) {
+	useTestLobbies(t)
+
+	l := &Lobby{
+		ID:         "lobby-1",
+		MaxPlayers: 2,
+		Players:    []*Player{newOfflinePlayer("p1", "Alice"), newOfflinePlayer("p2", "Bob")},
+		Version:    1,
+	}
+	lobbies[l.ID] = l
+
+	p := newPlayer("p1", "Alice", nil)
+	activePlayers[p.ID] = p
+	reclaimSeats(p)
+
+	if l.Players[0] != p || l.Players[1].ID != "p2" {
+		t.Errorf("players after reclaim %+v", l.Players)
+	}
+	if l.Version != 2 {
+		t.Errorf("Version = %d, want 2", l.Version)
+	}
+}
+
+func savedLobbyVersions(t *testing.T) map[string]uint64 {
+	t.Helper()
+
+	rows, err := db.Query(`SELECT id, version FROM saved_lobbies`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	versions := make(map[string]uint64)
+	for rows.Next() {
+		var id string
+		var version uint64
+		if err := rows.Scan(&id, &version); err != nil {
+			t.Fatal(err)
+		}
+		versions[id] = version
+	}
+	return versions
+}
+
+func TestLobbyPersisterSavesEveryChange(t *testing.T) {
+	useTestDB(t)
+	useTestLobbies(t)
+	startLobbyPersister()
+	t.Cleanup(stopLobbyPersister)
+
+	kept := &Lobby{ID: "kept", MaxPlayers: 2, Players: []*Player{newPlayer("p1", "Alice", nil)}, Version: 1}
+	gone := &Lobby{ID: "gone", MaxPlayers: 2, Players: []*Player{newPlayer("p2", "Bob", nil)}, Version: 1}
+	lobbies[kept.ID] = kept
+	lobbies[gone.ID] = gone
+	persistLobby(kept)
+	persistLobby(gone)
+
+	kept.Version = 3
+	persistLobby(kept)
+	delete(lobbies, gone.ID)
+	persistLobbyRemoved(gone.ID)
+
+	// A snapshot taken before the removal arrives late
+	persistLobby(gone)
+
+	stopLobbyPersister()
+
+	versions := savedLobbyVersions(t)
+	if len(versions) != 1 || versions["kept"] != 3 {
+		t.Errorf("saved lobbies %v, want only kept at version 3", versions)
+	}
+
+	// Even after the deletion was written
+	startLobbyPersister()
+	persistLobby(gone)
+	stopLobbyPersister()
+	if _, saved := savedLobbyVersions(t)["gone"]; saved {
+		t.Error("a late snapshot brought back a removed lobby")
+	}
+}
+
+// TestSavingDoesNotRaceWithPlays plays a game while the persister writes its
+// snapshots. Run with -race.
+func TestSavingDoesNotRaceWithPlays(t *testing.T) {
+	useTestDB(t)
+	useTestLobbies(t)
+	startLobbyPersister()
+	t.Cleanup(stopLobbyPersister)
+
+	l := &Lobby{
+		ID:         "lobby-1",
+		MaxPlayers: 2,
+		Players:    []*Player{newPlayer("p1", "Alice", nil), newPlayer("p2", "Bob", nil)},
+		GameStart:  []PlayerStarted{{ID: "p1"}, {ID: "p2"}},
+		Version:    1,
+	}
+	syncLobbyGame(l)
+	lobbies[l.ID] = l
+
+	for range 200 {
+		l.Lock.Lock()
+		g := l.Game
+		if len(g.Winners) > 0 {
+			l.Lock.Unlock()
+			break
+		}
+		p := g.CurrentPlayer()
+		moves, err := g.LegalMoves(p.ID)
+		if err != nil || len(moves) == 0 {
+			l.Lock.Unlock()
+			t.Fatalf("no legal move for %s: %v", p.ID, err)
+		}
+		if err := g.PlayCard(p.ID, moves[0].Play); err != nil {
+			l.Lock.Unlock()
+			t.Fatal(err)
+		}
+		g.Advance()
+		l.Version++
+		l.Lock.Unlock()
+
+		persistLobby(l)
+	}
+	stopLobbyPersister()
+
+	if v := savedLobbyVersions(t)[l.ID]; v != l.Version {
+		t.Errorf("saved version %d, want %d", v, l.Version)
+	}
+}
+
+func TestStaleSnapshotDoesNotOverwriteNewerOne(t *testing.T) {
+	useTestDB(t)
+
+	newer := lobbySnapshot{ID: "l", Version: 5}
+	older := lobbySnapshot{ID: "l", Version: 4}
+	if err := writeLobbySnapshot(db, &newer); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeLobbySnapshot(db, &older); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := savedLobbyVersions(t)["l"]; v != 5 {
+		t.Errorf("saved version %d, want 5", v)
+	}
+}
+
+func TestOfflineSeatsAreReleased(t *testing.T) {
+	useTestLobbies(t)
+
+	online := newPlayer("p1", "Alice", nil)
+	activePlayers[online.ID] = online
+	l := &Lobby{
+		ID:         "lobby-1",
+		MaxPlayers: 2,
+		Players:    []*Player{online, newOfflinePlayer("p2", "Bob")},
+		Version:    1,
+	}
+	lobbies[l.ID] = l
+
+	releaseOfflineSeats()
+
+	if len(l.Players) != 1 || l.Players[0] != online {
+		t.Errorf("players after release %+v", l.Players)
+	}
+}
+
+func TestDisconnectKeepsTheSeatInARunningGame(t *testing.T) {
+	useTestLobbies(t)
+
+	alice, bob := newPlayer("p1", "Alice", nil), newPlayer("p2", "Bob", nil)
+	activePlayers[alice.ID] = alice
+	activePlayers[bob.ID] = bob
+	l := &Lobby{
+		ID:         "lobby-1",
+		MaxPlayers: 2,
+		Players:    []*Player{alice, bob},
+		GameStart:  []PlayerStarted{{ID: "p1"}, {ID: "p2"}},
+		Version:    1,
+	}
+	syncLobbyGame(l)
+	lobbies[l.ID] = l
+	g := l.Game
+
+	disconnectPlayer(bob.ID)
+	seat := l.Players[1]
+	if l.Game != g || len(l.Players) != 2 || seat.ID != "p2" || !seat.offline() {
+		t.Fatalf("after the disconnect: game %p, players %+v", l.Game, l.Players)
+	}
+
+	// Coming back in time reclaims the seat, the late release does nothing
+	back := newPlayer("p2", "Bob", nil)
+	activePlayers[back.ID] = back
+	reclaimSeats(back)
+	releaseOfflineSeat(l, seat)
+	if l.Players[1] != back || l.Game != g {
+		t.Fatalf("after the reclaim: players %+v", l.Players)
+	}
+
+	// Not coming back frees the seat and ends the game
+	disconnectPlayer(back.ID)
+	releaseOfflineSeat(l, l.Players[1])
+	if len(l.Players) != 1 || l.Game != nil {
+		t.Errorf("after the release: players %+v, game %v", l.Players, l.Game)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"log"
+	"math/rand/v2"
+
+	"github.com/Daweenci/Web_Lobby/game"
+)
+
+// Team play is 2v2 with the partners sitting opposite each other. The host,
+// the first player in the lobby, puts every player into a team, or the
+// teams are drawn when the game starts.
+
+const (
+	teamCount   = 2
+	teamPlayers = 4
+)
+
+// lobbyTeams returns the teams of the players in the lobby. The caller must
+// hold lobby.Lock
+func lobbyTeams(l *Lobby) map[string]int {
+	teams := make(map[string]int)
+	for _, p := range l.Players {
+		if team, ok := l.Teams[p.ID]; ok {
+			teams[p.ID] = team
+		}
+	}
+	return teams
+}
+
+// teamsReady reports whether the game can be seated. The caller must hold
+// lobby.Lock
+func teamsReady(l *Lobby) bool {
+	if !l.TeamPlay || l.RandomTeams {
+		return true
+	}
+
+	var size [teamCount]int
+	for _, team := range lobbyTeams(l) {
+		size[team]++
+	}
+	for _, n := range size {
+		if n != teamPlayers/teamCount {
+			return false
+		}
+	}
+	return true
+}
+
+// gameSeating returns the players in seat order. In team play the teams
+// alternate, so partners sit opposite each other; random teams are drawn
+// here and recorded on the lobby. The caller must hold lobby.Lock
+func gameSeating(l *Lobby) []game.PlayerInGame {
+	players := make([]*Player, len(l.Players))
+	copy(players, l.Players)
+
+	if l.TeamPlay {
+		if l.Teams == nil {
+			l.Teams = make(map[string]int)
+		}
+		if l.RandomTeams {
+			rand.Shuffle(len(players), func(i, j int) { players[i], players[j] = players[j], players[i] })
+			for i, p := range players {
+				l.Teams[p.ID] = i % teamCount
+			}
+		} else {
+			var teams [teamCount][]*Player
+			for _, p := range players {
+				team := l.Teams[p.ID]
+				teams[team] = append(teams[team], p)
+			}
+			players = players[:0]
+			for i := range teamPlayers / teamCount {
+				for _, team := range teams {
+					players = append(players, team[i])
+				}
+			}
+		}
+	}
+
+	seats := make([]game.PlayerInGame, len(players))
+	for i, p := range players {
+		seats[i] = game.PlayerInGame{ID: p.ID, Name: p.Name}
+		if l.TeamPlay {
+			seats[i].Partner = players[(i+teamCount)%len(players)].ID
+		}
+	}
+	return seats
+}
+
+func setTeamHandler(msg SetTeamRequest) {
+	activePlayersLock.RLock()
+	player, ok := activePlayers[msg.PlayerID]
+	activePlayersLock.RUnlock()
+	if !ok {
+		log.Println("setTeamHandler: Player not found")
+		disconnectPlayer(msg.PlayerID)
+		return
+	}
+
+	lobbiesLock.RLock()
+	lobby, ok := lobbies[msg.LobbyID]
+	lobbiesLock.RUnlock()
+	if !ok {
+		sendErrorToPlayer(player, msg.RequestID, ErrorLobbyNotFound, "Lobby not found")
+		return
+	}
+
+	gameStarted, code, errMsg := assignTeam(lobby, player.ID, msg.TargetPlayerID, msg.Team)
+
+	if code != "" {
+		sendErrorToPlayer(player, msg.RequestID, code, errMsg)
+		return
+	}
+
+	broadcastLobbyUpdate(lobby)
+	broadcastLobbyChanged(lobby)
+	if gameStarted {
+		broadcastRoundStarted(lobby)
+	}
+}
+
+// assignTeam locks the lobby for setTeam and reports whether the game started
+func assignTeam(l *Lobby, hostID, playerID string, team int) (started bool, code ErrorCode, errMsg string) {
+	l.Lock.Lock()
+	defer l.Lock.Unlock()
+
+	code, errMsg = setTeam(l, hostID, playerID, team)
+	return code == "" && l.Game != nil, code, errMsg
+}
+
+// setTeam assigns the team and starts the game if that completed the teams.
+// The caller must hold lobby.Lock
+func setTeam(l *Lobby, hostID, playerID string, team int) (ErrorCode, string) {
+	switch {
+	case !l.TeamPlay || l.RandomTeams:
+		return ErrorInvalidRequest, "Teams are not assigned by the host in this lobby"
+	case len(l.Players) == 0 || l.Players[0].ID != hostID:
+		return ErrorNotLobbyHost, "Only the host can assign teams"
+	case l.Game != nil:
+		return ErrorGameAlreadyStarted, "The game has already started"
+	}
+
+	inLobby := false
+	for _, p := range l.Players {
+		inLobby = inLobby || p.ID == playerID
+	}
+	if !inLobby {
+		return ErrorPlayerNotFound, "Player is not in the lobby"
+	}
+
+	members := 0
+	for id, t := range lobbyTeams(l) {
+		if t == team && id != playerID {
+			members++
+		}
+	}
+	if members >= teamPlayers/teamCount {
+		return ErrorTeamFull, "Team is full"
+	}
+
+	if l.Teams == nil {
+		l.Teams = make(map[string]int)
+	}
+	l.Teams[playerID] = team
+	l.Version++
+	syncLobbyGame(l)
+	return "", ""
+}
@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+func newTeamLobby(randomTeams bool) *Lobby {
+	return &Lobby{
+		ID:          "lobby-1",
+		MaxPlayers:  teamPlayers,
+		TeamPlay:    true,
+		RandomTeams: randomTeams,
+		Teams:       make(map[string]int),
+		Players: []*Player{
+			newPlayer("host", "Host", nil), newPlayer("p2", "B", nil),
+			newPlayer("p3", "C", nil), newPlayer("p4", "D", nil),
+		},
+		GameStart: []PlayerStarted{{ID: "host"}, {ID: "p2"}, {ID: "p3"}, {ID: "p4"}},
+	}
+}
+
+func TestHostAssignsTeamsBeforeTheGameStarts(t *testing.T) {
+	l := newTeamLobby(false)
+
+	if code, _ := setTeam(l, "p2", "p2", 0); code != ErrorNotLobbyHost {
+		t.Errorf("non-host set a team: %q", code)
+	}
+
+	for id, team := range map[string]int{"host": 0, "p2": 0, "p3": 1} {
+		if code, msg := setTeam(l, "host", id, team); code != "" {
+			t.Fatalf("setTeam(%s): %s", id, msg)
+		}
+	}
+	if l.Game != nil {
+		t.Fatal("game started with an incomplete team")
+	}
+	if code, _ := setTeam(l, "host", "p4", 0); code != ErrorTeamFull {
+		t.Errorf("third player joined team 0: %q", code)
+	}
+
+	if code, msg := setTeam(l, "host", "p4", 1); code != "" {
+		t.Fatalf("setTeam(p4): %s", msg)
+	}
+	if l.Game == nil {
+		t.Fatal("game did not start once the teams were complete")
+	}
+
+	// Teams alternate around the board so partners sit opposite
+	seats := l.Game.Players
+	if seats[0].ID != "host" || seats[2].ID != "p2" || seats[0].Partner != "p2" || seats[1].Partner != seats[3].ID {
+		t.Errorf("seating %v, %v, %v, %v", seats[0], seats[1], seats[2], seats[3])
+	}
+
+	if code, _ := setTeam(l, "host", "p4", 0); code != ErrorGameAlreadyStarted {
+		t.Errorf("teams changed during the game: %q", code)
+	}
+}
+
+func TestRandomTeamsAreDrawnAtStart(t *testing.T) {
+	l := newTeamLobby(true)
+	syncLobbyGame(l)
+	if l.Game == nil {
+		t.Fatal("game did not start")
+	}
+
+	for seat, p := range l.Game.Players {
+		partner := l.Game.Players[(seat+2)%4]
+		if p.Partner != partner.ID || l.Teams[p.ID] != l.Teams[partner.ID] {
+			t.Errorf("%s sits opposite %s but is partnered with %s", p.ID, partner.ID, p.Partner)
+		}
+	}
+	if code, _ := setTeam(l, "host", "p2", 0); code != ErrorInvalidRequest {
+		t.Errorf("host set a team with random teams: %q", code)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// Failed logins in a row before the backoff starts
+	loginBackoffFreeAttempts = 3
+	loginBackoffBase         = time.Second
+	loginBackoffMax          = 5 * time.Minute
+
+	// Failed logins shown to the player after a successful login
+	maxFailedLoginsShown = 10
+)
+
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := hashPassword("not a real password")
+	if err != nil {
+		log.Printf("Failed to hash dummy password: %v", err)
+	}
+	return hash
+})
+
+// LoginBlockedError is returned while an account or IP has to wait before
+// it may try to log in again.
+type LoginBlockedError struct {
+	RetryAfter time.Duration
+	Locked     bool // the account is locked, not just backing off
+}
+
+func (e *LoginBlockedError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("account locked, retry in %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("too many failed logins, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// loginBackoff is how long an account has to wait after failures failed
+// logins in a row.
+func loginBackoff(failures int) time.Duration {
+	if failures < loginBackoffFreeAttempts {
+		return 0
+	}
+
+	shift := failures - loginBackoffFreeAttempts
+	if shift >= 20 {
+		return loginBackoffMax
+	}
+	return min(loginBackoffBase<<shift, loginBackoffMax)
+}
+
+// verifyPlayerCredentials checks a login from ip. On success it also returns
+// the failed logins on the account since the previous successful login.
+func verifyPlayerCredentials(username, password, ip string) (string, []FailedLoginDTO, error) {
+	now := time.Now().UTC()
+
+	attempt, err := beginLogin(username, ip, now)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if attempt.playerID == "" {
+		// Hash anyway, so unknown usernames don't answer noticeably faster
+		verifyPassword(dummyPasswordHash(), password)
+		return "", nil, ErrInvalidCredentials
+	}
+	if !verifyPassword(attempt.passwordHash, password) {
+		if attempt.failures >= config.LoginLockoutAttempts {
+			log.Printf("Locking account %s after %d failed logins", attempt.playerID, attempt.failures)
+		}
+		return "", nil, ErrInvalidCredentials
+	}
+
+	finishLogin(attempt, now)
+	return attempt.playerID, getFailedLoginsSince(attempt.playerID, attempt.lastLogin), nil
+}
+
+// loginAttempt is a login that passed the backoff checks. It counts as
+// failed until finishLogin.
+type loginAttempt struct {
+	id              int64
+	playerID        string // empty for an unknown username
+	passwordHash    string
+	lastLogin       *time.Time
+	lastFailedLogin *time.Time // before this attempt
+	failures        int        // failed logins in a row, this one included
+}
+
+type rowQuerier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// beginLogin checks the backoff of ip and of the account and records the
+// attempt as a failure in one transaction, so parallel guesses can not all
+// pass the check before the first failure is written. Attempts older than
+// loginAttemptRetention are dropped on the way.
+func beginLogin(username, ip string, now time.Time) (*loginAttempt, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer tx.Rollback()
+
+	if retryAfter, blocked, err := ipLoginBlocked(tx, ip, now); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	} else if blocked {
+		return nil, &LoginBlockedError{RetryAfter: retryAfter}
+	}
+
+	a := &loginAttempt{}
+	var lockedUntil *time.Time
+	err = tx.QueryRow(`
+	SELECT id, password_hash, last_login, last_failed_login, failed_login_count, locked_until
+	FROM players
+	WHERE username = ?
+	`, username).Scan(&a.playerID, &a.passwordHash, &a.lastLogin, &a.lastFailedLogin, &a.failures, &lockedUntil)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	if a.playerID != "" {
+		if lockedUntil != nil && lockedUntil.After(now) {
+			return nil, &LoginBlockedError{RetryAfter: lockedUntil.Sub(now), Locked: true}
+		}
+		if a.lastFailedLogin != nil {
+			if next := a.lastFailedLogin.Add(loginBackoff(a.failures)); next.After(now) {
+				return nil, &LoginBlockedError{RetryAfter: next.Sub(now)}
+			}
+		}
+
+		err = tx.QueryRow(`
+		UPDATE players
+		SET last_failed_login = ?,
+			failed_login_count = failed_login_count + 1,
+			locked_until = CASE WHEN failed_login_count + 1 >= ? THEN ? ELSE locked_until END
+		WHERE id = ?
+		RETURNING failed_login_count
+		`, now, config.LoginLockoutAttempts, now.Add(config.LoginLockoutDuration), a.playerID).Scan(&a.failures)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record failed login: %w", err)
+		}
+	}
+
+	if a.id, err = recordLoginAttempt(tx, username, a.playerID, ip, now); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM login_attempts WHERE created_at < ?`, now.Add(-loginAttemptRetention())); err != nil {
+		return nil, fmt.Errorf("failed to drop old login attempts: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return a, nil
+}
+
+// finishLogin marks the attempt as succeeded and clears the failures of the
+// account
+func finishLogin(a *loginAttempt, now time.Time) {
+	_, err := db.Exec(`
+	UPDATE players
+	SET last_login = ?, last_failed_login = ?, failed_login_count = 0, locked_until = NULL
+	WHERE id = ?
+	`, now, a.lastFailedLogin, a.playerID)
+	if err != nil {
+		log.Printf("Failed to update last login for player %s: %v", a.playerID, err)
+	}
+
+	if _, err := db.Exec(`UPDATE login_attempts SET succeeded = 1 WHERE id = ?`, a.id); err != nil {
+		log.Printf("Failed to record login of player %s: %v", a.playerID, err)
+	}
+}
+
+// loginAttemptRetention is how long login attempts are kept: as long as the
+// IP limit and the lockout look back. So the failed logins shown after a
+// login are the recent ones.
+func loginAttemptRetention() time.Duration {
+	return max(config.LoginIPFailureWindow, config.LoginLockoutDuration)
+}
+
+// ipLoginBlocked reports whether ip reached the failure limit within the
+// window, no matter which accounts it tried, and when its oldest counted failure drops out of it.
+func ipLoginBlocked(tx rowQuerier, ip string, now time.Time) (time.Duration, bool, error) {
+	query := `
+	SELECT created_at FROM login_attempts
+	WHERE ip = ? AND succeeded = 0 AND created_at > ?
+	ORDER BY created_at DESC
+	LIMIT 1 OFFSET ?
+	`
+
+	var oldest time.Time
+	err := tx.QueryRow(query, ip, now.Add(-config.LoginIPFailureWindow), config.LoginIPFailureLimit-1).Scan(&oldest)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return oldest.Add(config.LoginIPFailureWindow).Sub(now), true, nil
+}
+
+// recordLoginAttempt stores a failed login attempt and returns its ID
+func recordLoginAttempt(tx execer, username, playerID, ip string, now time.Time) (int64, error) {
+	var player any
+	if playerID != "" {
+		player = playerID
+	}
+
+	res, err := tx.Exec(`
+	INSERT INTO login_attempts (username, player_id, ip, succeeded, created_at)
+	VALUES (?, ?, ?, 0, ?)
+	`, username, player, ip, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record login attempt for %s: %w", username, err)
+	}
+	return res.LastInsertId()
+}
+
+// getFailedLoginsSince returns the latest failed logins on the account after
+// since, or all of them if the player never logged in.
+func getFailedLoginsSince(playerID string, since *time.Time) []FailedLoginDTO {
+	after := time.Time{}
+	if since != nil {
+		after = *since
+	}
+
+	rows, err := db.Query(`
+	SELECT ip, created_at FROM login_attempts
+	WHERE player_id = ? AND succeeded = 0 AND created_at > ?
+	ORDER BY created_at DESC
+	LIMIT ?
+	`, playerID, after, maxFailedLoginsShown)
+	if err != nil {
+		log.Printf("Error fetching failed logins: %v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	var failed []FailedLoginDTO
+	for rows.Next() {
+		var f FailedLoginDTO
+		if err := rows.Scan(&f.IP, &f.Time); err != nil {
+			log.Printf("Error scanning failed login: %v", err)
+			continue
+		}
+		failed = append(failed, f)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("Row iteration error: %v", err)
+	}
+
+	return failed
+}
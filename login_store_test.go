@@ -0,0 +1,220 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+// useTestDB points db at a fresh in-memory database with the schema loaded
+func useTestDB(t *testing.T) {
+	t.Helper()
+
+	testDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	testDB.SetMaxOpenConns(1)
+
+	oldDB := db
+	db = testDB
+	t.Cleanup(func() {
+		testDB.Close()
+		db = oldDB
+	})
+
+	if err := loadSchema(); err != nil {
+		t.Fatalf("load schema: %v", err)
+	}
+}
+
+func TestLoginBacksOffAfterFailedAttempts(t *testing.T) {
+	useTestDB(t)
+	if _, err := createPlayer("alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < loginBackoffFreeAttempts; i++ {
+		if _, _, err := verifyPlayerCredentials("alice", "wrong", "10.0.0.1"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("attempt %d: err = %v, want ErrInvalidCredentials", i, err)
+		}
+	}
+
+	var failures int
+	if err := db.QueryRow(`SELECT failed_login_count FROM players WHERE username = 'alice'`).Scan(&failures); err != nil {
+		t.Fatal(err)
+	}
+	if failures != loginBackoffFreeAttempts {
+		t.Fatalf("failed_login_count = %d, want %d", failures, loginBackoffFreeAttempts)
+	}
+
+	// Hashing is slow enough to outlast a short backoff, so restart it
+	if _, err := db.Exec(`UPDATE players SET last_failed_login = ? WHERE username = 'alice'`, time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Even the right password has to wait for the backoff
+	_, _, err := verifyPlayerCredentials("alice", "secret", "10.0.0.1")
+	var blocked *LoginBlockedError
+	if !errors.As(err, &blocked) || blocked.Locked || blocked.RetryAfter <= 0 {
+		t.Fatalf("err = %v, want a backoff", err)
+	}
+}
+
+func TestLoginLocksAccountAndReportsFailures(t *testing.T) {
+	useTestDB(t)
+	playerID, err := createPlayer("bob", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Skip the backoff between the attempts
+	past := time.Now().UTC().Add(-time.Hour)
+	_, err = db.Exec(`UPDATE players SET failed_login_count = ?, last_failed_login = ? WHERE id = ?`,
+		config.LoginLockoutAttempts-1, past, playerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := verifyPlayerCredentials("bob", "wrong", "10.0.0.2"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("err = %v, want ErrInvalidCredentials", err)
+	}
+
+	_, _, err = verifyPlayerCredentials("bob", "secret", "10.0.0.3")
+	var blocked *LoginBlockedError
+	if !errors.As(err, &blocked) || !blocked.Locked {
+		t.Fatalf("err = %v, want a locked account", err)
+	}
+
+	// Once the lock ran out the player logs in and sees the failed attempt
+	_, err = db.Exec(`UPDATE players SET locked_until = ?, last_failed_login = ? WHERE id = ?`, past, past, playerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotID, failed, err := verifyPlayerCredentials("bob", "secret", "10.0.0.3")
+	if err != nil || gotID != playerID {
+		t.Fatalf("login: id = %q, err = %v", gotID, err)
+	}
+	if len(failed) != 1 || failed[0].IP != "10.0.0.2" {
+		t.Errorf("failed logins = %+v, want the one from 10.0.0.2", failed)
+	}
+
+	// They are only shown once
+	if _, failed, err := verifyPlayerCredentials("bob", "secret", "10.0.0.3"); err != nil || len(failed) != 0 {
+		t.Errorf("second login: failed = %+v, err = %v", failed, err)
+	}
+}
+
+func TestParallelFailedLoginsAreAllCounted(t *testing.T) {
+	useTestDB(t)
+	if _, err := createPlayer("carol", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	// All guesses read the count before any of them is recorded
+	results := make(chan error, loginBackoffFreeAttempts)
+	for i := 0; i < loginBackoffFreeAttempts; i++ {
+		go func() {
+			_, _, err := verifyPlayerCredentials("carol", "wrong", "10.0.0.4")
+			results <- err
+		}()
+	}
+	invalid := 0
+	for i := 0; i < loginBackoffFreeAttempts; i++ {
+		if err := <-results; errors.Is(err, ErrInvalidCredentials) {
+			invalid++
+		}
+	}
+
+	var failures int
+	if err := db.QueryRow(`SELECT failed_login_count FROM players WHERE username = 'carol'`).Scan(&failures); err != nil {
+		t.Fatal(err)
+	}
+	if invalid == 0 || failures != invalid {
+		t.Errorf("failed_login_count = %d after %d wrong passwords", failures, invalid)
+	}
+}
+
+func TestParallelGuessesWaitForTheBackoff(t *testing.T) {
+	useTestDB(t)
+	if _, err := createPlayer("dave", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	// More guesses than are free, sent before any of them is checked
+	const guesses = loginBackoffFreeAttempts + 3
+	results := make(chan error, guesses)
+	for i := 0; i < guesses; i++ {
+		go func() {
+			_, _, err := verifyPlayerCredentials("dave", "wrong", "10.0.0.6")
+			results <- err
+		}()
+	}
+	invalid := 0
+	for i := 0; i < guesses; i++ {
+		if err := <-results; errors.Is(err, ErrInvalidCredentials) {
+			invalid++
+		}
+	}
+	if invalid != loginBackoffFreeAttempts {
+		t.Errorf("%d guesses were checked, want %d before the backoff", invalid, loginBackoffFreeAttempts)
+	}
+}
+
+func TestOldLoginAttemptsAreDropped(t *testing.T) {
+	useTestDB(t)
+	if _, err := createPlayer("erin", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().UTC().Add(-loginAttemptRetention() - time.Minute)
+	if _, err := recordLoginAttempt(db, "erin", "", "10.0.0.7", old); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := verifyPlayerCredentials("erin", "secret", "10.0.0.8"); err != nil {
+		t.Fatal(err)
+	}
+
+	var ips []string
+	rows, err := db.Query(`SELECT ip FROM login_attempts`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ip string
+		if err := rows.Scan(&ip); err != nil {
+			t.Fatal(err)
+		}
+		ips = append(ips, ip)
+	}
+	if len(ips) != 1 || ips[0] != "10.0.0.8" {
+		t.Errorf("login attempts from %v, want only the new one", ips)
+	}
+}
+
+func TestLoginBlocksIPAfterTooManyFailures(t *testing.T) {
+	useTestDB(t)
+	if _, err := createPlayer("carol", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	for i := 0; i < config.LoginIPFailureLimit; i++ {
+		if _, err := recordLoginAttempt(db, "unknown", "", "10.0.0.4", now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, _, err := verifyPlayerCredentials("carol", "secret", "10.0.0.4")
+	var blocked *LoginBlockedError
+	if !errors.As(err, &blocked) || blocked.RetryAfter <= 0 || blocked.RetryAfter > config.LoginIPFailureWindow {
+		t.Fatalf("err = %v, want the IP to be blocked", err)
+	}
+
+	if _, _, err := verifyPlayerCredentials("carol", "secret", "10.0.0.5"); err != nil {
+		t.Errorf("login from another IP: %v", err)
+	}
+}
@@ -6,20 +6,31 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/Daweenci/Web_Lobby/game"
 )
 
 // Globales CORS Middleware - wird für ALLE Requests angewendet
 func corsHandler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// CORS Headers für alle Requests setzen
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && allowedOrigins.allows(origin)
+
+		// CORS Headers nur für erlaubte Origins setzen
+		w.Header().Add("Vary", "Origin")
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		}
 
 		// OPTIONS Preflight Request behandeln
 		if r.Method == "OPTIONS" {
+			if !allowed {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
 			w.WriteHeader(http.StatusOK)
 			return
 		}
@@ -30,24 +41,42 @@ func corsHandler(h http.Handler) http.Handler {
 }
 
 func main() {
-	err := godotenv.Load(".env")
+	// Konfiguration aus Env, .env und CONFIG_FILE laden - ohne gültige Config kein Start
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	config = cfg
+
+	// Kartendeck-Definition prüfen - mit kaputtem Deck kein Start
+	deck, err := game.StandardDeck()
 	if err != nil {
-		log.Println("No .env file found, relying on system env vars")
+		log.Fatalf("Invalid deck definition: %v", err)
 	}
+	log.Printf("Using deck %s with %d cards", deck.Version, deck.Size())
+
+	// Erlaubte Origins für CORS und Websocket, im Dev-Modus auch die Vite-Ports
+	allowedOrigins = newOriginAllowlist(config.AllowedOrigins, config.DevMode)
+
+	// Per-message-deflate aushandeln, wenn WS_COMPRESSION gesetzt ist
+	upgrader.EnableCompression = config.WSCompression
 
 	if err := initDB(); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer closeDB()
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		log.Println("Shutting down ...")
-		closeDB()
-		os.Exit(0)
-	}()
+	// Lobbies und Spiele vom letzten Lauf wiederherstellen, ab jetzt jede Änderung speichern
+	if err := restoreLobbies(); err != nil {
+		log.Printf("Failed to restore lobbies: %v", err)
+	}
+	startLobbyPersister()
+
+	// Plätze von Spielern, die nicht zurückkommen, wieder freigeben
+	time.AfterFunc(config.SeatReclaimTimeout, releaseOfflineSeats)
+
+	// Volle Token-Buckets regelmäßig aufräumen
+	go pruneRateLimits()
 
 	// Einen neuen ServeMux erstellen
 	mux := http.NewServeMux()
@@ -56,17 +85,34 @@ func main() {
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "client/index.html")
 	})
-	mux.HandleFunc("/login", handleLogin)
-	mux.HandleFunc("/register", handleRegister)
+	mux.HandleFunc("/login", rateLimitHTTP(RequestLogin, ResponseLoginFailed, handleLogin))
+	mux.HandleFunc("/register", rateLimitHTTP(RequestRegister, ResponseRegisterFailed, handleRegister))
 	mux.HandleFunc("/ws", handleWebSocket)
+	mux.HandleFunc("/ws-ticket", rateLimitHTTP(RequestWSTicket, ResponseError, handleWSTicket))
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "4000"
+	// WICHTIG: corsHandler wraps den gesamten mux, beim Herunterfahren wird jede Anfrage abgelehnt
+	srv := &http.Server{Addr: ":" + config.Port, Handler: corsHandler(refuseWhileShuttingDown(mux))}
+
+	// Bei SIGTERM Spieler warnen, Lobbies speichern und sauber herunterfahren
+	shutdownDone := make(chan struct{})
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-c
+		gracefulShutdown(srv)
+		close(shutdownDone)
+	}()
+
+	// Nachrichten-Metriken nur auf einer eigenen, nicht öffentlichen Adresse anbieten
+	if config.MetricsAddr != "" {
+		go serveMetrics(config.MetricsAddr)
 	}
 
-	log.Printf("Server started on :%s with CORS enabled", port)
+	log.Printf("Server started on :%s, allowed origins: %s", config.Port, allowedOrigins)
 
-	// WICHTIG: corsHandler wraps den gesamten mux
-	log.Fatal(http.ListenAndServe(":"+port, corsHandler(mux)))
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-shutdownDone
+	log.Println("Server stopped")
 }
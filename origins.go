@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Ports the Vite dev server and preview pick, allowed for localhost in dev mode.
+// Vite counts up from 5173 when the port is taken.
+const (
+	viteFirstDevPort = 5173
+	viteLastDevPort  = 5183
+	vitePreviewPort  = 4173
+)
+
+// originAllowlist decides which browser origins may call the HTTP API and
+// open a websocket.
+type originAllowlist struct {
+	origins map[string]bool
+	dev     bool // also allow the Vite ports on localhost
+}
+
+var allowedOrigins = originAllowlist{}
+
+// newOriginAllowlist allows origins like "https://example.com"
+func newOriginAllowlist(origins []string, dev bool) originAllowlist {
+	a := originAllowlist{origins: make(map[string]bool), dev: dev}
+	for _, origin := range origins {
+		if origin = normalizeOrigin(origin); origin != "" {
+			a.origins[origin] = true
+		}
+	}
+	return a
+}
+
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/")
+}
+
+func (a originAllowlist) allows(origin string) bool {
+	origin = normalizeOrigin(origin)
+	if origin == "" {
+		return false
+	}
+	if a.origins[origin] {
+		return true
+	}
+	return a.dev && isViteDevOrigin(origin)
+}
+
+func isViteDevOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme != "http" {
+		return false
+	}
+
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+	default:
+		return false
+	}
+
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return false
+	}
+	return port == vitePreviewPort || (port >= viteFirstDevPort && port <= viteLastDevPort)
+}
+
+// isSameOrigin reports whether the request comes from a page this server served
+func isSameOrigin(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// checkOrigin is used for the websocket upgrade. Clients that are not
+// browsers send no Origin header and are let through, the token still has
+// to be valid.
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || isSameOrigin(r, origin) {
+		return true
+	}
+	return allowedOrigins.allows(origin)
+}
+
+func (a originAllowlist) String() string {
+	origins := make([]string, 0, len(a.origins)+1)
+	for origin := range a.origins {
+		origins = append(origins, origin)
+	}
+	sort.Strings(origins)
+	if a.dev {
+		origins = append(origins, "localhost vite ports")
+	}
+	if len(origins) == 0 {
+		return "same origin only"
+	}
+	return strings.Join(origins, ", ")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOriginAllowlist(t *testing.T) {
+	prod := newOriginAllowlist([]string{" https://lobby.example.com/ ", "https://www.example.com"}, false)
+	dev := newOriginAllowlist(nil, true)
+
+	tests := []struct {
+		list   originAllowlist
+		origin string
+		want   bool
+	}{
+		{prod, "https://lobby.example.com", true},
+		{prod, "HTTPS://WWW.EXAMPLE.COM", true},
+		{prod, "https://evil.example.com", false},
+		{prod, "http://localhost:5173", false},
+		{prod, "", false},
+		{dev, "http://localhost:5173", true},
+		{dev, "http://127.0.0.1:5175", true},
+		{dev, "http://localhost:4173", true},
+		{dev, "http://localhost:8080", false},
+		{dev, "https://localhost:5173", false},
+		{dev, "http://example.com:5173", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.list.allows(tt.origin); got != tt.want {
+			t.Errorf("%v allows(%q) = %v, want %v", tt.list, tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	old := allowedOrigins
+	allowedOrigins = newOriginAllowlist([]string{"https://lobby.example.com"}, false)
+	t.Cleanup(func() { allowedOrigins = old })
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},                          // not a browser
+		{"http://game.local:4000", true},    // page served by this server
+		{"https://lobby.example.com", true}, // allowlisted
+		{"https://evil.example.com", false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "http://game.local:4000/ws", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := checkOrigin(r); got != tt.want {
+			t.Errorf("checkOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
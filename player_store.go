@@ -52,32 +52,6 @@ func createPlayer(username, password string) (string, error) {
 	return playerID, nil
 }
 
-func verifyPlayerCredentials(username, password string) (string, error) {
-	var playerID string
-	var passwordHash string
-
-	query := `SELECT id, password_hash FROM players WHERE username = ?`
-
-	err := db.QueryRow(query, username).Scan(&playerID, &passwordHash)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return "", fmt.Errorf("invalid credentials")
-		}
-		return "", fmt.Errorf("database error: %w", err)
-	}
-
-	if !verifyPassword(passwordHash, password) {
-		return "", fmt.Errorf("invalid credentials")
-	}
-
-	_, err = db.Exec(`UPDATE players SET last_login = CURRENT_TIMESTAMP WHERE id = ?`, playerID)
-	if err != nil {
-		log.Printf("Failed to update last login for player %s: %v", playerID, err)
-	}
-
-	return playerID, nil
-}
-
 func getPlayerByID(playerID string) (*PlayerDB, error) {
 	var player PlayerDB
 
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"log"
+	"slices"
+
+	"github.com/Daweenci/Web_Lobby/game"
+	"github.com/google/uuid"
+)
+
+// Admins can load a position, e.g. from a bug report, into a practice
+// lobby. The admin is the lobby's only player and plays every seat of the
+// loaded game in turn. Nobody can join, and the game stays until the lobby
+// is closed.
+
+const practiceLobbyName = "Practice"
+
+// isAdmin reports whether the player is listed in config.Admins
+func isAdmin(p *Player) bool {
+	return slices.Contains(config.Admins, p.Name)
+}
+
+// actingPlayerID is the game player that player acts as: themselves, or in
+// a practice lobby the player whose turn it is, or during the exchange the
+// next player to choose a card. The caller must hold lobby.Lock
+func actingPlayerID(lobby *Lobby, player *Player) string {
+	g := lobby.Game
+	if !lobby.Practice || g == nil {
+		return player.ID
+	}
+	if g.Phase == game.PhaseExchange {
+		for _, p := range g.Players {
+			if p.Partner != "" && p.Exchange == nil {
+				return p.ID
+			}
+		}
+	}
+	return g.CurrentPlayer().ID
+}
+
+func loadPositionHandler(msg LoadPositionRequest) {
+	activePlayersLock.RLock()
+	player, ok := activePlayers[msg.PlayerID]
+	activePlayersLock.RUnlock()
+	if !ok {
+		log.Println("loadPositionHandler: Player not found")
+		disconnectPlayer(msg.PlayerID)
+		return
+	}
+
+	if !isAdmin(player) {
+		sendErrorToPlayer(player, msg.RequestID, ErrorNotAdmin, "Only admins can load positions")
+		return
+	}
+	deck, err := game.StandardDeck()
+	if err != nil {
+		log.Printf("loadPositionHandler: %v", err)
+		sendErrorToPlayer(player, msg.RequestID, ErrorInternal, "Failed to load the deck")
+		return
+	}
+	g, err := game.ParseNotation(msg.Position, deck)
+	if err != nil {
+		sendErrorToPlayer(player, msg.RequestID, ErrorInvalidRequest, err.Error())
+		return
+	}
+
+	lobby := newPracticeLobby(player, g)
+	dto := startPracticeTimer(lobby)
+
+	lobbiesLock.Lock()
+	lobbies[lobby.ID] = lobby
+	lobbiesLock.Unlock()
+
+	log.Printf("Admin %s loaded a position into practice lobby %s", player.Name, lobby.ID)
+	sendResponse(player, CreateLobbyResponse{
+		BaseResponse: newReplyResponse(ResponseLobbyCreated, msg.RequestID),
+		Lobby:        dto,
+	})
+	broadcastLobbyAdded(lobby)
+	broadcastGameState(lobby)
+}
+
+// startPracticeTimer starts the turn timer of a new practice lobby and
+// returns the lobby
+func startPracticeTimer(lobby *Lobby) LobbyDTO {
+	lobby.Lock.Lock()
+	defer lobby.Lock.Unlock()
+
+	resetTurnTimer(lobby)
+	return toLobbyDTO(lobby)
+}
+
+// newPracticeLobby seats the admin alone in a private lobby with the game
+func newPracticeLobby(admin *Player, g *game.Game) *Lobby {
+	return &Lobby{
+		ID:         uuid.New().String(),
+		Name:       practiceLobbyName,
+		MaxPlayers: 1,
+		IsPrivate:  true,
+		Players:    []*Player{admin},
+		GameStart:  []PlayerStarted{{ID: admin.ID}},
+		Teams:      make(map[string]int),
+		Rules:      g.Rules,
+		Practice:   true,
+		Game:       g,
+		Version:    1,
+	}
+}
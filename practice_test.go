@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Daweenci/Web_Lobby/game"
+)
+
+func TestAdminPlaysEverySeatOfALoadedPosition(t *testing.T) {
+	useTestLobbies(t)
+	oldAdmins := config.Admins
+	config.Admins = []string{"Alice"}
+	t.Cleanup(func() { config.Admins = oldAdmins })
+
+	admin := newPlayer("admin", "Alice", nil)
+	other := newPlayer("other", "Bob", nil)
+	activePlayers[admin.ID] = admin
+	activePlayers[other.ID] = other
+
+	const position = "v1 standard-1 1/1/0/0 play x,X,,t5.s.s.s,two1,-;y,Y,,s.s.s.s,king2,- - - - - 0"
+	loadPositionHandler(LoadPositionRequest{BaseRequest: BaseRequest{PlayerID: other.ID}, Position: position})
+	if len(lobbies) != 0 {
+		t.Fatal("a player that is no admin loaded a position")
+	}
+
+	loadPositionHandler(LoadPositionRequest{BaseRequest: BaseRequest{PlayerID: admin.ID}, Position: position})
+	if len(lobbies) != 1 {
+		t.Fatalf("%d lobbies, want the practice lobby", len(lobbies))
+	}
+	var lobby *Lobby
+	for _, l := range lobbies {
+		lobby = l
+	}
+	if !lobby.Practice || lobby.Game == nil || !isLobbyStarted(lobby) {
+		t.Fatalf("practice lobby %+v", lobby)
+	}
+
+	for _, seat := range []string{"x", "y"} {
+		if id := actingPlayerID(lobby, admin); id != seat {
+			t.Fatalf("admin acts as %q, want %q", id, seat)
+		}
+		var play game.Play
+		if seat == "x" {
+			play = game.Play{CardID: 1, Effect: game.EffectMove, FigureID: 0, Moves: 2}
+		} else {
+			play = game.Play{CardID: 2, Effect: game.EffectStart, FigureID: 0}
+		}
+		playCardHandler(PlayCardRequest{BaseRequest: BaseRequest{PlayerID: admin.ID}, LobbyID: lobby.ID, Play: play})
+	}
+
+	x, y := lobby.Game.Players[0].Figures[0], lobby.Game.Players[1].Figures[0]
+	if x.Position != 7 || y.Status != game.FigureOnTrack {
+		t.Errorf("figures %+v and %+v after both plays", x, y)
+	}
+}
+
+func TestRemovedPracticeLobbyStopsItsTimer(t *testing.T) {
+	const position = "v1 standard-1 1/1/0/0 play x,X,,t5.s.s.s,two1,-;y,Y,,s.s.s.s,king2,- - - - - 0"
+
+	for _, leave := range []struct {
+		name  string
+		leave func(admin *Player, lobby *Lobby)
+	}{
+		{"leave", func(admin *Player, lobby *Lobby) {
+			leaveLobbyHandler(LeaveLobbyRequest{BaseRequest: BaseRequest{PlayerID: admin.ID}, LobbyID: lobby.ID})
+		}},
+		{"disconnect", func(admin *Player, _ *Lobby) { disconnectPlayer(admin.ID) }},
+	} {
+		t.Run(leave.name, func(t *testing.T) {
+			useTestLobbies(t)
+			oldAdmins := config.Admins
+			config.Admins = []string{"Alice"}
+			t.Cleanup(func() { config.Admins = oldAdmins })
+
+			admin := newPlayer("admin", "Alice", nil)
+			activePlayers[admin.ID] = admin
+			loadPositionHandler(LoadPositionRequest{BaseRequest: BaseRequest{PlayerID: admin.ID}, Position: position})
+			var lobby *Lobby
+			for _, l := range lobbies {
+				lobby = l
+			}
+			if lobby == nil {
+				t.Fatal("no practice lobby")
+			}
+			lobby.Lock.Lock()
+			lobby.Rules.TurnTimerSeconds = 60
+			resetTurnTimer(lobby)
+			lobby.Lock.Unlock()
+
+			leave.leave(admin, lobby)
+
+			if _, ok := lobbies[lobby.ID]; ok {
+				t.Fatal("practice lobby was not removed")
+			}
+			if lobby.Game != nil || lobby.turnTimer != nil || !lobby.turnDeadline.IsZero() {
+				t.Errorf("removed practice lobby keeps its game (%t) or turn timer (%t)", lobby.Game != nil, lobby.turnTimer != nil)
+			}
+		})
+	}
+}
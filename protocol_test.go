@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/Daweenci/Web_Lobby/internal/protogen"
+)
+
+// TestGeneratedProtocolIsUpToDate fails when the committed schema or client
+// types no longer match the Go protocol types. Run `go generate` to fix it.
+func TestGeneratedProtocolIsUpToDate(t *testing.T) {
+	out, err := protogen.Generate(protogen.DefaultDirs...)
+	if err != nil {
+		t.Fatalf("generate protocol: %v", err)
+	}
+
+	for path, want := range map[string][]byte{
+		protogen.SchemaFile:     out.Schema,
+		protogen.TypeScriptFile: out.TypeScript,
+	} {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read %s: %v", path, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s is stale, run `go generate` in the module root", path)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// rateLimit is a token bucket: Burst messages at once, refilled at Rate
+// messages per second. A zero Burst means no limit.
+type rateLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst float64 `json:"burst"`
+}
+
+// messageRateLimit limits one message type per authenticated player and per
+// remote IP. The IP limit is looser since several players can share one
+// address.
+type messageRateLimit struct {
+	Player rateLimit `json:"player"`
+	IP     rateLimit `json:"ip"`
+}
+
+// unknownMessageKind is the bucket shared by all message types the server
+// does not know, so made up types can not be used to get fresh buckets
+const unknownMessageKind = "unknown"
+
+var (
+	// Used for every message type without an entry in config.RateLimits
+	defaultRateLimit = messageRateLimit{
+		Player: rateLimit{Rate: 10, Burst: 30},
+		IP:     rateLimit{Rate: 40, Burst: 120},
+	}
+
+	// The limits config.RateLimits starts out with, RATE_LIMITS overrides
+	// single message types
+	defaultMessageRateLimits = map[MessageType]messageRateLimit{
+		RequestLogin: {
+			IP: rateLimit{Rate: 0.2, Burst: 10},
+		},
+		RequestRegister: {
+			IP: rateLimit{Rate: 0.05, Burst: 3},
+		},
+		RequestAuthentication: {
+			IP: rateLimit{Rate: 1, Burst: 10},
+		},
+		RequestWSTicket: {
+			IP: rateLimit{Rate: 1, Burst: 10},
+		},
+		RequestCreateLobby: {
+			Player: rateLimit{Rate: 0.2, Burst: 3},
+			IP:     rateLimit{Rate: 0.5, Burst: 10},
+		},
+		RequestAddFriend: {
+			Player: rateLimit{Rate: 0.2, Burst: 5},
+			IP:     rateLimit{Rate: 0.5, Burst: 15},
+		},
+		RequestJoinLobby: {
+			Player: rateLimit{Rate: 1, Burst: 5},
+			IP:     rateLimit{Rate: 4, Burst: 20},
+		},
+		RequestResync: {
+			Player: rateLimit{Rate: 0.5, Burst: 3},
+			IP:     rateLimit{Rate: 2, Burst: 10},
+		},
+		RequestGetLegalMoves: { // tries every play of the hand
+			Player: rateLimit{Rate: 2, Burst: 5},
+			IP:     rateLimit{Rate: 5, Burst: 20},
+		},
+	}
+
+	// A connection that keeps sending throttled messages drains this bucket
+	// and gets closed
+	rateViolationLimit = rateLimit{Rate: 0.5, Burst: 20}
+
+	// Buckets that were full for this long are forgotten
+	rateBucketIdle = 10 * time.Minute
+)
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket for the time passed since the last call and takes
+// one token. If the bucket is empty it returns how long until the next token.
+func (b *tokenBucket) take(limit rateLimit, now time.Time) (bool, time.Duration) {
+	b.tokens = math.Min(limit.Burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if limit.Rate <= 0 {
+		return false, rateBucketIdle
+	}
+	return false, time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+}
+
+type rateBucketKey struct {
+	subject string // player ID or IP
+	kind    string // message type, unknownMessageKind or "violations"
+}
+
+// rateLimiter holds the buckets of every player and IP
+type rateLimiter struct {
+	lock    sync.Mutex
+	buckets map[rateBucketKey]*tokenBucket
+}
+
+var rateLimits = &rateLimiter{buckets: make(map[rateBucketKey]*tokenBucket)}
+
+func (l *rateLimiter) take(key rateBucketKey, limit rateLimit, now time.Time) (bool, time.Duration) {
+	if limit.Burst <= 0 {
+		return true, 0
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: limit.Burst, last: now}
+		l.buckets[key] = b
+	}
+	return b.take(limit, now)
+}
+
+// knownMessageType reports whether the server handles messages of type t,
+// over the websocket or as one of the requests before authentication
+func knownMessageType(t MessageType) bool {
+	_, routed := messageRoutes[t]
+	_, limited := defaultMessageRateLimits[t]
+	return routed || limited
+}
+
+// allow charges one message of type t to the player and to ip. playerID is
+// empty for requests that are not authenticated yet. t is checked before
+// the dispatcher rejects unknown types, so those all share one bucket.
+func (l *rateLimiter) allow(playerID, ip string, t MessageType) (bool, time.Duration) {
+	kind := string(t)
+	if !knownMessageType(t) {
+		kind = unknownMessageKind
+	}
+	limit, ok := config.RateLimits[t]
+	if !ok {
+		limit = defaultRateLimit
+	}
+
+	now := time.Now()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if playerID != "" {
+		if ok, retryAfter := l.take(rateBucketKey{playerID, kind}, limit.Player, now); !ok {
+			return false, retryAfter
+		}
+	}
+	return l.take(rateBucketKey{ip, kind}, limit.IP, now)
+}
+
+// violation records a throttled message of a connection. It returns false
+// once the connection was throttled too often and should be closed.
+func (l *rateLimiter) violation(subject string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	ok, _ := l.take(rateBucketKey{subject, "violations"}, rateViolationLimit, time.Now())
+	return ok
+}
+
+// prune forgets buckets that have been refilled completely
+func (l *rateLimiter) prune() {
+	now := time.Now()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > rateBucketIdle {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func pruneRateLimits() {
+	ticker := time.NewTicker(rateBucketIdle)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		rateLimits.prune()
+	}
+}
+
+// remoteIP returns the IP address of the client without the port
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// retryAfterMs rounds retryAfter up to whole milliseconds
+func retryAfterMs(retryAfter time.Duration) int64 {
+	return int64((retryAfter + time.Millisecond - 1) / time.Millisecond)
+}
+
+// allowWebSocketMessage checks the rate limits for one websocket message and
+// answers throttled messages. It returns false together with closeConn when
+// the connection is abusive and has to be closed.
+func allowWebSocketMessage(conn *websocket.Conn, player *Player, ip string, base BaseRequest) (allowed, closeConn bool) {
+	playerID, subject := "", ip
+	if player != nil {
+		playerID, subject = player.ID, player.ID
+	}
+
+	ok, retryAfter := rateLimits.allow(playerID, ip, base.Type)
+	if ok {
+		return true, false
+	}
+
+	if !rateLimits.violation(subject) {
+		log.Printf("Closing connection of %s (%s): too many throttled messages", subject, ip)
+		conn.WriteControl(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Rate limit exceeded"),
+			time.Now().Add(config.WriteWait),
+		)
+		return false, true
+	}
+
+	r := ErrorResponse{
+		BaseResponse: newReplyResponse(ResponseError, base.RequestID),
+		Code:         ErrorRateLimited,
+		Error:        "Too many requests, slow down",
+		RetryAfterMs: retryAfterMs(retryAfter),
+	}
+	if player != nil {
+		sendResponse(player, r)
+	} else {
+		writeToConn(conn, r)
+	}
+	return false, false
+}
+
+// rateLimitHTTP limits the requests of one IP to handler as if they were
+// messages of type t. Throttled requests get a 429 with a Retry-After header.
+func rateLimitHTTP(t MessageType, failed MessageType, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, retryAfter := rateLimits.allow("", remoteIP(r), t)
+		if !ok {
+			seconds := int64(math.Ceil(retryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(AuthResponse{
+				Type:         failed,
+				Code:         ErrorRateLimited,
+				Message:      "Too many requests, try again later",
+				RetryAfterMs: retryAfterMs(retryAfter),
+			})
+			return
+		}
+		handler(w, r)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketRefillsAtRate(t *testing.T) {
+	limit := rateLimit{Rate: 2, Burst: 3}
+	now := time.Now()
+	b := &tokenBucket{tokens: limit.Burst, last: now}
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := b.take(limit, now); !ok {
+			t.Fatalf("message %d within burst was throttled", i)
+		}
+	}
+
+	ok, retryAfter := b.take(limit, now)
+	if ok {
+		t.Fatal("message beyond burst was allowed")
+	}
+	if retryAfter != 500*time.Millisecond {
+		t.Errorf("retryAfter = %v, want 500ms", retryAfter)
+	}
+
+	if ok, _ := b.take(limit, now.Add(retryAfter)); !ok {
+		t.Error("message after retryAfter was throttled")
+	}
+}
+
+func TestRateLimiterSeparatesPlayersAndMessageTypes(t *testing.T) {
+	l := &rateLimiter{buckets: make(map[rateBucketKey]*tokenBucket)}
+	burst := int(config.RateLimits[RequestCreateLobby].Player.Burst)
+
+	for i := 0; i < burst; i++ {
+		if ok, _ := l.allow("p1", "10.0.0.1", RequestCreateLobby); !ok {
+			t.Fatalf("create_lobby %d was throttled", i)
+		}
+	}
+	if ok, _ := l.allow("p1", "10.0.0.1", RequestCreateLobby); ok {
+		t.Error("create_lobby beyond the player burst was allowed")
+	}
+
+	if ok, _ := l.allow("p2", "10.0.0.1", RequestCreateLobby); !ok {
+		t.Error("another player on the same IP was throttled")
+	}
+	if ok, _ := l.allow("p1", "10.0.0.1", RequestJoinLobby); !ok {
+		t.Error("another message type was throttled")
+	}
+}
+
+func TestRateLimiterLimitsIPBeforeAuthentication(t *testing.T) {
+	l := &rateLimiter{buckets: make(map[rateBucketKey]*tokenBucket)}
+	burst := int(config.RateLimits[RequestLogin].IP.Burst)
+
+	for i := 0; i < burst; i++ {
+		l.allow("", "10.0.0.2", RequestLogin)
+	}
+	if ok, retryAfter := l.allow("", "10.0.0.2", RequestLogin); ok || retryAfter <= 0 {
+		t.Errorf("login beyond the IP burst: ok=%v retryAfter=%v", ok, retryAfter)
+	}
+	if ok, _ := l.allow("", "10.0.0.3", RequestLogin); !ok {
+		t.Error("login from another IP was throttled")
+	}
+}
+
+func TestRateLimiterSharesOneBucketForUnknownTypes(t *testing.T) {
+	l := &rateLimiter{buckets: make(map[rateBucketKey]*tokenBucket)}
+	burst := int(defaultRateLimit.Player.Burst)
+
+	for i := 0; i < burst; i++ {
+		if ok, _ := l.allow("p1", "10.0.0.4", MessageType(fmt.Sprintf("made_up_%d", i))); !ok {
+			t.Fatalf("unknown message %d was throttled", i)
+		}
+	}
+	if ok, _ := l.allow("p1", "10.0.0.4", "yet_another_type"); ok {
+		t.Error("a new unknown type got a fresh bucket")
+	}
+	if len(l.buckets) != 2 {
+		t.Errorf("%d buckets, want one for the player and one for the IP", len(l.buckets))
+	}
+	if ok, _ := l.allow("p1", "10.0.0.4", RequestJoinLobby); !ok {
+		t.Error("a known type was throttled by the unknown ones")
+	}
+}
@@ -5,7 +5,35 @@ CREATE TABLE IF NOT EXISTS players (
     username TEXT UNIQUE NOT NULL,
     password_hash TEXT NOT NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    last_login DATETIME
+    last_login DATETIME,
+    last_failed_login DATETIME,
+    failed_login_count INTEGER NOT NULL DEFAULT 0,
+    locked_until DATETIME
+);
+
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT NOT NULL,
+    player_id TEXT,
+    ip TEXT NOT NULL,
+    succeeded INTEGER NOT NULL,
+    created_at DATETIME NOT NULL,
+
+    FOREIGN KEY (player_id) REFERENCES players(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_player ON login_attempts(player_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip ON login_attempts(ip, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_created ON login_attempts(created_at);
+
+
+-- Lobbies and their running games, saved on every change and restored on start
+CREATE TABLE IF NOT EXISTS saved_lobbies (
+    id TEXT PRIMARY KEY,
+    version INTEGER NOT NULL DEFAULT 0,
+    snapshot TEXT NOT NULL,
+    saved_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 
 
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// shuttingDown is set once the server stops taking new connections
+var shuttingDown atomic.Bool
+
+// gracefulShutdown warns the players, saves the lobbies and their games and
+// stops srv. New requests are refused from the start, see
+// refuseWhileShuttingDown.
+func gracefulShutdown(srv *http.Server) {
+	shuttingDown.Store(true)
+	log.Printf("Shutting down in %s ...", config.ShutdownGracePeriod)
+
+	deadline := time.Now().Add(config.ShutdownGracePeriod)
+	ticker := time.NewTicker(time.Second)
+	for {
+		secondsLeft := int(time.Until(deadline).Round(time.Second).Seconds())
+		if secondsLeft <= 0 {
+			break
+		}
+		broadcastShutdownNotice(secondsLeft)
+		<-ticker.C
+	}
+	ticker.Stop()
+
+	// Write the queued changes first, then a final snapshot of everything
+	stopLobbyPersister()
+	if err := saveAllLobbies(); err != nil {
+		log.Printf("Failed to save lobbies: %v", err)
+	}
+
+	closeAllConnections()
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.WriteWait)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("HTTP server shutdown: %v", err)
+	}
+}
+
+// refuseWhileShuttingDown answers every request with 503 once the shutdown
+// started, so no logins or websocket connections come in during the countdown
+func refuseWhileShuttingDown(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if shuttingDown.Load() {
+			w.Header().Set("Connection", "close")
+			http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func broadcastShutdownNotice(secondsLeft int) {
+	activePlayersLock.RLock()
+	players := make([]*Player, 0, len(activePlayers))
+	for _, p := range activePlayers {
+		players = append(players, p)
+	}
+	activePlayersLock.RUnlock()
+
+	for _, p := range players {
+		sendResponse(p, ServerShuttingDownResponse{
+			BaseResponse: newBaseResponse(ResponseServerShuttingDown),
+			SecondsLeft:  secondsLeft,
+		})
+	}
+}
+
+// closeAllConnections tells every client to reconnect later. The players
+// keep their seats, see removePlayerFromLobbies.
+func closeAllConnections() {
+	activePlayersLock.RLock()
+	players := make([]*Player, 0, len(activePlayers))
+	for _, p := range activePlayers {
+		players = append(players, p)
+	}
+	activePlayersLock.RUnlock()
+
+	for _, p := range players {
+		if p.Conn != nil {
+			p.Conn.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseServiceRestart, "Server restarting"),
+				time.Now().Add(config.WriteWait),
+			)
+		}
+		p.close()
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestShutdownRefusesNewRequests(t *testing.T) {
+	h := refuseWhileShuttingDown(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func() int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/login", nil))
+		return rec.Code
+	}
+
+	if code := serve(); code != http.StatusOK {
+		t.Errorf("before the shutdown answered %d", code)
+	}
+	shuttingDown.Store(true)
+	t.Cleanup(func() { shuttingDown.Store(false) })
+	if code := serve(); code != http.StatusServiceUnavailable {
+		t.Errorf("during the shutdown answered %d, want %d", code, http.StatusServiceUnavailable)
+	}
+}
@@ -1,27 +1,46 @@
 package main
 
+// The client's protocol types in client/src/protocol.ts and the JSON Schema in
+// protocol.schema.json are generated from the types in this package.
+//go:generate go run ./cmd/protogen
+
 import (
 	"sync"
+	"time"
 
+	"github.com/Daweenci/Web_Lobby/game"
 	"github.com/gorilla/websocket"
 )
 
 type MessageType string
 
+// ErrorCode is the machine-readable reason sent along with an error message.
+type ErrorCode string
+
 const (
 	Online  string = "online"
 	Offline string = "offline"
 
-	RequestAuthentication      MessageType = "authenticate"
-	RequestLogin               MessageType = "login"
-	RequestRegister            MessageType = "register"
-	RequestCreateLobby         MessageType = "create_lobby"
-	RequestJoinLobby           MessageType = "join_lobby"
-	RequestLeaveLobby          MessageType = "leave_lobby"
-	RequestStartGame           MessageType = "start_game"
-	RequestCancelGame          MessageType = "cancel_game"
-	RequestAddFriend           MessageType = "add_friend"
-	RequestAcceptFriendRequest MessageType = "accept_friend_request"
+	RequestAuthentication           MessageType = "authenticate"
+	RequestLogin                    MessageType = "login"
+	RequestRegister                 MessageType = "register"
+	RequestCreateLobby              MessageType = "create_lobby"
+	RequestJoinLobby                MessageType = "join_lobby"
+	RequestLeaveLobby               MessageType = "leave_lobby"
+	RequestStartGame                MessageType = "start_game"
+	RequestCancelGame               MessageType = "cancel_game"
+	RequestAddFriend                MessageType = "add_friend"
+	RequestAcceptFriendRequest      MessageType = "accept_friend_request"
+	RequestGetPendingFriendRequests MessageType = "get_pending_friend_requests"
+	RequestResync                   MessageType = "resync"
+	RequestSubscribeLobbies         MessageType = "subscribe_lobbies"
+	RequestPlayCard                 MessageType = "play_card"
+	RequestGetLegalMoves            MessageType = "get_legal_moves"
+	RequestSetTeam                  MessageType = "set_team"
+	RequestExchangeCard             MessageType = "exchange_card"
+	RequestPassTurn                 MessageType = "pass_turn"
+	RequestLoadPosition             MessageType = "load_position"
+	RequestWSTicket                 MessageType = "ws_ticket" // POST /ws-ticket, only used for rate limits
 
 	ResponseWelcome               MessageType = "welcome"
 	ResponseLoginSuccessful       MessageType = "login_successful"
@@ -31,6 +50,9 @@ const (
 	ResponseLobbyCreated          MessageType = "lobby_created"
 	ResponseLobbyList             MessageType = "lobby_list"
 	ResponseLobbyUpdated          MessageType = "lobby_updated"
+	ResponseLobbyAdded            MessageType = "lobby_added"
+	ResponseLobbyChanged          MessageType = "lobby_changed"
+	ResponseLobbyRemoved          MessageType = "lobby_removed"
 	ResponseJoinLobbySuccessful   MessageType = "join_lobby_successful"
 	ResponseJoinLobbyFailed       MessageType = "join_lobby_failed"
 	ResponseLobbyLeft             MessageType = "lobby_left"
@@ -40,7 +62,42 @@ const (
 	ResponseFriendRequestAccepted MessageType = "friend_request_accepted"
 	ResponseFriendOnlineStatus    MessageType = "friend_online_status"
 	ResponseFriendsList           MessageType = "friends_list"
+	ResponseSnapshot              MessageType = "snapshot"
+	ResponseWSTicket              MessageType = "ws_ticket"
+	ResponseServerShuttingDown    MessageType = "server_shutting_down"
+	ResponseGameState             MessageType = "game_state"
+	ResponseLegalMoves            MessageType = "legal_moves"
+	ResponseTurnSkipped           MessageType = "turn_skipped"
+	ResponseRoundStarted          MessageType = "round_started"
 	ResponseError                 MessageType = "error"
+
+	ErrorInvalidMessage         ErrorCode = "invalid_message"
+	ErrorInvalidRequest         ErrorCode = "invalid_request"
+	ErrorUnknownMessageType     ErrorCode = "unknown_message_type"
+	ErrorAuthenticationRequired ErrorCode = "authentication_required"
+	ErrorInvalidToken           ErrorCode = "invalid_token"
+	ErrorPlayerNotFound         ErrorCode = "player_not_found"
+	ErrorLobbyNotFound          ErrorCode = "lobby_not_found"
+	ErrorIncorrectPassword      ErrorCode = "incorrect_password"
+	ErrorLobbyFull              ErrorCode = "lobby_full"
+	ErrorCannotAddSelf          ErrorCode = "cannot_add_self"
+	ErrorAlreadyFriends         ErrorCode = "already_friends"
+	ErrorFriendRequestExists    ErrorCode = "friend_request_exists"
+	ErrorFriendRequestNotFound  ErrorCode = "friend_request_not_found"
+	ErrorInternal               ErrorCode = "internal_error"
+	ErrorRateLimited            ErrorCode = "rate_limited"
+	ErrorAccountLocked          ErrorCode = "account_locked"
+	ErrorInvalidCredentials     ErrorCode = "invalid_credentials"
+	ErrorGameNotStarted         ErrorCode = "game_not_started"
+	ErrorNotYourTurn            ErrorCode = "not_your_turn"
+	ErrorInvalidPlay            ErrorCode = "invalid_play"
+	ErrorGameOver               ErrorCode = "game_over"
+	ErrorNotLobbyHost           ErrorCode = "not_lobby_host"
+	ErrorTeamFull               ErrorCode = "team_full"
+	ErrorGameAlreadyStarted     ErrorCode = "game_already_started"
+	ErrorNoExchange             ErrorCode = "no_exchange"
+	ErrorNotAdmin               ErrorCode = "not_admin"
+	ErrorNotInLobby             ErrorCode = "not_in_lobby"
 )
 
 type Player struct {
@@ -48,6 +105,24 @@ type Player struct {
 	Name string
 	Conn *websocket.Conn
 	Send chan []byte
+	Done chan struct{} // closed once the player is disconnected
+
+	codec codec // wire encoding negotiated for Conn
+
+	closeOnce sync.Once
+	sendLock  sync.Mutex // keeps seq and the order in Send in step
+	seq       uint64     // sequence number of the last message queued for this connection
+
+	lobbySub *lobbySubscription
+}
+
+// BaseRequest holds the fields every inbound message has. RequestID is
+// optional and chosen by the client; it is echoed in the direct response
+// and in any error so the client can match them to the request.
+type BaseRequest struct {
+	Type      MessageType `json:"type"`
+	RequestID string      `json:"requestID,omitempty"`
+	PlayerID  string      `json:"-"` // set from the authenticated connection, never by the client
 }
 
 type PlayerDTO struct {
@@ -66,80 +141,150 @@ type PlayerStarted struct {
 }
 
 type LoginRequest struct {
-	Type     MessageType `json:"type"`
-	Name     string      `json:"name"`
-	Password string      `json:"password"`
+	BaseRequest
+	Name     string `json:"name"`
+	Password string `json:"password"`
 }
 
 type RegisterRequest struct {
-	Type     MessageType `json:"type"`
-	Name     string      `json:"name"`
-	Password string      `json:"password"`
+	BaseRequest
+	Name     string `json:"name"`
+	Password string `json:"password"`
 }
 
 type JoinLobbyRequest struct {
-	Type     MessageType `json:"type"`
-	LobbyID  string      `json:"lobbyID"`
-	PlayerID string      `json:"playerID"`
-	Name     string      `json:"name"`
-	Password string      `json:"password"`
+	BaseRequest
+	LobbyID  string `json:"lobbyID"`
+	Name     string `json:"-"` // set from the authenticated connection
+	Password string `json:"password"`
 }
 
 type CreateLobbyRequest struct {
-	Type       MessageType `json:"type"`
-	LobbyName  string      `json:"lobbyName"`
-	MaxPlayers int         `json:"maxPlayers"`
-	IsPrivate  bool        `json:"isPrivate"`
-	Password   string      `json:"password"`
-	PlayerID   string      `json:"playerID"`
-	PlayerName string      `json:"playerName"`
+	BaseRequest
+	LobbyName  string `json:"lobbyName"`
+	MaxPlayers int    `json:"maxPlayers"`
+	IsPrivate  bool   `json:"isPrivate"`
+	Password   string `json:"password"`
+	PlayerName string `json:"-"` // set from the authenticated connection
+
+	// TeamPlay seats four players as two teams. The host assigns the teams
+	// unless RandomTeams draws them when the game starts
+	TeamPlay    bool `json:"teamPlay"`
+	RandomTeams bool `json:"randomTeams"`
+
+	// Rules are the house rules of the lobby's games, the standard rules if
+	// omitted
+	Rules *game.Rules `json:"rules,omitempty"`
 }
 
 type LeaveLobbyRequest struct {
-	Type     MessageType `json:"type"`
-	LobbyID  string      `json:"lobbyID"`
-	PlayerID string      `json:"playerID"`
+	BaseRequest
+	LobbyID string `json:"lobbyID"`
 }
 
 type AddFriendRequest struct {
-	Type       MessageType `json:"type"`
-	FriendName string      `json:"friendName"`
-	PlayerID   string      `json:"playerID"`
+	BaseRequest
+	FriendName string `json:"friendName"`
 }
 
 type GetPendingFriendRequestsRequest struct {
-	Type     MessageType `json:"type"`
-	PlayerID string      `json:"playerID"`
+	BaseRequest
 }
 
 type AcceptFriendRequestRequest struct {
-	Type          MessageType `json:"type"`
-	FriendID      string      `json:"friendID"` //person that requested in the first place
-	AcceptRequest bool        `json:"acceptRequest"`
-	PlayerID      string      `json:"playerID"`
+	BaseRequest
+	FriendID      string `json:"friendID"` //person that requested in the first place
+	AcceptRequest bool   `json:"acceptRequest"`
+}
+
+type ResyncRequest struct {
+	BaseRequest
+}
+
+type LobbyFilter struct {
+	Search       string `json:"search"`       // case-insensitive part of the lobby name
+	MaxPlayers   int    `json:"maxPlayers"`   // 0 matches every size
+	Visibility   string `json:"visibility"`   // "public", "private" or "" for both
+	HasFreeSeats bool   `json:"hasFreeSeats"` // only lobbies that can still be joined
+	NotStarted   bool   `json:"notStarted"`   // hide lobbies with a running game
+	Rated        bool   `json:"rated"`        // only lobbies with the standard rules
+}
+
+type SubscribeLobbiesRequest struct {
+	BaseRequest
+	Filter   LobbyFilter `json:"filter"`
+	PageSize int         `json:"pageSize"` // 0 means all matching lobbies
 }
 
 type StartGame struct { //TODO: Why StartGame not response or request?
-	Type     MessageType `json:"type"`
-	LobbyID  string      `json:"lobbyID"`
-	PlayerID string      `json:"playerID"`
+	BaseRequest
+	LobbyID string `json:"lobbyID"`
 }
 
 type CancelGame struct { //TODO: Why CancelGame not response or request?
-	Type     MessageType `json:"type"`
-	LobbyID  string      `json:"lobbyID"`
-	PlayerID string      `json:"playerID"`
+	BaseRequest
+	LobbyID string `json:"lobbyID"`
+}
+
+type PlayCardRequest struct {
+	BaseRequest
+	LobbyID string    `json:"lobbyID"`
+	Play    game.Play `json:"play"`
+}
+
+type GetLegalMovesRequest struct {
+	BaseRequest
+	LobbyID string `json:"lobbyID"`
+}
+
+// SetTeamRequest lets the host put a player of the lobby into team 0 or 1
+type SetTeamRequest struct {
+	BaseRequest
+	LobbyID        string `json:"lobbyID"`
+	TargetPlayerID string `json:"playerID"`
+	Team           int    `json:"team"`
+}
+
+type ExchangeCardRequest struct {
+	BaseRequest
+	LobbyID string `json:"lobbyID"`
+	CardID  int    `json:"cardID"`
+}
+
+// LoadPositionRequest opens a practice lobby with the game of a position in
+// the notation of game.ParseNotation. Only admins may send it.
+type LoadPositionRequest struct {
+	BaseRequest
+	Position string `json:"position"`
+}
+
+// PassTurnRequest ends the player's turn without playing, if the lobby's
+// rules allow passing
+type PassTurnRequest struct {
+	BaseRequest
+	LobbyID string `json:"lobbyID"`
 }
 
 type Lobby struct {
-	ID         string
-	Name       string
-	MaxPlayers int
-	IsPrivate  bool
-	Password   string
-	Players    []*Player
-	GameStart  []PlayerStarted
-	Lock       sync.RWMutex
+	ID          string
+	Name        string
+	MaxPlayers  int
+	IsPrivate   bool
+	Password    string
+	Players     []*Player
+	GameStart   []PlayerStarted
+	TeamPlay    bool
+	RandomTeams bool
+	Teams       map[string]int // team of each player in team play
+	Rules       game.Rules
+	Practice    bool       // an admin plays every seat of a loaded position, see loadPositionHandler
+	Game        *game.Game // set while every player has pressed start
+	Version     uint64     // incremented on every change, guarded by Lock
+	Lock        sync.RWMutex
+
+	turnTimer    *time.Timer // ends the current turn when it runs out, see resetTurnTimer
+	turnDeadline time.Time   // when turnTimer runs out, zero without a timer
+	removed      bool        // set when the last player left, no one can join anymore
 }
 
 type Response interface {
@@ -148,12 +293,22 @@ type Response interface {
 
 type BaseResponse struct {
 	Type MessageType `json:"type"`
+	// Seq is filled in per connection when the message is queued, see Player.enqueue.
+	// It starts at 1 and increases by one for every message, so a gap means a lost update.
+	Seq uint64 `json:"seq,omitempty"`
+	// RequestID echoes the request this message is the direct response to
+	RequestID string `json:"requestID,omitempty"`
 }
 
 func newBaseResponse(t MessageType) BaseResponse {
 	return BaseResponse{Type: t}
 }
 
+// newReplyResponse is newBaseResponse for the direct response to a request
+func newReplyResponse(t MessageType, requestID string) BaseResponse {
+	return BaseResponse{Type: t, RequestID: requestID}
+}
+
 func (r BaseResponse) GetType() MessageType {
 	return r.Type
 }
@@ -174,6 +329,24 @@ type LobbyDTO struct {
 	IsPrivate  bool            `json:"isPrivate"`
 	Players    []PlayerDTO     `json:"players"`
 	GameStart  []PlayerStarted `json:"gameStart"`
+	Started    bool            `json:"started"`
+	Version    uint64          `json:"version"`
+
+	// HostID is the player that may assign the teams, the longest in the lobby
+	HostID      string         `json:"hostID"`
+	TeamPlay    bool           `json:"teamPlay"`
+	RandomTeams bool           `json:"randomTeams"`
+	Teams       map[string]int `json:"teams,omitempty"`
+
+	// DeckVersion is the deck definition the running game was dealt from
+	DeckVersion string `json:"deckVersion,omitempty"`
+
+	Rules game.Rules `json:"rules"`
+	// Rated games are played with the standard rules, see game.Rules.IsStandard
+	Rated bool `json:"rated"`
+	// Practice lobbies hold a loaded position, their only player plays
+	// every seat
+	Practice bool `json:"practice,omitempty"`
 }
 
 type LobbyUpdatedResponse struct {
@@ -183,12 +356,30 @@ type LobbyUpdatedResponse struct {
 
 type LobbiesUpdateResponse struct {
 	BaseResponse
-	Lobbies []LobbyDTO `json:"lobbies"`
+	Lobbies  []LobbyDTO `json:"lobbies"`
+	Total    int        `json:"total"` // number of matching lobbies, may exceed the page
+	PageSize int        `json:"pageSize"`
+}
+
+type LobbyAddedResponse struct {
+	BaseResponse
+	Lobby LobbyDTO `json:"lobby"`
+}
+
+type LobbyChangedResponse struct {
+	BaseResponse
+	Lobby LobbyDTO `json:"lobby"`
+}
+
+type LobbyRemovedResponse struct {
+	BaseResponse
+	LobbyID string `json:"lobbyID"`
 }
 
 type LobbyJoinFailedResponse struct {
 	BaseResponse
-	Message string `json:"message"`
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
 }
 
 type SuccessfulJoinLobbyResponse struct {
@@ -207,8 +398,9 @@ type LobbyLeftResponse struct {
 
 type FriendRequestSentResponse struct {
 	BaseResponse
-	Success bool   `json:"success"`
-	Message string `json:"message"`
+	Success bool      `json:"success"`
+	Code    ErrorCode `json:"code,omitempty"` // set when Success is false
+	Message string    `json:"message"`
 }
 
 type PendingFriendRequestsResponse struct {
@@ -236,7 +428,77 @@ type FriendOnlineStatusResponse struct {
 	Friend FriendDTO `json:"friend"`
 }
 
+// ServerShuttingDownResponse counts down to a restart. Lobbies and games are
+// saved and can be rejoined after reconnecting.
+type ServerShuttingDownResponse struct {
+	BaseResponse
+	SecondsLeft int `json:"secondsLeft"`
+}
+
+// WSTicketResponse is the answer of POST /ws-ticket. The ticket opens one
+// websocket as ws://host/ws?ticket=...
+type WSTicketResponse struct {
+	BaseResponse
+	Ticket      string `json:"ticket"`
+	ExpiresInMs int64  `json:"expiresInMs"`
+}
+
+// SnapshotResponse carries the full state a client needs to rebuild its view,
+// sent in reply to a resync request.
+type SnapshotResponse struct {
+	BaseResponse
+	Player                PlayerDTO   `json:"player"`
+	Lobbies               []LobbyDTO  `json:"lobbies"`
+	Lobby                 *LobbyDTO   `json:"lobby"`          // lobby the player is in, if any
+	Game                  *game.View  `json:"game,omitempty"` // game in that lobby, if one runs
+	PendingFriendRequests []PlayerDTO `json:"pendingFriendRequests"`
+	FriendsList           []FriendDTO `json:"friendsList"`
+}
+
+// GameStateResponse is sent to every player of a lobby after the game
+// changed, each with their own view.
+type GameStateResponse struct {
+	BaseResponse
+	LobbyID string    `json:"lobbyID"`
+	Game    game.View `json:"game"`
+	// TurnDeadline is when the current turn ends, in Unix milliseconds.
+	// Only set if the lobby has a turn timer.
+	TurnDeadline int64 `json:"turnDeadline,omitempty"`
+}
+
+// LegalMovesResponse lists what the player can play at the given game
+// version
+type LegalMovesResponse struct {
+	BaseResponse
+	LobbyID string      `json:"lobbyID"`
+	Version uint64      `json:"version"`
+	Moves   []game.Move `json:"moves"`
+}
+
+// RoundStartedResponse is sent to every player of a lobby when a round was
+// dealt, with their new hand in the view
+type RoundStartedResponse struct {
+	BaseResponse
+	LobbyID string    `json:"lobbyID"`
+	Round   int       `json:"round"`
+	Dealer  string    `json:"dealer"`
+	Game    game.View `json:"game"`
+}
+
+// TurnSkippedResponse tells the lobby that a player could not or did not
+// play any card and discarded the hand, or passed and kept it
+type TurnSkippedResponse struct {
+	BaseResponse
+	LobbyID   string      `json:"lobbyID"`
+	PlayerID  string      `json:"playerID"`
+	Discarded []game.Card `json:"discarded"`
+	Passed    bool        `json:"passed,omitempty"`
+}
+
 type ErrorResponse struct {
 	BaseResponse
-	Error string `json:"error"`
+	Code  ErrorCode `json:"code"`
+	Error string    `json:"error"` // human readable, for display only
+	// RetryAfterMs tells a rate limited client when to try again
+	RetryAfterMs int64 `json:"retryAfterMs,omitempty"`
 }
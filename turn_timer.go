@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/Daweenci/Web_Lobby/game"
+)
+
+// A lobby whose rules set a turn timer gives every player that long for a
+// turn. When the time runs out the player passes or discards the hand, see
+// game.TimeOut. The timer only runs while the game waits for a play, not
+// during the card exchange.
+
+// resetTurnTimer starts the timer for the current turn, replacing the one
+// of the previous turn, or stops it if the game does not wait for a play.
+// The caller must hold lobby.Lock
+func resetTurnTimer(l *Lobby) {
+	if l.turnTimer != nil {
+		l.turnTimer.Stop()
+		l.turnTimer = nil
+	}
+	l.turnDeadline = time.Time{}
+
+	g := l.Game
+	if g == nil || l.Rules.TurnTimerSeconds == 0 || len(g.Winners) > 0 || g.Phase != game.PhasePlay {
+		return
+	}
+
+	d := time.Duration(l.Rules.TurnTimerSeconds) * time.Second
+	version := g.Version
+	l.turnDeadline = time.Now().Add(d)
+	l.turnTimer = time.AfterFunc(d, func() { turnTimedOut(l, version) })
+}
+
+// turnTimedOut ends the turn that was current at the given game version.
+// A timer that fired while the turn ended anyway does nothing.
+func turnTimedOut(lobby *Lobby, version uint64) {
+	lobby.Lock.Lock()
+	if lobby.Game == nil || lobby.Game.Version != version {
+		lobby.Lock.Unlock()
+		return
+	}
+	skip, err := lobby.Game.TimeOut()
+	var progress game.Progress
+	if err == nil {
+		progress = lobby.Game.Advance()
+		progress.Skips = append([]game.Skip{skip}, progress.Skips...)
+	}
+	resetTurnTimer(lobby)
+	lobby.Lock.Unlock()
+
+	if err != nil {
+		log.Printf("Turn timer in lobby %s: %v", lobby.ID, err)
+		return
+	}
+
+	log.Printf("Player %s ran out of time in lobby %s", skip.PlayerID, lobby.ID)
+	broadcastGameProgress(lobby, progress)
+	persistLobby(lobby)
+}
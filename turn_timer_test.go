@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Daweenci/Web_Lobby/game"
+)
+
+func TestTurnTimerEndsTheTurn(t *testing.T) {
+	l := &Lobby{
+		ID:         "lobby-1",
+		MaxPlayers: 2,
+		Players:    []*Player{newPlayer("p1", "Alice", nil), newPlayer("p2", "Bob", nil)},
+		GameStart:  []PlayerStarted{{ID: "p1"}, {ID: "p2"}},
+		Rules:      game.Rules{AllowPass: true, TurnTimerSeconds: 60},
+	}
+	l.Lock.Lock()
+	syncLobbyGame(l)
+	if l.Game == nil || l.turnDeadline.IsZero() {
+		l.Lock.Unlock()
+		t.Fatal("game started without a turn timer")
+	}
+	version, turn := l.Game.Version, l.Game.Turn
+	l.Lock.Unlock()
+	t.Cleanup(func() { l.turnTimer.Stop() })
+
+	// A timer of an earlier turn does nothing
+	turnTimedOut(l, version-1)
+	if l.Game.Version != version {
+		t.Fatal("stale timer changed the game")
+	}
+
+	turnTimedOut(l, version)
+	if l.Game.Version == version {
+		t.Errorf("turn of seat %d did not end", turn)
+	}
+	if l.turnDeadline.IsZero() {
+		t.Error("no timer for the next turn")
+	}
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
-	"encoding/json"
 	"log"
+	"math/rand/v2"
+	"slices"
+	"time"
 
+	"github.com/Daweenci/Web_Lobby/game"
 	"github.com/gorilla/websocket"
 )
 
@@ -14,18 +17,109 @@ func getLobbiesList() []LobbyDTO {
 
 	responseLobbies := make([]LobbyDTO, 0, len(lobbies))
 	for _, l := range lobbies {
-		responseLobbies = append(responseLobbies, LobbyDTO{
-			ID:         l.ID,
-			Name:       l.Name,
-			MaxPlayers: l.MaxPlayers,
-			IsPrivate:  l.IsPrivate,
-			Players:    toPlayerResponses(l.Players),
-			GameStart:  l.GameStart,
-		})
+		l.Lock.RLock()
+		responseLobbies = append(responseLobbies, toLobbyDTO(l))
+		l.Lock.RUnlock()
 	}
 	return responseLobbies
 }
 
+// Helper function to convert Lobby to LobbyDTO, the caller must hold lobby.Lock
+func toLobbyDTO(l *Lobby) LobbyDTO {
+	gameStart := make([]PlayerStarted, len(l.GameStart))
+	copy(gameStart, l.GameStart)
+
+	dto := LobbyDTO{
+		ID:          l.ID,
+		Name:        l.Name,
+		MaxPlayers:  l.MaxPlayers,
+		IsPrivate:   l.IsPrivate,
+		Players:     toPlayerResponses(l.Players),
+		GameStart:   gameStart,
+		Started:     isLobbyStarted(l),
+		Version:     l.Version,
+		TeamPlay:    l.TeamPlay,
+		RandomTeams: l.RandomTeams,
+		Rules:       l.Rules,
+		Rated:       l.Rules.IsStandard() && !l.Practice,
+		Practice:    l.Practice,
+	}
+	if len(l.Players) > 0 {
+		dto.HostID = l.Players[0].ID
+	}
+	if l.TeamPlay {
+		dto.Teams = lobbyTeams(l)
+	}
+	if l.Game != nil {
+		dto.DeckVersion = l.Game.DeckVersion
+	}
+	return dto
+}
+
+// isLobbyStarted reports whether every seat is taken, the teams are complete
+// and every player has pressed start. The caller must hold lobby.Lock
+func isLobbyStarted(l *Lobby) bool {
+	return len(l.Players) == l.MaxPlayers && len(l.GameStart) == len(l.Players) && teamsReady(l)
+}
+
+// syncLobbyGame starts the game once the lobby is started and drops it when
+// the lobby stops being started. It reports whether a game was started.
+// Practice lobbies keep their loaded game until their admin leaves. The
+// caller must hold lobby.Lock
+func syncLobbyGame(l *Lobby) bool {
+	if l.Practice {
+		if len(l.Players) == 0 && l.Game != nil {
+			l.Game = nil
+			resetTurnTimer(l)
+		}
+		return false
+	}
+
+	switch started := isLobbyStarted(l); {
+	case started && l.Game == nil:
+		players := gameSeating(l)
+		deck, err := game.StandardDeck()
+		if err != nil {
+			log.Printf("Cannot start game in lobby %s: %v", l.ID, err)
+			return false
+		}
+		l.Game = game.NewGame(players, deck, l.Rules, rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())))
+		// The first player may hold no playable card
+		l.Game.Advance()
+		resetTurnTimer(l)
+		log.Printf("Lobby %s started a game with deck %s", l.ID, l.Game.DeckVersion)
+		return true
+	case !started && l.Game != nil:
+		l.Game = nil
+		resetTurnTimer(l)
+	}
+	return false
+}
+
+// isSeated reports whether the player holds a seat in the lobby. The caller
+// must hold lobby.Lock
+func isSeated(l *Lobby, playerID string) bool {
+	return slices.ContainsFunc(l.Players, func(p *Player) bool { return p.ID == playerID })
+}
+
+// findPlayerLobby returns the lobby the player currently sits in, or nil
+func findPlayerLobby(playerID string) *Lobby {
+	lobbiesLock.RLock()
+	defer lobbiesLock.RUnlock()
+
+	for _, l := range lobbies {
+		l.Lock.RLock()
+		for _, p := range l.Players {
+			if p.ID == playerID {
+				l.Lock.RUnlock()
+				return l
+			}
+		}
+		l.Lock.RUnlock()
+	}
+	return nil
+}
+
 // Helper function to convert Player to DTO PlayerResponse
 func toPlayerResponses(players []*Player) []PlayerDTO {
 	res := make([]PlayerDTO, len(players))
@@ -45,19 +139,67 @@ func disconnectPlayer(playerID string) {
 	player, ok := activePlayers[playerID]
 	if !ok {
 		activePlayersLock.Unlock()
-		removePlayerFromLobbies(playerID)
+		vacateSeat(playerID)
 		return
 	}
 
 	delete(activePlayers, playerID)
 	activePlayersLock.Unlock()
 
-	close(player.Send)
-	player.Conn.Close()
-	removePlayerFromLobbies(playerID)
+	player.close()
+	vacateSeat(playerID)
+}
+
+// vacateSeat handles the seat of a player that disconnected. In a running
+// game the seat is kept for the player as offline, so a dropped connection
+// does not end the game for everyone; reconnecting reclaims it, see
+// reclaimSeats. It is freed if the player is not back within
+// config.SeatReclaimTimeout. Any other seat is freed right away.
+func vacateSeat(playerID string) {
+	lobby := findPlayerLobby(playerID)
+	if lobby == nil || shuttingDown.Load() {
+		return
+	}
+
+	lobby.Lock.Lock()
+	kept := keepSeatOffline(lobby, playerID)
+	lobby.Lock.Unlock()
+	if !kept {
+		removePlayerFromLobbies(playerID)
+		return
+	}
+
+	broadcastLobbyUpdate(lobby)
+	broadcastLobbyChanged(lobby)
+}
+
+// keepSeatOffline turns the player's seat in a running game into an offline
+// one, which is freed after config.SeatReclaimTimeout. It reports false if
+// the lobby has no running game or the player no seat there. The caller
+// must hold lobby.Lock
+func keepSeatOffline(lobby *Lobby, playerID string) bool {
+	i := slices.IndexFunc(lobby.Players, func(p *Player) bool { return p.ID == playerID })
+	if i < 0 || lobby.Game == nil || lobby.Practice {
+		return false
+	}
+	if lobby.Players[i].offline() {
+		return true
+	}
+
+	seat := newOfflinePlayer(playerID, lobby.Players[i].Name)
+	lobby.Players[i] = seat
+	lobby.Version++
+	log.Printf("Player %s went offline in lobby %s, keeping the seat for %s", playerID, lobby.ID, config.SeatReclaimTimeout)
+	time.AfterFunc(config.SeatReclaimTimeout, func() { releaseOfflineSeat(lobby, seat) })
+	return true
 }
 
 func removePlayerFromLobbies(playerID string) {
+	// Players keep their seats through a restart, the lobbies are saved with them
+	if shuttingDown.Load() {
+		return
+	}
+
 	lobbiesLock.RLock()
 
 	for _, lobby := range lobbies {
@@ -67,7 +209,10 @@ func removePlayerFromLobbies(playerID string) {
 			if lobby.Players[i].ID == playerID {
 
 				lobby.Players = append(lobby.Players[:i], lobby.Players[i+1:]...)
+				lobby.Version++
+				syncLobbyGame(lobby)
 				empty := len(lobby.Players) == 0
+				lobby.removed = empty
 
 				lobby.Lock.Unlock()
 				lobbiesLock.RUnlock()
@@ -78,8 +223,12 @@ func removePlayerFromLobbies(playerID string) {
 					lobbiesLock.Unlock()
 				}
 
-				broadcastLobbyUpdate(lobby)
-				broadcastLobbies()
+				if empty {
+					broadcastLobbyRemoved(lobby.ID)
+				} else {
+					broadcastLobbyUpdate(lobby)
+					broadcastLobbyChanged(lobby)
+				}
 				return
 			}
 		}
@@ -90,29 +239,108 @@ func removePlayerFromLobbies(playerID string) {
 	lobbiesLock.RUnlock()
 }
 
+func newPlayer(id, name string, conn *websocket.Conn) *Player {
+	p := &Player{
+		ID:       id,
+		Name:     name,
+		Conn:     conn,
+		Send:     make(chan []byte, config.SendBufferSize),
+		codec:    jsonWire,
+		Done:     make(chan struct{}),
+		lobbySub: newLobbySubscription(LobbyFilter{}, 0),
+	}
+	if conn != nil {
+		p.codec = codecFor(conn.Subprotocol())
+	}
+	return p
+}
+
+// close marks the player as disconnected and closes the underlying connection.
+// It is safe to call multiple times.
+func (p *Player) close() {
+	p.closeOnce.Do(func() {
+		close(p.Done)
+		if p.Conn != nil {
+			p.Conn.Close()
+		}
+	})
+}
+
+// dropSlowConsumer closes the connection of a player that does not read its
+// messages fast enough. The read loop in handleWebSocket notices the closed
+// connection and cleans up the player like any other disconnect.
+func dropSlowConsumer(p *Player) {
+	log.Printf("Send buffer full for %s, disconnecting slow consumer", p.ID)
+	if p.Conn != nil {
+		p.Conn.WriteControl(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "Slow consumer"),
+			time.Now().Add(config.WriteWait),
+		)
+	}
+	p.close()
+}
+
 func (p *Player) writePump() {
-	defer p.Conn.Close()
+	ticker := time.NewTicker(config.PingPeriod())
+	defer func() {
+		ticker.Stop()
+		p.Conn.Close()
+	}()
 
-	for msg := range p.Send {
-		err := p.Conn.WriteMessage(websocket.TextMessage, msg)
-		if err != nil {
-			log.Println("write error:", err)
+	for {
+		select {
+		case msg := <-p.Send:
+			p.Conn.SetWriteDeadline(time.Now().Add(config.WriteWait))
+			p.Conn.EnableWriteCompression(len(msg) >= compressionThreshold)
+			if err := p.Conn.WriteMessage(p.codec.frameType(), msg); err != nil {
+				log.Println("write error:", err)
+				return
+			}
+
+		case <-ticker.C:
+			p.Conn.SetWriteDeadline(time.Now().Add(config.WriteWait))
+			if err := p.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				log.Println("ping error:", err)
+				return
+			}
+
+		case <-p.Done:
 			return
 		}
 	}
 }
 
 func sendResponse(p *Player, r Response) {
-	msg, err := json.Marshal(r)
+	msg, err := p.codec.marshal(r)
 
 	if err != nil {
 		log.Printf("MessageType:%v. Marshal error: %v", r.GetType(), err)
 		return
 	}
 
+	p.enqueue(msg)
+}
+
+// enqueue stamps msg with the connection's next sequence number and queues
+// it for writePump
+func (p *Player) enqueue(msg []byte) {
+	p.sendLock.Lock()
+	defer p.sendLock.Unlock()
+
+	select {
+	case <-p.Done:
+		return
+	default:
+	}
+
+	p.seq++
 	select {
-	case p.Send <- msg:
+	case p.Send <- p.codec.withSeq(msg, p.seq):
+	case <-p.Done:
 	default:
-		log.Printf("Dropping message for %s (send buffer full)", p.ID)
+		// Never drop messages silently: a client that can't keep up gets
+		// disconnected and has to reconnect for a fresh state.
+		go dropSlowConsumer(p)
 	}
 }
@@ -1,15 +1,21 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// Messages smaller than this are not worth compressing
+const compressionThreshold = 512
+
 var (
 	lobbies     = make(map[string]*Lobby)
 	lobbiesLock sync.RWMutex
@@ -20,39 +26,85 @@ var (
 	upgrader = websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
-		CheckOrigin:     func(r *http.Request) bool { return true },
+		CheckOrigin:     checkOrigin,
+		Subprotocols:    wireSubprotocols(),
 	}
 )
 
-func sendErrorToPlayer(player *Player, errorMsg string) {
+func sendErrorToPlayer(player *Player, requestID string, code ErrorCode, errorMsg string) {
 	sendResponse(player, ErrorResponse{
-		BaseResponse: newBaseResponse(ResponseError),
+		BaseResponse: newReplyResponse(ResponseError, requestID),
+		Code:         code,
 		Error:        errorMsg,
 	})
 }
 
-func sendErrorToConn(conn *websocket.Conn, errorMsg string) {
-	err := conn.WriteJSON(ErrorResponse{
-		BaseResponse: newBaseResponse(ResponseError),
+func sendErrorToConn(conn *websocket.Conn, requestID string, code ErrorCode, errorMsg string) {
+	writeToConn(conn, ErrorResponse{
+		BaseResponse: newReplyResponse(ResponseError, requestID),
+		Code:         code,
 		Error:        errorMsg,
 	})
+}
+
+// writeToConn writes r directly to a connection that has no player and
+// writePump yet
+func writeToConn(conn *websocket.Conn, r Response) {
+	c := codecFor(conn.Subprotocol())
+	msg, err := c.marshal(r)
 	if err != nil {
-		log.Printf("Failed to send error message to connection %s: %v", conn.RemoteAddr(), err)
+		log.Printf("MessageType:%v. Marshal error: %v", r.GetType(), err)
+		return
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(config.WriteWait))
+	if err := conn.WriteMessage(c.frameType(), msg); err != nil {
+		log.Printf("Failed to send message to connection %s: %v", conn.RemoteAddr(), err)
 	}
 }
 
 func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	log.Printf("NEW WEBSOCKET CONNECTION from %s", r.RemoteAddr)
-	// Upgrade to WebSocket immediately (no auth check yet)
+	ip := remoteIP(r)
+
+	// Credentials in the upgrade request are checked before upgrading, so a
+	// bad token never gets a socket
+	var upgradePlayerID string
+	if hasUpgradeCredentials(r) {
+		if ok, retryAfter := rateLimits.allow("", ip, RequestAuthentication); !ok {
+			w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(retryAfter.Seconds())), 10))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+
+		playerID, err := authenticateUpgrade(r)
+		if err != nil || playerID == "" {
+			http.Error(w, "Invalid or expired credentials", http.StatusUnauthorized)
+			return
+		}
+		upgradePlayerID = playerID
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("Error upgrading connection:", err)
 		return
 	}
 
-	// Create temporary player for initial connection
+	wire := codecFor(conn.Subprotocol())
+
 	var player *Player
-	authenticated := false
+
+	// Until the player is authenticated the read deadline is the hard
+	// deadline for the authenticate message, pongs don't extend it
+	conn.SetReadLimit(config.MaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(config.AuthTimeout))
+	conn.SetPongHandler(func(string) error {
+		if player != nil {
+			conn.SetReadDeadline(time.Now().Add(config.PongWait))
+		}
+		return nil
+	})
 
 	defer func() {
 		conn.Close()
@@ -64,176 +116,109 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 			if exists && current.Conn == conn {
 				disconnectPlayer(player.ID)
-				broadcastLobbies()
 				pingAllFriendsOnlineStatusHandler(player.ID, false) //isOnline = false
 			}
 
 		}
 	}()
 
-	// Wait for authentication message
+	// login authenticates the connection and lifts the authentication deadline
+	login := func(playerID, requestID string) bool {
+		p, err := authenticateConn(conn, playerID, requestID)
+		if err != nil {
+			sendErrorToConn(conn, requestID, ErrorPlayerNotFound, "Player not found")
+			return false
+		}
+		player = p
+		conn.SetReadDeadline(time.Now().Add(config.PongWait))
+		return true
+	}
+
+	if upgradePlayerID != "" && !login(upgradePlayerID, "") {
+		return
+	}
+
+	// rejectUnauthenticated answers a bad message sent before authentication
+	// and reports whether the connection used up its attempts
+	unauthenticatedMessages := 0
+	rejectUnauthenticated := func(requestID string, code ErrorCode, errorMsg string) bool {
+		sendErrorToConn(conn, requestID, code, errorMsg)
+		unauthenticatedMessages++
+		if unauthenticatedMessages < maxUnauthenticatedMessages {
+			return false
+		}
+		conn.WriteControl(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Authentication failed"),
+			time.Now().Add(config.WriteWait),
+		)
+		return true
+	}
+
 	for {
 		_, msgBytes, err := conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			var netErr net.Error
+			if player == nil && errors.As(err, &netErr) && netErr.Timeout() {
+				log.Printf("Closing connection from %s: no authentication within %s", ip, config.AuthTimeout)
+				conn.WriteControl(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Authentication timeout"),
+					time.Now().Add(config.WriteWait),
+				)
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("WebSocket error: %v", err)
 			}
 			break
 		}
 
 		var base struct {
-			Type  MessageType `json:"type"`
-			Token string      `json:"token,omitempty"`
+			BaseRequest
+			Token string `json:"token,omitempty"`
 		}
-		if err := json.Unmarshal(msgBytes, &base); err != nil {
-			sendErrorToConn(conn, "Invalid message format")
-			continue
-		}
-
-		// If not authenticated yet, only accept authenticate messages
-		if !authenticated {
-			if base.Type != RequestAuthentication || base.Token == "" {
-				sendErrorToConn(conn, "Authentication required")
-				continue
-			}
-
-			// Validate JWT token
-			playerID, err := parseJWT(base.Token)
-			if err != nil {
-				sendErrorToConn(conn, "Invalid or expired token")
-				continue
-			}
-
-			// Get player from database
-			dbPlayer, err := getPlayerByID(playerID)
-			if err != nil {
-				sendErrorToConn(conn, "Player not found")
-				continue
-			}
+		decodeErr := wire.unmarshal(msgBytes, &base)
 
-			// Create authenticated player
-			player = &Player{
-				ID:   dbPlayer.ID,
-				Name: dbPlayer.Username,
-				Conn: conn,
-				Send: make(chan []byte, 256),
+		// Undecodable messages are charged too, so garbage can't bypass the limits
+		if allowed, closeConn := allowWebSocketMessage(conn, player, ip, base.BaseRequest); !allowed {
+			if closeConn {
+				break
 			}
+			continue
+		}
 
-			// Add to active players
-			var oldPlayer *Player
-			activePlayersLock.RLock()
-			if p, exists := activePlayers[player.ID]; exists {
-				oldPlayer = p
+		// Before authentication only a valid authenticate message is accepted
+		if player == nil {
+			switch {
+			case decodeErr != nil:
+				if rejectUnauthenticated("", ErrorInvalidMessage, "Invalid message format") {
+					return
+				}
+			case base.Type != RequestAuthentication || base.Token == "":
+				if rejectUnauthenticated(base.RequestID, ErrorAuthenticationRequired, "Authentication required") {
+					return
+				}
+			default:
+				playerID, err := parseJWT(base.Token)
+				if err != nil {
+					if rejectUnauthenticated(base.RequestID, ErrorInvalidToken, "Invalid or expired token") {
+						return
+					}
+					continue
+				}
+				if !login(playerID, base.RequestID) {
+					return
+				}
 			}
-			activePlayersLock.RUnlock()
-
-			if oldPlayer != nil {
-				log.Printf("Player %s already connected, disconnecting old connection", player.ID)
-
-				oldPlayer.Conn.WriteControl(
-					websocket.CloseMessage,
-					websocket.FormatCloseMessage(1008, "Duplicate login"),
-					time.Now().Add(time.Second),
-				)
+			continue
+		}
 
-				disconnectPlayer(oldPlayer.ID)
-			}
-			activePlayersLock.Lock()
-			activePlayers[player.ID] = player
-			activePlayersLock.Unlock()
-
-			go player.writePump()
-
-			authenticated = true
-
-			// Send welcome message
-			welcomeResponse := WelcomeResponse{
-				BaseResponse: newBaseResponse(ResponseWelcome),
-				Player: PlayerDTO{
-					ID:   player.ID,
-					Name: player.Name,
-				},
-				Message:               "Welcome back, " + player.Name + "!",
-				Lobbies:               getLobbiesList(),
-				PendingFriendRequests: getPendingFriendRequests(player.ID),
-				FriendsList:           getFriendsWithOnlineStatus(player.ID),
-			}
-			sendResponse(player, welcomeResponse)
-			log.Printf("Player %s authenticated successfully", player.ID)
-			pingAllFriendsOnlineStatusHandler(player.ID, true) //isOnline = true
+		if decodeErr != nil {
+			sendErrorToPlayer(player, "", ErrorInvalidMessage, "Invalid message format")
 			continue
 		}
 
 		// Handle authenticated messages
-		switch base.Type {
-		case RequestJoinLobby:
-			var msg JoinLobbyRequest
-			if err := json.Unmarshal(msgBytes, &msg); err != nil {
-				sendErrorToPlayer(player, "Invalid join_lobby message")
-				continue
-			}
-			msg.PlayerID = player.ID
-			msg.Name = player.Name
-			joinLobbyHandler(msg)
-
-		case RequestLeaveLobby:
-			var msg LeaveLobbyRequest
-			if err := json.Unmarshal(msgBytes, &msg); err != nil {
-				sendErrorToPlayer(player, "Invalid leave_lobby message")
-				continue
-			}
-			msg.PlayerID = player.ID
-			leaveLobbyHandler(msg)
-
-		case RequestCreateLobby:
-			var msg CreateLobbyRequest
-			if err := json.Unmarshal(msgBytes, &msg); err != nil {
-				sendErrorToPlayer(player, "Invalid create_lobby message")
-				continue
-			}
-			msg.PlayerID = player.ID
-			msg.PlayerName = player.Name
-			createLobbyHandler(msg)
-
-		case RequestStartGame:
-			var msg StartGame
-			if err := json.Unmarshal(msgBytes, &msg); err != nil {
-				sendErrorToPlayer(player, "Invalid start_game message")
-				continue
-			}
-			msg.PlayerID = player.ID
-			startGameHandler(msg)
-
-		case RequestCancelGame:
-			var msg CancelGame
-			if err := json.Unmarshal(msgBytes, &msg); err != nil {
-				sendErrorToPlayer(player, "Invalid cancel_game message")
-				continue
-			}
-			msg.PlayerID = player.ID
-			cancelGameHandler(msg)
-
-		case RequestAddFriend:
-			var msg AddFriendRequest
-			if err := json.Unmarshal(msgBytes, &msg); err != nil {
-				sendErrorToPlayer(player, "Invalid add_friend message")
-				continue
-			}
-			msg.PlayerID = player.ID
-			sendFriendRequestHandler(msg)
-
-		case RequestAcceptFriendRequest:
-			var msg AcceptFriendRequestRequest
-			if err := json.Unmarshal(msgBytes, &msg); err != nil {
-				sendErrorToPlayer(player, "Invalid accept_friend_request message")
-				continue
-			}
-			msg.PlayerID = player.ID
-			acceptFriendRequestHandler(msg)
-
-		default:
-			sendErrorToPlayer(player, "Unknown message type")
-		}
+		dispatchMessage(player, base.BaseRequest, msgBytes)
 	}
 
 }
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Offered in Sec-WebSocket-Protocol as "bearer.<jwt>" next to the
+	// encoding subprotocol. It is never echoed back.
+	bearerSubprotocolPrefix = "bearer."
+
+	// Rejected messages before authentication after which the connection
+	// is closed
+	maxUnauthenticatedMessages = 3
+)
+
+var (
+	ErrInvalidTicket    = errors.New("invalid or expired ticket")
+	ErrEmptyCredentials = errors.New("empty ticket or token")
+)
+
+type wsTicket struct {
+	playerID string
+	expires  time.Time
+}
+
+var (
+	wsTickets     = make(map[string]wsTicket)
+	wsTicketsLock sync.Mutex
+)
+
+// issueWSTicket creates a one-time ticket that opens a websocket for playerID
+func issueWSTicket(playerID string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	ticket := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	wsTicketsLock.Lock()
+	defer wsTicketsLock.Unlock()
+
+	for t, wt := range wsTickets {
+		if now.After(wt.expires) {
+			delete(wsTickets, t)
+		}
+	}
+	wsTickets[ticket] = wsTicket{playerID: playerID, expires: now.Add(config.WSTicketTTL)}
+
+	return ticket, nil
+}
+
+// redeemWSTicket returns the player of ticket and invalidates it
+func redeemWSTicket(ticket string) (string, error) {
+	wsTicketsLock.Lock()
+	defer wsTicketsLock.Unlock()
+
+	wt, ok := wsTickets[ticket]
+	if !ok {
+		return "", ErrInvalidTicket
+	}
+	delete(wsTickets, ticket)
+
+	if time.Now().After(wt.expires) {
+		return "", ErrInvalidTicket
+	}
+	return wt.playerID, nil
+}
+
+// handleWSTicket exchanges the JWT in the Authorization header for a ticket
+// that can be passed as ?ticket= when opening the websocket.
+func handleWSTicket(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			BaseResponse: newBaseResponse(ResponseError),
+			Code:         ErrorAuthenticationRequired,
+			Error:        "Bearer token required",
+		})
+		return
+	}
+
+	playerID, err := parseJWT(token)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			BaseResponse: newBaseResponse(ResponseError),
+			Code:         ErrorInvalidToken,
+			Error:        "Invalid or expired token",
+		})
+		return
+	}
+
+	ticket, err := issueWSTicket(playerID)
+	if err != nil {
+		log.Printf("Failed to issue websocket ticket: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			BaseResponse: newBaseResponse(ResponseError),
+			Code:         ErrorInternal,
+			Error:        "Failed to issue ticket",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(WSTicketResponse{
+		BaseResponse: newBaseResponse(ResponseWSTicket),
+		Ticket:       ticket,
+		ExpiresInMs:  config.WSTicketTTL.Milliseconds(),
+	})
+}
+
+// authenticateUpgrade returns the player a websocket upgrade request carries
+// credentials for, or "" if it carries none and has to send an
+// authenticate message instead. An empty ticket or token is an error.
+func authenticateUpgrade(r *http.Request) (string, error) {
+	if query := r.URL.Query(); query.Has("ticket") {
+		ticket := query.Get("ticket")
+		if ticket == "" {
+			return "", ErrEmptyCredentials
+		}
+		return redeemWSTicket(ticket)
+	}
+
+	for _, protocol := range websocket.Subprotocols(r) {
+		if token, ok := strings.CutPrefix(protocol, bearerSubprotocolPrefix); ok {
+			if token == "" {
+				return "", ErrEmptyCredentials
+			}
+			return parseJWT(token)
+		}
+	}
+
+	return "", nil
+}
+
+// hasUpgradeCredentials reports whether r tries to authenticate at upgrade
+func hasUpgradeCredentials(r *http.Request) bool {
+	if r.URL.Query().Has("ticket") {
+		return true
+	}
+	for _, protocol := range websocket.Subprotocols(r) {
+		if strings.HasPrefix(protocol, bearerSubprotocolPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticateConn makes conn the connection of playerID, replacing an older
+// connection of the same player, and sends the welcome message.
+func authenticateConn(conn *websocket.Conn, playerID, requestID string) (*Player, error) {
+	dbPlayer, err := getPlayerByID(playerID)
+	if err != nil {
+		return nil, err
+	}
+
+	player := newPlayer(dbPlayer.ID, dbPlayer.Username, conn)
+
+	var oldPlayer *Player
+	activePlayersLock.RLock()
+	if p, exists := activePlayers[player.ID]; exists {
+		oldPlayer = p
+	}
+	activePlayersLock.RUnlock()
+
+	if oldPlayer != nil {
+		log.Printf("Player %s already connected, disconnecting old connection", player.ID)
+
+		oldPlayer.Conn.WriteControl(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Duplicate login"),
+			time.Now().Add(time.Second),
+		)
+
+		disconnectPlayer(oldPlayer.ID)
+	}
+	activePlayersLock.Lock()
+	activePlayers[player.ID] = player
+	activePlayersLock.Unlock()
+
+	go player.writePump()
+
+	welcomeLobbies, _ := player.lobbySub.reset(getSortedLobbiesList())
+	sendResponse(player, WelcomeResponse{
+		BaseResponse: newReplyResponse(ResponseWelcome, requestID),
+		Player: PlayerDTO{
+			ID:   player.ID,
+			Name: player.Name,
+		},
+		Message:               "Welcome back, " + player.Name + "!",
+		Lobbies:               welcomeLobbies,
+		PendingFriendRequests: getPendingFriendRequests(player.ID),
+		FriendsList:           getFriendsWithOnlineStatus(player.ID),
+	})
+	log.Printf("Player %s authenticated successfully", player.ID)
+	pingAllFriendsOnlineStatusHandler(player.ID, true) //isOnline = true
+
+	// Seats kept across a restart go back to the player
+	reclaimSeats(player)
+
+	return player, nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// startWSServer serves /ws and /ws-ticket against a test database with one
+// player and returns the server together with a token of that player.
+func startWSServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	useTestDB(t)
+	old := config
+	config.JWTSecret = "test-secret-that-is-long-enough-for-hmac"
+	t.Cleanup(func() { config = old })
+
+	playerID, err := createPlayer("dave", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := generateJWT(playerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Handlers of hijacked connections outlive srv.Close, wait for them
+	// before the test database goes away
+	var handlers sync.WaitGroup
+	t.Cleanup(handlers.Wait)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		handlers.Add(1)
+		defer handlers.Done()
+		handleWebSocket(w, r)
+	})
+	mux.HandleFunc("/ws-ticket", handleWSTicket)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv, token
+}
+
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+}
+
+func readType(t *testing.T, conn *websocket.Conn) MessageType {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var msg BaseResponse
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return msg.Type
+}
+
+func TestWebSocketAuthWithBearerSubprotocol(t *testing.T) {
+	srv, token := startWSServer(t)
+
+	dialer := websocket.Dialer{Subprotocols: []string{SubprotocolJSON, bearerSubprotocolPrefix + token}}
+	conn, resp, err := dialer.Dial(wsURL(srv), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != SubprotocolJSON {
+		t.Errorf("negotiated subprotocol %q, want %q", got, SubprotocolJSON)
+	}
+	if got := readType(t, conn); got != ResponseWelcome {
+		t.Errorf("first message %q, want welcome", got)
+	}
+}
+
+func TestWebSocketRejectsBadTokenAtUpgrade(t *testing.T) {
+	srv, _ := startWSServer(t)
+
+	dialer := websocket.Dialer{Subprotocols: []string{SubprotocolJSON, bearerSubprotocolPrefix + "not.a.jwt"}}
+	_, resp, err := dialer.Dial(wsURL(srv), nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("dial: err = %v, resp = %v, want 401", err, resp)
+	}
+}
+
+func TestWebSocketRejectsEmptyCredentialsAtUpgrade(t *testing.T) {
+	srv, _ := startWSServer(t)
+
+	for name, dial := range map[string]func() (*http.Response, error){
+		"ticket": func() (*http.Response, error) {
+			_, resp, err := websocket.DefaultDialer.Dial(wsURL(srv)+"?ticket=", nil)
+			return resp, err
+		},
+		"bearer": func() (*http.Response, error) {
+			dialer := websocket.Dialer{Subprotocols: []string{SubprotocolJSON, bearerSubprotocolPrefix}}
+			_, resp, err := dialer.Dial(wsURL(srv), nil)
+			return resp, err
+		},
+	} {
+		resp, err := dial()
+		if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: dial: err = %v, resp = %v, want 401", name, err, resp)
+		}
+	}
+}
+
+func TestWebSocketAuthWithOneTimeTicket(t *testing.T) {
+	srv, token := startWSServer(t)
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/ws-ticket", bytes.NewReader(nil))
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var ticket WSTicketResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ticket); err != nil || ticket.Ticket == "" {
+		t.Fatalf("ticket response: %+v, err = %v", ticket, err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv)+"?ticket="+ticket.Ticket, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if got := readType(t, conn); got != ResponseWelcome {
+		t.Errorf("first message %q, want welcome", got)
+	}
+
+	// A ticket only works once
+	_, resp, err = websocket.DefaultDialer.Dial(wsURL(srv)+"?ticket="+ticket.Ticket, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("second dial: err = %v, want 401", err)
+	}
+}
+
+func TestWebSocketAuthenticateMessage(t *testing.T) {
+	srv, token := startWSServer(t)
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	conn.WriteJSON(map[string]string{"type": string(RequestAuthentication), "token": token})
+	if got := readType(t, conn); got != ResponseWelcome {
+		t.Errorf("first message %q, want welcome", got)
+	}
+}
+
+func TestWebSocketClosesWithoutAuthentication(t *testing.T) {
+	srv, _ := startWSServer(t)
+
+	old := config
+	config.AuthTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { config = old })
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("read: %v, want a policy violation close", err)
+	}
+}
+
+func TestWebSocketClosesAfterRepeatedBadMessages(t *testing.T) {
+	srv, _ := startWSServer(t)
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < maxUnauthenticatedMessages; i++ {
+		conn.WriteJSON(map[string]string{"type": string(RequestCreateLobby)})
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	if !websocket.IsCloseError(err, websocket.ClosePolicyViolation) {
+		t.Errorf("read: %v, want a policy violation close", err)
+	}
+}